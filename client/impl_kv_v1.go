@@ -34,6 +34,10 @@ func (c *routingImpl_KvV1) GetReplica(ctx context.Context, in *kv_v1.GetReplicaR
 	return c.client.fetchConnForKey(in.BucketName, in.Key).KvV1().GetReplica(ctx, in, opts...)
 }
 
+func (c *routingImpl_KvV1) GetAllReplicas(ctx context.Context, in *kv_v1.GetAllReplicasRequest, opts ...grpc.CallOption) (kv_v1.KvService_GetAllReplicasClient, error) {
+	return c.client.fetchConnForKey(in.BucketName, in.Key).KvV1().GetAllReplicas(ctx, in, opts...)
+}
+
 func (c *routingImpl_KvV1) Touch(ctx context.Context, in *kv_v1.TouchRequest, opts ...grpc.CallOption) (*kv_v1.TouchResponse, error) {
 	return c.client.fetchConnForKey(in.BucketName, in.Key).KvV1().Touch(ctx, in, opts...)
 }
@@ -85,3 +89,15 @@ func (c *routingImpl_KvV1) MutateIn(ctx context.Context, in *kv_v1.MutateInReque
 func (c *routingImpl_KvV1) RangeScan(ctx context.Context, in *kv_v1.RangeScanRequest, opts ...grpc.CallOption) (*kv_v1.RangeScanResponse, error) {
 	return c.client.fetchConnForBucket(in.BucketName).KvV1().RangeScan(ctx, in, opts...)
 }
+
+func (c *routingImpl_KvV1) Scan(ctx context.Context, in *kv_v1.ScanRequest, opts ...grpc.CallOption) (kv_v1.KvService_ScanClient, error) {
+	return c.client.fetchConnForBucket(in.BucketName).KvV1().Scan(ctx, in, opts...)
+}
+
+func (c *routingImpl_KvV1) GetMulti(ctx context.Context, in *kv_v1.GetMultiRequest, opts ...grpc.CallOption) (*kv_v1.GetMultiResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).KvV1().GetMulti(ctx, in, opts...)
+}
+
+func (c *routingImpl_KvV1) UpsertMulti(ctx context.Context, in *kv_v1.UpsertMultiRequest, opts ...grpc.CallOption) (*kv_v1.UpsertMultiResponse, error) {
+	return c.client.fetchConnForBucket(in.BucketName).KvV1().UpsertMulti(ctx, in, opts...)
+}
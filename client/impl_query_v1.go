@@ -21,3 +21,19 @@ func (c *routingImpl_QueryV1) Query(ctx context.Context, in *query_v1.QueryReque
 		return c.client.fetchConn().QueryV1().Query(ctx, in, opts...)
 	}
 }
+
+func (c *routingImpl_QueryV1) Explain(ctx context.Context, in *query_v1.ExplainRequest, opts ...grpc.CallOption) (*query_v1.ExplainResponse, error) {
+	if in.BucketName != nil {
+		return c.client.fetchConnForBucket(*in.BucketName).QueryV1().Explain(ctx, in, opts...)
+	} else {
+		return c.client.fetchConn().QueryV1().Explain(ctx, in, opts...)
+	}
+}
+
+func (c *routingImpl_QueryV1) AdviseIndexes(ctx context.Context, in *query_v1.AdviseIndexesRequest, opts ...grpc.CallOption) (*query_v1.AdviseIndexesResponse, error) {
+	if in.BucketName != nil {
+		return c.client.fetchConnForBucket(*in.BucketName).QueryV1().AdviseIndexes(ctx, in, opts...)
+	} else {
+		return c.client.fetchConn().QueryV1().AdviseIndexes(ctx, in, opts...)
+	}
+}
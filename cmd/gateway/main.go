@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/couchbase/stellar-gateway/gateway"
 	"github.com/couchbase/stellar-gateway/pkg/version"
@@ -52,6 +53,9 @@ func init() {
 	configFlags.String("key", "", "path to server private tls key")
 	configFlags.String("cacert", "", "path to root CA cert")
 	configFlags.Bool("debug", false, "enable debug mode")
+	configFlags.Int("stream-max-message-size", 1024*1024, "the maximum number of row bytes sent in a single streamed message")
+	configFlags.Duration("stream-target-latency", 50*time.Millisecond, "the longest a streamed row is held waiting for its batch to fill")
+	configFlags.String("capture-file", "", "path to a file which every request is recorded to, for later replay")
 	rootCmd.Flags().AddFlagSet(configFlags)
 
 	_ = viper.BindPFlags(configFlags)
@@ -99,6 +103,9 @@ func startGateway() {
 	keyPath := viper.GetString("key")
 	caCertPath := viper.GetString("cacert")
 	debug := viper.GetBool("debug")
+	streamMaxMessageSize := viper.GetInt("stream-max-message-size")
+	streamTargetLatency := viper.GetDuration("stream-target-latency")
+	captureFile := viper.GetString("capture-file")
 
 	logger.Info("parsed gateway configuration",
 		zap.String("logLevelStr", logLevelStr),
@@ -114,6 +121,9 @@ func startGateway() {
 		zap.String("keyPath", keyPath),
 		zap.String("cacertPath", caCertPath),
 		zap.Bool("debug", debug),
+		zap.Int("streamMaxMessageSize", streamMaxMessageSize),
+		zap.Duration("streamTargetLatency", streamTargetLatency),
+		zap.String("captureFile", captureFile),
 	)
 
 	parsedLogLevel, err := zapcore.ParseLevel(logLevelStr)
@@ -172,6 +182,11 @@ func startGateway() {
 		BindAddress:    bindAddress,
 		TlsCertificate: tlsCertificate,
 		NumInstances:   1,
+
+		StreamMaxMessageBytes: streamMaxMessageSize,
+		StreamTargetLatency:   streamTargetLatency,
+
+		CapturePath: captureFile,
 	}
 
 	gw, err := gateway.NewGateway(gatewayConfig)
@@ -0,0 +1,155 @@
+package capture
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// maxStreamMessages is the most requests, and separately responses, which are
+// captured for a single stream.  Streams such as WatchRouting never end, so
+// without a limit their records would grow without bound.
+const maxStreamMessages = 1000
+
+// Interceptor records every RPC passing through it into a Recorder.
+type Interceptor struct {
+	logger   *zap.Logger
+	recorder *Recorder
+}
+
+func NewInterceptor(logger *zap.Logger, recorder *Recorder) *Interceptor {
+	return &Interceptor{
+		logger:   logger,
+		recorder: recorder,
+	}
+}
+
+func (i *Interceptor) newRecord(ctx context.Context, method string, isStream bool) *Record {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return &Record{
+		Method:    method,
+		IsStream:  isStream,
+		StartTime: time.Now(),
+		Metadata:  filterMetadata(md),
+	}
+}
+
+func (i *Interceptor) addMessage(record *Record, msgs *[]*Message, msg interface{}) {
+	captureMsg, err := NewMessage(msg)
+	if err != nil {
+		i.logger.Debug("failed to capture message",
+			zap.String("method", record.Method),
+			zap.Error(err))
+		return
+	}
+
+	*msgs = append(*msgs, captureMsg)
+}
+
+func (i *Interceptor) writeRecord(record *Record, err error) {
+	record.Duration = time.Since(record.StartTime)
+
+	st := status.Convert(err)
+	record.Status = Status{
+		Code:    uint32(st.Code()),
+		Message: st.Message(),
+	}
+
+	writeErr := i.recorder.Write(record)
+	if writeErr != nil {
+		i.logger.Warn("failed to write capture record",
+			zap.String("method", record.Method),
+			zap.Error(writeErr))
+	}
+}
+
+func (i *Interceptor) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		record := i.newRecord(ctx, info.FullMethod, false)
+		i.addMessage(record, &record.Requests, req)
+
+		resp, err := handler(ctx, req)
+
+		if err == nil && resp != nil {
+			i.addMessage(record, &record.Responses, resp)
+			record.ResponseTime = append(record.ResponseTime, time.Since(record.StartTime))
+		}
+
+		i.writeRecord(record, err)
+
+		return resp, err
+	}
+}
+
+func (i *Interceptor) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		record := i.newRecord(ss.Context(), info.FullMethod, true)
+
+		err := handler(srv, &captureServerStream{
+			ServerStream: ss,
+			interceptor:  i,
+			record:       record,
+		})
+
+		i.writeRecord(record, err)
+
+		return err
+	}
+}
+
+type captureServerStream struct {
+	grpc.ServerStream
+
+	interceptor *Interceptor
+
+	// lock protects record, as gRPC permits RecvMsg and SendMsg to be
+	// called concurrently from different goroutines.
+	lock   sync.Mutex
+	record *Record
+}
+
+func (s *captureServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	if len(s.record.Requests) < maxStreamMessages {
+		s.interceptor.addMessage(s.record, &s.record.Requests, m)
+	} else {
+		s.record.Truncated = true
+	}
+	s.lock.Unlock()
+
+	return nil
+}
+
+func (s *captureServerStream) SendMsg(m interface{}) error {
+	s.lock.Lock()
+	if len(s.record.Responses) < maxStreamMessages {
+		s.interceptor.addMessage(s.record, &s.record.Responses, m)
+		s.record.ResponseTime = append(s.record.ResponseTime, time.Since(s.record.StartTime))
+	} else {
+		s.record.Truncated = true
+	}
+	s.lock.Unlock()
+
+	return s.ServerStream.SendMsg(m)
+}
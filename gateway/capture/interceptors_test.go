@@ -0,0 +1,71 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type testServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *testServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testServerStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *testServerStream) RecvMsg(m interface{}) error {
+	return nil
+}
+
+func TestStreamInterceptorTruncatesMessages(t *testing.T) {
+	recorder, err := NewRecorder(filepath.Join(t.TempDir(), "capture.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to create recorder: %s", err)
+	}
+	defer recorder.Close()
+
+	var record *Record
+	interceptor := NewInterceptor(zap.NewNop(), recorder)
+	err = interceptor.StreamInterceptor()(nil, &testServerStream{}, &grpc.StreamServerInfo{
+		FullMethod: "/test/Stream",
+	}, func(srv interface{}, ss grpc.ServerStream) error {
+		record = ss.(*captureServerStream).record
+
+		err := ss.RecvMsg(&emptypb.Empty{})
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < maxStreamMessages+10; i++ {
+			err := ss.SendMsg(&emptypb.Empty{})
+			if err != nil {
+				return err
+			}
+		}
+
+		return io.EOF
+	})
+	if err != io.EOF {
+		t.Fatalf("expected the handler error to be returned, got %v", err)
+	}
+
+	if len(record.Requests) != 1 {
+		t.Fatalf("expected 1 captured request, got %d", len(record.Requests))
+	}
+	if len(record.Responses) != maxStreamMessages || len(record.ResponseTime) != maxStreamMessages {
+		t.Fatalf("expected responses to be capped at %d, got %d", maxStreamMessages, len(record.Responses))
+	}
+	if !record.Truncated {
+		t.Fatalf("expected the record to be marked as truncated")
+	}
+}
@@ -0,0 +1,241 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Message is a single protobuf message within a capture, stored in its binary
+// wire form along with the full name of its type so that it can be decoded.
+type Message struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// sensitiveFieldNames lists the message fields which are cleared before a
+// message is written into a capture, since captures are expected to be shared.
+// Requests with redacted fields will not replay faithfully.
+var sensitiveFieldNames = []string{
+	"password",
+	"new_password",
+}
+
+func NewMessage(msg interface{}) (*Message, error) {
+	return newMessage(msg, sensitiveFieldNames)
+}
+
+func newMessage(msg interface{}, redactedFieldNames []string) (*Message, error) {
+	protoMsg, ok := msg.(proto.Message)
+	if !ok {
+		return nil, errors.New("captured message is not a protobuf message")
+	}
+
+	// the message is still in use by the caller, so redaction is performed on
+	// a copy, and only when there is something to redact.
+	if redactFields(protoMsg.ProtoReflect(), redactedFieldNames, false) {
+		protoMsg = proto.Clone(protoMsg)
+		redactFields(protoMsg.ProtoReflect(), redactedFieldNames, true)
+	}
+
+	data, err := proto.Marshal(protoMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Type: string(protoMsg.ProtoReflect().Descriptor().FullName()),
+		Data: data,
+	}, nil
+}
+
+// redactFields reports whether any of the named fields are set anywhere within
+// msg, clearing them if clear is specified.
+func redactFields(msg protoreflect.Message, fieldNames []string, clear bool) bool {
+	found := false
+	msg.Range(func(fd protoreflect.FieldDescriptor, val protoreflect.Value) bool {
+		for _, fieldName := range fieldNames {
+			if string(fd.Name()) == fieldName {
+				found = true
+				if clear {
+					msg.Clear(fd)
+				}
+				return clear
+			}
+		}
+
+		switch {
+		case fd.IsList():
+			if fd.Kind() == protoreflect.MessageKind {
+				list := val.List()
+				for i := 0; i < list.Len(); i++ {
+					if redactFields(list.Get(i).Message(), fieldNames, clear) {
+						found = true
+					}
+				}
+			}
+		case fd.IsMap():
+			if fd.MapValue().Kind() == protoreflect.MessageKind {
+				val.Map().Range(func(_ protoreflect.MapKey, mapVal protoreflect.Value) bool {
+					if redactFields(mapVal.Message(), fieldNames, clear) {
+						found = true
+					}
+					return true
+				})
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			if redactFields(val.Message(), fieldNames, clear) {
+				found = true
+			}
+		}
+
+		return clear || !found
+	})
+
+	return found
+}
+
+type Status struct {
+	Code    uint32 `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Record is a single RPC within a capture.  Unary calls have exactly one
+// request and at most one response, streaming calls may have any number up to
+// a limit, beyond which further messages are dropped and Truncated is set.
+type Record struct {
+	Method       string              `json:"method"`
+	IsStream     bool                `json:"isStream,omitempty"`
+	StartTime    time.Time           `json:"startTime"`
+	Duration     time.Duration       `json:"duration"`
+	Metadata     map[string][]string `json:"metadata,omitempty"`
+	Requests     []*Message          `json:"requests"`
+	Responses    []*Message          `json:"responses,omitempty"`
+	ResponseTime []time.Duration     `json:"responseTimes,omitempty"`
+	Truncated    bool                `json:"truncated,omitempty"`
+	Status       Status              `json:"status"`
+}
+
+// sensitiveMetadataKeys lists the metadata which is never written into a
+// capture, since captures are expected to be shared.
+var sensitiveMetadataKeys = []string{
+	"authorization",
+	"cookie",
+}
+
+func filterMetadata(md map[string][]string) map[string][]string {
+	filtered := make(map[string][]string, len(md))
+	for key, values := range md {
+		lowerKey := strings.ToLower(key)
+
+		isSensitive := false
+		for _, sensitiveKey := range sensitiveMetadataKeys {
+			if lowerKey == sensitiveKey {
+				isSensitive = true
+				break
+			}
+		}
+
+		// pseudo-headers such as :authority are transport details
+		if isSensitive || strings.HasPrefix(lowerKey, ":") {
+			continue
+		}
+
+		filtered[key] = values
+	}
+
+	return filtered
+}
+
+// Recorder writes capture records to a file, one JSON document per line.
+type Recorder struct {
+	lock    sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (r *Recorder) Write(record *Record) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return errors.New("recorder is closed")
+	}
+
+	return r.encoder.Encode(record)
+}
+
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Reader reads the records of a capture written by a Recorder.
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+
+	// records contain whole messages, so lines can be far longer than the
+	// default scanner limit.
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	return &Reader{
+		scanner: scanner,
+	}
+}
+
+// Next returns the next record in the capture, or io.EOF once all the records
+// have been read.
+func (r *Reader) Next() (*Record, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		err := json.Unmarshal(line, &record)
+		if err != nil {
+			return nil, err
+		}
+
+		return &record, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
@@ -0,0 +1,162 @@
+package capture
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %s", err)
+	}
+
+	records := []*Record{
+		{
+			Method:    "/couchbase.kv.v1.KvService/Get",
+			StartTime: time.Now().UTC(),
+			Duration:  5 * time.Millisecond,
+			Requests:  []*Message{{Type: "couchbase.kv.v1.GetRequest", Data: []byte{1, 2, 3}}},
+			Responses: []*Message{{Type: "couchbase.kv.v1.GetResponse", Data: []byte{4, 5}}},
+		},
+		{
+			Method:    "/couchbase.query.v1.QueryService/Query",
+			IsStream:  true,
+			StartTime: time.Now().UTC(),
+			Status:    Status{Code: 5, Message: "not found"},
+		},
+	}
+
+	for _, record := range records {
+		err := recorder.Write(record)
+		if err != nil {
+			t.Fatalf("failed to write record: %s", err)
+		}
+	}
+
+	err = recorder.Close()
+	if err != nil {
+		t.Fatalf("failed to close recorder: %s", err)
+	}
+
+	err = recorder.Write(records[0])
+	if err == nil {
+		t.Fatalf("expected writing to a closed recorder to fail")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open capture: %s", err)
+	}
+	defer file.Close()
+
+	reader := NewReader(file)
+	for recordIdx, expected := range records {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("failed to read record %d: %s", recordIdx, err)
+		}
+
+		if record.Method != expected.Method || record.IsStream != expected.IsStream ||
+			record.Status != expected.Status || !record.StartTime.Equal(expected.StartTime) {
+			t.Fatalf("unexpected record %d: %+v", recordIdx, record)
+		}
+
+		if len(record.Requests) != len(expected.Requests) || len(record.Responses) != len(expected.Responses) {
+			t.Fatalf("unexpected messages in record %d: %+v", recordIdx, record)
+		}
+
+		for msgIdx, msg := range record.Requests {
+			if msg.Type != expected.Requests[msgIdx].Type || string(msg.Data) != string(expected.Requests[msgIdx].Data) {
+				t.Fatalf("unexpected request %d in record %d: %+v", msgIdx, recordIdx, msg)
+			}
+		}
+	}
+
+	_, err = reader.Next()
+	if err != io.EOF {
+		t.Fatalf("expected EOF after the last record, got %v", err)
+	}
+}
+
+func TestFilterMetadata(t *testing.T) {
+	filtered := filterMetadata(map[string][]string{
+		"authorization": {"Basic Zm9vOmJhcg=="},
+		"cookie":        {"session=1"},
+		":authority":    {"localhost"},
+		"user-agent":    {"grpc-go"},
+		"x-custom":      {"a", "b"},
+	})
+
+	if len(filtered) != 2 {
+		t.Fatalf("unexpected filtered metadata: %v", filtered)
+	}
+	if _, ok := filtered["user-agent"]; !ok {
+		t.Fatalf("expected user-agent to be kept: %v", filtered)
+	}
+	if len(filtered["x-custom"]) != 2 {
+		t.Fatalf("expected x-custom to be kept: %v", filtered)
+	}
+}
+
+func TestMessageRedactsFields(t *testing.T) {
+	msg := &descriptorpb.FileDescriptorProto{
+		Name: proto.String("test.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Test"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("a"), JsonName: proto.String("secret")},
+					{Name: proto.String("b")},
+				},
+			},
+		},
+	}
+
+	captured, err := newMessage(msg, []string{"json_name"})
+	if err != nil {
+		t.Fatalf("failed to capture message: %s", err)
+	}
+
+	var decoded descriptorpb.FileDescriptorProto
+	err = proto.Unmarshal(captured.Data, &decoded)
+	if err != nil {
+		t.Fatalf("failed to decode captured message: %s", err)
+	}
+
+	if decoded.MessageType[0].Field[0].JsonName != nil {
+		t.Fatalf("expected the nested field to be redacted")
+	}
+	if decoded.MessageType[0].Field[0].GetName() != "a" || decoded.GetName() != "test.proto" {
+		t.Fatalf("unexpected fields were redacted: %+v", &decoded)
+	}
+
+	if msg.MessageType[0].Field[0].GetJsonName() != "secret" {
+		t.Fatalf("redaction modified the original message")
+	}
+}
+
+func TestMessageWithoutRedactedFields(t *testing.T) {
+	msg := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+	}
+
+	captured, err := newMessage(msg, sensitiveFieldNames)
+	if err != nil {
+		t.Fatalf("failed to capture message: %s", err)
+	}
+
+	expectedData, _ := proto.Marshal(msg)
+	if captured.Type != "google.protobuf.FileDescriptorProto" || string(captured.Data) != string(expectedData) {
+		t.Fatalf("unexpected captured message: %+v", captured)
+	}
+}
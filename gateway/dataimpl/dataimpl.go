@@ -1,19 +1,30 @@
 package dataimpl
 
 import (
+	"context"
+	"time"
+
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
 	"github.com/couchbase/stellar-gateway/gateway/dataimpl/server_v1"
 	"github.com/couchbase/stellar-gateway/gateway/topology"
+	"github.com/couchbase/stellar-gateway/pkg/metrics"
 	"go.uber.org/zap"
 )
 
 type NewOptions struct {
+	// Ctx bounds the lifetime of any background work started by the servers.
+	Ctx context.Context
+
 	Logger *zap.Logger
 
 	TopologyProvider topology.Provider
 	CbClient         *gocbcorex.AgentManager
 	Authenticator    auth.Authenticator
+	Metrics          *metrics.SnMetrics
+
+	StreamMaxMessageBytes int
+	StreamTargetLatency   time.Duration
 
 	Debug bool
 }
@@ -23,11 +34,15 @@ type Servers struct {
 	QueryV1Server            *server_v1.QueryServer
 	SearchV1Server           *server_v1.SearchServer
 	AnalyticsV1Server        *server_v1.AnalyticsServer
+	ViewV1Server             *server_v1.ViewServer
 	AdminBucketV1Server      *server_v1.BucketAdminServer
 	AdminCollectionV1Server  *server_v1.CollectionAdminServer
 	AdminQueryIndexV1Server  *server_v1.QueryIndexAdminServer
 	AdminSearchIndexV1Server *server_v1.SearchIndexAdminServer
+	AdminViewV1Server        *server_v1.ViewAdminServer
+	AdminUserV1Server        *server_v1.UserAdminServer
 	TransactionsV1Server     *server_v1.TransactionsServer
+	ChangeFeedV1Server       *server_v1.ChangeFeedServer
 }
 
 func New(opts *NewOptions) *Servers {
@@ -43,26 +58,49 @@ func New(opts *NewOptions) *Servers {
 		CbClient:      opts.CbClient,
 	}
 
+	v1QueryPreparedCache := server_v1.NewQueryPreparedCache(&server_v1.QueryPreparedCacheOptions{
+		Ctx:              opts.Ctx,
+		Logger:           opts.Logger.Named("query-prepared-cache"),
+		TopologyProvider: opts.TopologyProvider,
+		Metrics:          opts.Metrics,
+	})
+
+	v1RowBatcherOpts := &server_v1.RowBatcherOptions{
+		MaxBatchBytes: opts.StreamMaxMessageBytes,
+		TargetLatency: opts.StreamTargetLatency,
+	}
+
 	return &Servers{
 		KvV1Server: server_v1.NewKvServer(
 			opts.Logger.Named("kv"),
 			v1ErrHandler,
 			v1AuthHandler,
+			opts.TopologyProvider,
 		),
 		QueryV1Server: server_v1.NewQueryServer(
 			opts.Logger.Named("query"),
 			v1ErrHandler,
 			v1AuthHandler,
+			v1QueryPreparedCache,
+			v1RowBatcherOpts,
 		),
 		SearchV1Server: server_v1.NewSearchServer(
 			opts.Logger.Named("search"),
 			v1ErrHandler,
 			v1AuthHandler,
+			v1RowBatcherOpts,
 		),
 		AnalyticsV1Server: server_v1.NewAnalyticsServer(
 			opts.Logger.Named("analytics"),
 			v1ErrHandler,
 			v1AuthHandler,
+			v1RowBatcherOpts,
+		),
+		ViewV1Server: server_v1.NewViewServer(
+			opts.Logger.Named("view"),
+			v1ErrHandler,
+			v1AuthHandler,
+			v1RowBatcherOpts,
 		),
 		AdminBucketV1Server: server_v1.NewBucketAdminServer(
 			opts.Logger.Named("adminbucket"),
@@ -84,10 +122,26 @@ func New(opts *NewOptions) *Servers {
 			v1ErrHandler,
 			v1AuthHandler,
 		),
+		AdminViewV1Server: server_v1.NewViewAdminServer(
+			opts.Logger.Named("adminview"),
+			v1ErrHandler,
+			v1AuthHandler,
+		),
+		AdminUserV1Server: server_v1.NewUserAdminServer(
+			opts.Logger.Named("adminuser"),
+			v1ErrHandler,
+			v1AuthHandler,
+		),
 		TransactionsV1Server: server_v1.NewTransactionsServer(
 			opts.Logger.Named("transactions"),
 			v1ErrHandler,
 			v1AuthHandler,
 		),
+		ChangeFeedV1Server: server_v1.NewChangeFeedServer(
+			opts.Logger.Named("changefeed"),
+			v1ErrHandler,
+			v1AuthHandler,
+			opts.TopologyProvider,
+		),
 	}
 }
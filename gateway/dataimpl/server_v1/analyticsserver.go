@@ -1,26 +1,219 @@
 package server_v1
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbanalyticsx"
 	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type AnalyticsServer struct {
 	analytics_v1.UnimplementedAnalyticsServiceServer
 
-	logger       *zap.Logger
-	errorHandler *ErrorHandler
-	authHandler  *AuthHandler
+	logger         *zap.Logger
+	errorHandler   *ErrorHandler
+	authHandler    *AuthHandler
+	rowBatcherOpts *RowBatcherOptions
 }
 
 func NewAnalyticsServer(
 	logger *zap.Logger,
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
+	rowBatcherOpts *RowBatcherOptions,
 ) *AnalyticsServer {
 	return &AnalyticsServer{
-		logger:       logger,
-		errorHandler: errorHandler,
-		authHandler:  authHandler,
+		logger:         logger,
+		errorHandler:   errorHandler,
+		authHandler:    authHandler,
+		rowBatcherOpts: rowBatcherOpts,
+	}
+}
+
+// analyticsMissingResourceName extracts the name of the missing dataverse or dataset
+// from an analytics error message such as "Cannot find dataset with name foo in
+// dataverse Default".  An empty string is returned if no name can be found.
+func analyticsMissingResourceName(msg string) string {
+	_, rest, found := strings.Cut(msg, "with name ")
+	if !found {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(rest, " ")
+	return strings.Trim(name, "[]`")
+}
+
+func (s *AnalyticsServer) translateError(err error) *status.Status {
+	var serverErrs *cbanalyticsx.ServerErrors
+	if errors.As(err, &serverErrs) {
+		if len(serverErrs.Errors) == 0 {
+			return s.errorHandler.NewInternalStatus()
+		}
+
+		firstErr := serverErrs.Errors[0]
+		if errors.Is(firstErr, cbanalyticsx.ErrParsingFailure) ||
+			errors.Is(firstErr, cbanalyticsx.ErrCompilationFailure) {
+			return s.errorHandler.NewInvalidAnalyticsQueryStatus(err, firstErr.Msg)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrAuthenticationFailure) {
+			return s.errorHandler.NewAnalyticsNoAccessStatus(err)
+		} else if errors.Is(firstErr, cbanalyticsx.ErrDataverseNotFound) {
+			return s.errorHandler.NewDataverseMissingStatus(err, analyticsMissingResourceName(firstErr.Msg))
+		} else if errors.Is(firstErr, cbanalyticsx.ErrDatasetNotFound) {
+			return s.errorHandler.NewDatasetMissingStatus(err, analyticsMissingResourceName(firstErr.Msg))
+		} else if errors.Is(firstErr, cbanalyticsx.ErrJobQueueFull) {
+			return s.errorHandler.NewAnalyticsJobQueueFullStatus(err)
+		}
+	}
+
+	return s.errorHandler.NewGenericStatus(err)
+}
+
+func (s *AnalyticsServer) AnalyticsQuery(
+	in *analytics_v1.AnalyticsQueryRequest,
+	out analytics_v1.AnalyticsService_AnalyticsQueryServer,
+) error {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(out.Context(), in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	var opts gocbcorex.AnalyticsOptions
+	opts.OnBehalfOf = oboInfo
+
+	opts.Statement = in.Statement
+
+	if in.BucketName == nil && in.ScopeName != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid scope and bucket name combination options specified")
+	}
+
+	if in.BucketName != nil && in.ScopeName != nil {
+		opts.QueryContext = fmt.Sprintf("default:`%s`.`%s`", in.GetBucketName(), in.GetScopeName())
+	}
+
+	if in.ReadOnly != nil {
+		opts.ReadOnly = *in.ReadOnly
+	}
+
+	if in.ClientContextId != nil {
+		opts.ClientContextId = *in.ClientContextId
 	}
+
+	if in.Priority != nil && *in.Priority {
+		opts.Priority = -1
+	}
+
+	if in.ScanConsistency != nil {
+		switch *in.ScanConsistency {
+		case analytics_v1.AnalyticsQueryRequest_SCAN_CONSISTENCY_NOT_BOUNDED:
+			opts.ScanConsistency = cbanalyticsx.QueryScanConsistencyNotBounded
+		case analytics_v1.AnalyticsQueryRequest_SCAN_CONSISTENCY_REQUEST_PLUS:
+			opts.ScanConsistency = cbanalyticsx.QueryScanConsistencyRequestPlus
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid scan consistency option specified")
+		}
+	}
+
+	named := in.GetNamedParameters()
+	pos := in.GetPositionalParameters()
+	if len(named) > 0 && len(pos) > 0 {
+		return status.Errorf(codes.InvalidArgument, "named and positional parameters must be used exclusively")
+	}
+	if len(named) > 0 {
+		params := make(map[string]json.RawMessage, len(named))
+		for k, v := range named {
+			params[k] = v
+		}
+		opts.NamedArgs = params
+	}
+	if len(pos) > 0 {
+		params := make([]json.RawMessage, len(pos))
+		for i, p := range pos {
+			params[i] = p
+		}
+		opts.Args = params
+	}
+
+	result, err := agent.Analytics(out.Context(), &opts)
+	if err != nil {
+		return s.translateError(err).Err()
+	}
+
+	batcher := newRowBatcher(s.rowBatcherOpts, func(rows [][]byte) error {
+		return out.Send(&analytics_v1.AnalyticsQueryResponse{
+			Rows:     rows,
+			MetaData: nil,
+		})
+	})
+	defer batcher.Close()
+
+	for result.HasMoreRows() {
+		rowBytes, err := result.ReadRow()
+		if err != nil {
+			return s.translateError(err).Err()
+		}
+
+		err = batcher.Add(rowBytes, len(rowBytes))
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
+		}
+	}
+
+	var psMetaData *analytics_v1.AnalyticsQueryResponse_MetaData
+
+	metaData, err := result.MetaData()
+	if err == nil {
+		psMetaData = &analytics_v1.AnalyticsQueryResponse_MetaData{
+			RequestId:       metaData.RequestID,
+			ClientContextId: metaData.ClientContextID,
+			Metrics: &analytics_v1.AnalyticsQueryResponse_Metrics{
+				ElapsedTime:      durationFromGo(metaData.Metrics.ElapsedTime),
+				ExecutionTime:    durationFromGo(metaData.Metrics.ExecutionTime),
+				ResultCount:      metaData.Metrics.ResultCount,
+				ResultSize:       metaData.Metrics.ResultSize,
+				MutationCount:    metaData.Metrics.MutationCount,
+				SortCount:        metaData.Metrics.SortCount,
+				ErrorCount:       metaData.Metrics.ErrorCount,
+				WarningCount:     metaData.Metrics.WarningCount,
+				ProcessedObjects: metaData.Metrics.ProcessedObjects,
+			},
+		}
+
+		warnings := make([]*analytics_v1.AnalyticsQueryResponse_MetaData_Warning, len(metaData.Warnings))
+		for i, warning := range metaData.Warnings {
+			warnings[i] = &analytics_v1.AnalyticsQueryResponse_MetaData_Warning{
+				Code:    warning.Code,
+				Message: warning.Message,
+			}
+		}
+		psMetaData.Warnings = warnings
+
+		if metaData.Signature != nil {
+			sig, err := json.Marshal(metaData.Signature)
+			if err == nil {
+				psMetaData.Signature = sig
+			}
+		}
+	}
+
+	// if we have any rows or meta-data left to stream, we send that first
+	// before we process any errors that occurred.
+	rowCache := batcher.Take()
+	if rowCache != nil || psMetaData != nil {
+		err := out.Send(&analytics_v1.AnalyticsQueryResponse{
+			Rows:     rowCache,
+			MetaData: psMetaData,
+		})
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,46 @@
+package server_v1
+
+import "testing"
+
+func TestAnalyticsMissingResourceName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		msg      string
+		expected string
+	}{
+		{
+			name:     "Dataverse",
+			msg:      "Cannot find dataverse with name [travel]",
+			expected: "travel",
+		},
+		{
+			name:     "Dataset",
+			msg:      "Cannot find dataset with name airports in dataverse Default",
+			expected: "airports",
+		},
+		{
+			name:     "AnalyticsCollection",
+			msg:      "Cannot find analytics collection with name `airports` in analytics scope `Default`",
+			expected: "airports",
+		},
+		{
+			name:     "NoName",
+			msg:      "Cannot find dataset airports in dataverse Default nor an alias",
+			expected: "",
+		},
+		{
+			name:     "Empty",
+			msg:      "",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name := analyticsMissingResourceName(tc.msg)
+			if name != tc.expected {
+				t.Fatalf("expected '%s' from `%s`, got '%s'", tc.expected, tc.msg, name)
+			}
+		})
+	}
+}
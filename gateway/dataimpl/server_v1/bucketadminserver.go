@@ -32,6 +32,53 @@ func NewBucketAdminServer(
 	}
 }
 
+func bucketFromCbmgmtx(bucket *cbmgmtx.BucketDef) (*admin_bucket_v1.ListBucketsResponse_Bucket, *status.Status) {
+	bucketType, errSt := bucketTypeFromCbmgmtx(bucket.BucketType)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	evictionMode, errSt := evictionModeFromCbmgmtx(bucket.EvictionPolicy)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	compressionMode, errSt := compressionModeFromCbmgmtx(bucket.CompressionMode)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	minimumDurabilityLevel, errSt := durabilityLevelFromCbmgmtx(bucket.DurabilityMinLevel)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	storageBackend, errSt := storageBackendFromCbmgmtx(bucket.StorageBackend)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	conflictResolutionType, errSt := conflictResolutionTypeFromCbmgmtx(bucket.ConflictResolutionType)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	return &admin_bucket_v1.ListBucketsResponse_Bucket{
+		BucketName:             bucket.Name,
+		FlushEnabled:           bucket.FlushEnabled,
+		RamQuotaBytes:          bucket.RAMQuotaMB * 1024 * 1024,
+		NumReplicas:            bucket.ReplicaNumber,
+		ReplicaIndexes:         !bucket.ReplicaIndexDisabled,
+		BucketType:             bucketType,
+		EvictionMode:           evictionMode,
+		MaxExpirySecs:          uint32(bucket.MaxTTL / time.Second),
+		CompressionMode:        compressionMode,
+		MinimumDurabilityLevel: minimumDurabilityLevel,
+		StorageBackend:         storageBackend,
+		ConflictResolutionType: conflictResolutionType,
+	}, nil
+}
+
 func (s *BucketAdminServer) ListBuckets(
 	ctx context.Context,
 	in *admin_bucket_v1.ListBucketsRequest,
@@ -50,54 +97,46 @@ func (s *BucketAdminServer) ListBuckets(
 
 	var buckets []*admin_bucket_v1.ListBucketsResponse_Bucket
 	for _, bucket := range result {
-		bucketType, errSt := bucketTypeFromCbmgmtx(bucket.BucketType)
-		if errSt != nil {
-			return nil, errSt.Err()
-		}
-
-		evictionMode, errSt := evictionModeFromCbmgmtx(bucket.EvictionPolicy)
+		psBucket, errSt := bucketFromCbmgmtx(bucket)
 		if errSt != nil {
 			return nil, errSt.Err()
 		}
 
-		compressionMode, errSt := compressionModeFromCbmgmtx(bucket.CompressionMode)
-		if errSt != nil {
-			return nil, errSt.Err()
-		}
+		buckets = append(buckets, psBucket)
+	}
 
-		minimumDurabilityLevel, errSt := durabilityLevelFromCbmgmtx(bucket.DurabilityMinLevel)
-		if errSt != nil {
-			return nil, errSt.Err()
-		}
+	return &admin_bucket_v1.ListBucketsResponse{
+		Buckets: buckets,
+	}, nil
+}
 
-		storageBackend, errSt := storageBackendFromCbmgmtx(bucket.StorageBackend)
-		if errSt != nil {
-			return nil, errSt.Err()
-		}
+func (s *BucketAdminServer) GetBucket(
+	ctx context.Context,
+	in *admin_bucket_v1.GetBucketRequest,
+) (*admin_bucket_v1.GetBucketResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
 
-		conflictResolutionType, errSt := conflictResolutionTypeFromCbmgmtx(bucket.ConflictResolutionType)
-		if errSt != nil {
-			return nil, errSt.Err()
+	bucket, err := agent.GetBucket(ctx, &cbmgmtx.GetBucketOptions{
+		OnBehalfOf: oboInfo,
+		BucketName: in.BucketName,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrBucketNotFound) {
+			return nil, s.errorHandler.NewBucketMissingStatus(err, in.BucketName).Err()
 		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
 
-		buckets = append(buckets, &admin_bucket_v1.ListBucketsResponse_Bucket{
-			BucketName:             bucket.Name,
-			FlushEnabled:           bucket.FlushEnabled,
-			RamQuotaBytes:          bucket.RAMQuotaMB * 1024 * 1024,
-			NumReplicas:            bucket.ReplicaNumber,
-			ReplicaIndexes:         !bucket.ReplicaIndexDisabled,
-			BucketType:             bucketType,
-			EvictionMode:           evictionMode,
-			MaxExpirySecs:          uint32(bucket.MaxTTL / time.Second),
-			CompressionMode:        compressionMode,
-			MinimumDurabilityLevel: minimumDurabilityLevel,
-			StorageBackend:         storageBackend,
-			ConflictResolutionType: conflictResolutionType,
-		})
+	psBucket, errSt := bucketFromCbmgmtx(bucket)
+	if errSt != nil {
+		return nil, errSt.Err()
 	}
 
-	return &admin_bucket_v1.ListBucketsResponse{
-		Buckets: buckets,
+	return &admin_bucket_v1.GetBucketResponse{
+		Bucket: psBucket,
 	}, nil
 }
 
@@ -304,3 +343,28 @@ func (s *BucketAdminServer) DeleteBucket(
 
 	return &admin_bucket_v1.DeleteBucketResponse{}, nil
 }
+
+func (s *BucketAdminServer) FlushBucket(
+	ctx context.Context,
+	in *admin_bucket_v1.FlushBucketRequest,
+) (*admin_bucket_v1.FlushBucketResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.FlushBucket(ctx, &cbmgmtx.FlushBucketOptions{
+		OnBehalfOf: oboInfo,
+		BucketName: in.BucketName,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrBucketNotFound) {
+			return nil, s.errorHandler.NewBucketMissingStatus(err, in.BucketName).Err()
+		} else if errors.Is(err, cbmgmtx.ErrFlushDisabled) {
+			return nil, s.errorHandler.NewBucketFlushDisabledStatus(err, in.BucketName).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_bucket_v1.FlushBucketResponse{}, nil
+}
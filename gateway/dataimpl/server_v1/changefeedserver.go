@@ -0,0 +1,266 @@
+package server_v1
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/memdx"
+	"github.com/couchbase/goprotostellar/genproto/changefeed_v1"
+	"github.com/couchbase/stellar-gateway/gateway/topology"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ChangeFeedServer struct {
+	changefeed_v1.UnimplementedChangeFeedServiceServer
+
+	logger           *zap.Logger
+	errorHandler     *ErrorHandler
+	authHandler      *AuthHandler
+	topologyProvider topology.Provider
+}
+
+func NewChangeFeedServer(
+	logger *zap.Logger,
+	errorHandler *ErrorHandler,
+	authHandler *AuthHandler,
+	topologyProvider topology.Provider,
+) *ChangeFeedServer {
+	return &ChangeFeedServer{
+		logger:           logger,
+		errorHandler:     errorHandler,
+		authHandler:      authHandler,
+		topologyProvider: topologyProvider,
+	}
+}
+
+// changeFeedPosition tracks how far through a single vbucket a stream has
+// progressed, it is the information carried by a resume token.
+type changeFeedPosition struct {
+	VbucketUUID    uint64
+	SeqNo          uint64
+	SnapStartSeqNo uint64
+	SnapEndSeqNo   uint64
+}
+
+func (p changeFeedPosition) ToResumeToken(vbID uint16) *changefeed_v1.ResumeToken {
+	return &changefeed_v1.ResumeToken{
+		VbucketId:          uint32(vbID),
+		VbucketUuid:        p.VbucketUUID,
+		SeqNo:              p.SeqNo,
+		SnapshotStartSeqNo: p.SnapStartSeqNo,
+		SnapshotEndSeqNo:   p.SnapEndSeqNo,
+	}
+}
+
+func (s *ChangeFeedServer) translateError(err error, bucketName, scopeName, collectionName string, vbID uint16) *status.Status {
+	var rollbackErr *memdx.DcpRollbackError
+	if errors.As(err, &rollbackErr) {
+		return s.errorHandler.NewChangeFeedRollbackStatus(err, bucketName, vbID, rollbackErr.RollbackSeqNo)
+	} else if errors.Is(err, memdx.ErrUnknownCollectionName) {
+		return s.errorHandler.NewCollectionMissingStatus(err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, memdx.ErrUnknownScopeName) {
+		return s.errorHandler.NewScopeMissingStatus(err, bucketName, scopeName)
+	} else if errors.Is(err, memdx.ErrAccessError) {
+		return s.errorHandler.NewCollectionNoReadAccessStatus(err, bucketName, scopeName, collectionName)
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
+func (s *ChangeFeedServer) StreamChanges(
+	in *changefeed_v1.StreamChangesRequest,
+	out changefeed_v1.ChangeFeedService_StreamChangesServer,
+) error {
+	ctx, cancel := context.WithCancel(out.Context())
+	defer cancel()
+
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	if in.CollectionName != nil && in.ScopeName == nil {
+		return status.Errorf(codes.InvalidArgument, "a scope name must be specified when filtering by collection")
+	}
+
+	vbRouting, errSt := getVbucketRouting(ctx, s.topologyProvider, s.errorHandler, in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	// each vbucket is streamed by its own goroutine, clients which need to limit
+	// the resources used by a single call can split the vbuckets across several
+	// calls by requesting a subset of them.  By default all vbuckets are streamed.
+	startPositions := make(map[uint16]changeFeedPosition)
+	if len(in.VbucketIds) > 0 {
+		for _, vbID := range in.VbucketIds {
+			if uint(vbID) >= vbRouting.NumVbuckets {
+				return status.Errorf(codes.InvalidArgument, "invalid vbucket %d requested", vbID)
+			}
+
+			startPositions[uint16(vbID)] = changeFeedPosition{}
+		}
+	} else {
+		for vbID := uint16(0); uint(vbID) < vbRouting.NumVbuckets; vbID++ {
+			startPositions[vbID] = changeFeedPosition{}
+		}
+	}
+
+	// any vbucket without a resume token is streamed from the beginning.
+	for _, token := range in.ResumeTokens {
+		if _, ok := startPositions[uint16(token.VbucketId)]; !ok || uint(token.VbucketId) > math.MaxUint16 {
+			return status.Errorf(codes.InvalidArgument, "resume token references invalid vbucket %d", token.VbucketId)
+		}
+
+		startPositions[uint16(token.VbucketId)] = changeFeedPosition{
+			VbucketUUID:    token.VbucketUuid,
+			SeqNo:          token.SeqNo,
+			SnapStartSeqNo: token.SnapshotStartSeqNo,
+			SnapEndSeqNo:   token.SnapshotEndSeqNo,
+		}
+	}
+
+	eventCh := make(chan *changefeed_v1.StreamChangesResponse, 1024)
+	errCh := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for vbID, startPos := range startPositions {
+		wg.Add(1)
+		go func(vbID uint16, startPos changeFeedPosition) {
+			defer wg.Done()
+
+			err := s.streamVbucket(ctx, bucketAgent, oboUser, in, vbID, startPos, eventCh)
+			if err != nil {
+				select {
+				case errCh <- err:
+				default:
+				}
+			}
+		}(vbID, startPos)
+	}
+
+	go func() {
+		wg.Wait()
+		close(eventCh)
+	}()
+
+	for {
+		select {
+		case event, ok := <-eventCh:
+			if !ok {
+				return nil
+			}
+
+			err := out.Send(event)
+			if err != nil {
+				return s.errorHandler.NewGenericStatus(err).Err()
+			}
+		case err := <-errCh:
+			return err
+		}
+	}
+}
+
+// streamVbucket streams all the changes for a single vbucket into eventCh until
+// the context is cancelled or an error occurs.  Streams which are closed by the
+// server (during a rebalance for instance) are transparently reopened.
+func (s *ChangeFeedServer) streamVbucket(
+	ctx context.Context,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	in *changefeed_v1.StreamChangesRequest,
+	vbID uint16,
+	pos changeFeedPosition,
+	eventCh chan<- *changefeed_v1.StreamChangesResponse,
+) error {
+	scopeName := in.GetScopeName()
+	collectionName := in.GetCollectionName()
+
+	for {
+		stream, err := bucketAgent.DcpStreamRequest(ctx, &gocbcorex.DcpStreamRequestOptions{
+			OnBehalfOf:     oboUser,
+			ScopeName:      scopeName,
+			CollectionName: collectionName,
+			VbucketID:      vbID,
+			VbucketUUID:    pos.VbucketUUID,
+			StartSeqNo:     pos.SeqNo,
+			EndSeqNo:       math.MaxUint64,
+			SnapStartSeqNo: pos.SnapStartSeqNo,
+			SnapEndSeqNo:   pos.SnapEndSeqNo,
+			KeysOnly:       !in.IncludeContent,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return s.translateError(err, in.BucketName, scopeName, collectionName, vbID).Err()
+		}
+
+		pos.VbucketUUID = stream.VbucketUUID()
+
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				stream.Close()
+
+				if ctx.Err() != nil {
+					return nil
+				}
+				if errors.Is(err, memdx.ErrDcpStreamStateChanged) {
+					s.logger.Debug("change feed stream closed by server, reopening",
+						zap.Uint16("vbId", vbID),
+						zap.Uint64("seqNo", pos.SeqNo))
+					break
+				}
+				return s.translateError(err, in.BucketName, scopeName, collectionName, vbID).Err()
+			}
+
+			var eventType changefeed_v1.StreamChangesResponse_EventType
+			switch event.Type {
+			case gocbcorex.DcpEventTypeSnapshotMarker:
+				pos.SnapStartSeqNo = event.SnapStartSeqNo
+				pos.SnapEndSeqNo = event.SnapEndSeqNo
+				continue
+			case gocbcorex.DcpEventTypeMutation:
+				eventType = changefeed_v1.StreamChangesResponse_EVENT_TYPE_MUTATION
+			case gocbcorex.DcpEventTypeDeletion:
+				eventType = changefeed_v1.StreamChangesResponse_EVENT_TYPE_DELETION
+			case gocbcorex.DcpEventTypeExpiration:
+				eventType = changefeed_v1.StreamChangesResponse_EVENT_TYPE_EXPIRATION
+			default:
+				continue
+			}
+
+			pos.SeqNo = event.SeqNo
+
+			psEvent := &changefeed_v1.StreamChangesResponse{
+				Type:        eventType,
+				Key:         string(event.Key),
+				Cas:         event.Cas,
+				ResumeToken: pos.ToResumeToken(vbID),
+			}
+
+			if eventType == changefeed_v1.StreamChangesResponse_EVENT_TYPE_MUTATION {
+				psEvent.ContentFlags = event.Flags
+				if in.IncludeContent {
+					psEvent.Content = event.Value
+				}
+				if event.Expiry != 0 {
+					psEvent.Expiry = timeFromGo(time.Unix(int64(event.Expiry), 0))
+				}
+			}
+
+			select {
+			case eventCh <- psEvent:
+			case <-ctx.Done():
+				stream.Close()
+				return nil
+			}
+		}
+	}
+}
@@ -2,10 +2,13 @@ package server_v1
 
 import (
 	"context"
+	"errors"
 
 	"github.com/couchbase/gocbcorex/cbmgmtx"
 	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type CollectionAdminServer struct {
@@ -28,6 +31,15 @@ func NewCollectionAdminServer(
 	}
 }
 
+func (s *CollectionAdminServer) translateCollectionError(err error, bucketName, scopeName, collectionName string) *status.Status {
+	if errors.Is(err, cbmgmtx.ErrCollectionNotFound) {
+		return s.errorHandler.NewCollectionMissingStatus(err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, cbmgmtx.ErrScopeNotFound) {
+		return s.errorHandler.NewScopeMissingStatus(err, bucketName, scopeName)
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
 func (s *CollectionAdminServer) ListCollections(
 	ctx context.Context,
 	in *admin_collection_v1.ListCollectionsRequest,
@@ -51,7 +63,8 @@ func (s *CollectionAdminServer) ListCollections(
 
 		for _, collection := range scope.Collections {
 			collectionSpec := &admin_collection_v1.ListCollectionsResponse_Collection{
-				Name: collection.Name,
+				Name:                    collection.Name,
+				HistoryRetentionEnabled: collection.History,
 			}
 			if collection.MaxTTL > 0 {
 				collectionSpec.MaxExpirySecs = &collection.MaxTTL
@@ -106,6 +119,9 @@ func (s *CollectionAdminServer) DeleteScope(
 		ScopeName:  in.ScopeName,
 	})
 	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrScopeNotFound) {
+			return nil, s.errorHandler.NewScopeMissingStatus(err, in.BucketName, in.ScopeName).Err()
+		}
 		return nil, s.errorHandler.NewGenericStatus(err).Err()
 	}
 
@@ -132,14 +148,43 @@ func (s *CollectionAdminServer) CreateCollection(
 		CollectionName: in.CollectionName,
 		ScopeName:      in.ScopeName,
 		MaxTTL:         maxTTL,
+		HistoryEnabled: in.HistoryRetentionEnabled,
 	})
 	if err != nil {
-		return nil, s.errorHandler.NewGenericStatus(err).Err()
+		return nil, s.translateCollectionError(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
 	}
 
 	return &admin_collection_v1.CreateCollectionResponse{}, nil
 }
 
+func (s *CollectionAdminServer) UpdateCollection(
+	ctx context.Context,
+	in *admin_collection_v1.UpdateCollectionRequest,
+) (*admin_collection_v1.UpdateCollectionResponse, error) {
+	if in.MaxExpirySecs == nil && in.HistoryRetentionEnabled == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "at least one collection setting must be specified to update")
+	}
+
+	bucketAgent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, &in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	_, err := bucketAgent.UpdateCollection(ctx, &cbmgmtx.UpdateCollectionOptions{
+		OnBehalfOf:     oboInfo,
+		BucketName:     in.BucketName,
+		ScopeName:      in.ScopeName,
+		CollectionName: in.CollectionName,
+		MaxTTL:         in.MaxExpirySecs,
+		HistoryEnabled: in.HistoryRetentionEnabled,
+	})
+	if err != nil {
+		return nil, s.translateCollectionError(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
+	}
+
+	return &admin_collection_v1.UpdateCollectionResponse{}, nil
+}
+
 func (s *CollectionAdminServer) DeleteCollection(
 	ctx context.Context,
 	in *admin_collection_v1.DeleteCollectionRequest,
@@ -156,7 +201,7 @@ func (s *CollectionAdminServer) DeleteCollection(
 		CollectionName: in.CollectionName,
 	})
 	if err != nil {
-		return nil, s.errorHandler.NewGenericStatus(err).Err()
+		return nil, s.translateCollectionError(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
 	}
 
 	return &admin_collection_v1.DeleteCollectionResponse{}, nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/couchbase/gocbcorex/cbqueryx"
@@ -129,6 +130,21 @@ func (e ErrorHandler) NewBucketExistsStatus(baseErr error, bucketName string) *s
 	return st
 }
 
+func (e ErrorHandler) NewBucketFlushDisabledStatus(baseErr error, bucketName string) *status.Status {
+	st := status.New(codes.FailedPrecondition,
+		fmt.Sprintf("Flush is not enabled for bucket '%s'.",
+			bucketName))
+	st = e.tryAttachStatusDetails(st, &epb.PreconditionFailure{
+		Violations: []*epb.PreconditionFailure_Violation{{
+			Type:        "FLUSH_DISABLED",
+			Subject:     bucketName,
+			Description: "",
+		}},
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
 func (e ErrorHandler) NewScopeMissingStatus(baseErr error, bucketName, scopeName string) *status.Status {
 	st := status.New(codes.NotFound,
 		fmt.Sprintf("Scope '%s' not found in '%s'.",
@@ -156,6 +172,19 @@ func (e ErrorHandler) NewCollectionMissingStatus(baseErr error, bucketName, scop
 }
 
 func (e ErrorHandler) NewSearchIndexExistsStatus(baseErr error, indexName string) *status.Status {
+	st := status.New(codes.AlreadyExists,
+		fmt.Sprintf("Search index '%s' already existed.",
+			indexName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "searchindex",
+		ResourceName: indexName,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewSearchIndexMissingStatus(baseErr error, indexName string) *status.Status {
 	st := status.New(codes.NotFound,
 		fmt.Sprintf("Search index '%s' not found.",
 			indexName))
@@ -168,9 +197,9 @@ func (e ErrorHandler) NewSearchIndexExistsStatus(baseErr error, indexName string
 	return st
 }
 
-func (e ErrorHandler) NewSearchIndexMissingStatus(baseErr error, indexName string) *status.Status {
-	st := status.New(codes.AlreadyExists,
-		fmt.Sprintf("Search index '%s' already existed.",
+func (e ErrorHandler) NewSearchConsistencyTimeoutStatus(baseErr error, indexName string) *status.Status {
+	st := status.New(codes.DeadlineExceeded,
+		fmt.Sprintf("Search index '%s' did not reach the requested consistency in time.",
 			indexName))
 	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
 		ResourceType: "searchindex",
@@ -181,6 +210,58 @@ func (e ErrorHandler) NewSearchIndexMissingStatus(baseErr error, indexName strin
 	return st
 }
 
+func (e ErrorHandler) NewDesignDocumentMissingStatus(baseErr error, bucketName, designDocName string) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("Design document '%s' not found in '%s'.",
+			designDocName, bucketName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "designdocument",
+		ResourceName: fmt.Sprintf("%s/%s", bucketName, designDocName),
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewViewMissingStatus(baseErr error, bucketName, designDocName, viewName string) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("View '%s' not found in '%s/%s'.",
+			viewName, bucketName, designDocName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "view",
+		ResourceName: fmt.Sprintf("%s/%s/%s", bucketName, designDocName, viewName),
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewViewNoAccessStatus(baseErr error, bucketName string) *status.Status {
+	st := status.New(codes.PermissionDenied,
+		fmt.Sprintf("No permissions to access views in '%s'.",
+			bucketName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: "",
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewReplicaMissingStatus(baseErr error, bucketName string, replicaIdx uint32) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("Replica %d is not configured for '%s'.",
+			replicaIdx, bucketName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "replica",
+		ResourceName: fmt.Sprintf("%s/%d", bucketName, replicaIdx),
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
 func (e ErrorHandler) NewDocMissingStatus(baseErr error, bucketName, scopeName, collectionName, docId string) *status.Status {
 	st := status.New(codes.NotFound,
 		fmt.Sprintf("Document '%s' not found in '%s/%s/%s'.",
@@ -402,6 +483,145 @@ func (e ErrorHandler) NewSdPathInvalidStatus(baseErr error, sdPath string) *stat
 	return st
 }
 
+func (e ErrorHandler) NewTransactionAttemptMissingStatus(transactionID, attemptID string) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("Transaction attempt '%s' for transaction '%s' was not found.",
+			attemptID, transactionID))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "transaction_attempt",
+		ResourceName: fmt.Sprintf("%s/%s", transactionID, attemptID),
+		Description:  "",
+	})
+	return st
+}
+
+func (e ErrorHandler) NewTransactionExpiredStatus(transactionID, attemptID string) *status.Status {
+	st := status.New(codes.DeadlineExceeded,
+		fmt.Sprintf("Transaction attempt '%s' for transaction '%s' has expired.",
+			attemptID, transactionID))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "transaction_attempt",
+		ResourceName: fmt.Sprintf("%s/%s", transactionID, attemptID),
+		Description:  "",
+	})
+	return st
+}
+
+func (e ErrorHandler) NewTransactionNotPendingStatus(transactionID, attemptID string) *status.Status {
+	st := status.New(codes.FailedPrecondition,
+		fmt.Sprintf("Transaction attempt '%s' for transaction '%s' has already been completed.",
+			attemptID, transactionID))
+	st = e.tryAttachStatusDetails(st, &epb.PreconditionFailure{
+		Violations: []*epb.PreconditionFailure_Violation{{
+			Type:        "TRANSACTION_NOT_PENDING",
+			Subject:     fmt.Sprintf("%s/%s", transactionID, attemptID),
+			Description: "",
+		}},
+	})
+	return st
+}
+
+func (e ErrorHandler) NewWriteWriteConflictStatus(baseErr error, bucketName, scopeName, collectionName, docId string) *status.Status {
+	st := status.New(codes.Aborted,
+		fmt.Sprintf("Document '%s' in '%s/%s/%s' is being modified by another transaction.",
+			docId, bucketName, scopeName, collectionName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "document",
+		ResourceName: fmt.Sprintf("%s/%s/%s/%s", bucketName, scopeName, collectionName, docId),
+		Description:  "",
+	})
+	if baseErr != nil {
+		st = e.tryAttachExtraContext(st, baseErr)
+	}
+	return st
+}
+
+func (e ErrorHandler) NewChangeFeedRollbackStatus(baseErr error, bucketName string, vbID uint16, rollbackSeqNo uint64) *status.Status {
+	st := status.New(codes.FailedPrecondition,
+		fmt.Sprintf("The resume token for vbucket %d in '%s' is no longer valid, the stream must be restarted from sequence number %d.",
+			vbID, bucketName, rollbackSeqNo))
+	st = e.tryAttachStatusDetails(st, &epb.PreconditionFailure{
+		Violations: []*epb.PreconditionFailure_Violation{{
+			Type:        "ROLLBACK",
+			Subject:     fmt.Sprintf("%s/%d", bucketName, vbID),
+			Description: strconv.FormatUint(rollbackSeqNo, 10),
+		}},
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewUserMissingStatus(baseErr error, username string) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("User '%s' not found.",
+			username))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: username,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewGroupMissingStatus(baseErr error, groupName string) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("Group '%s' not found.",
+			groupName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "group",
+		ResourceName: groupName,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewInvalidUserStatus(baseErr error, username string) *status.Status {
+	st := status.New(codes.InvalidArgument,
+		fmt.Sprintf("User '%s' was rejected by the server as invalid.",
+			username))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: username,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewInvalidGroupStatus(baseErr error, groupName string) *status.Status {
+	st := status.New(codes.InvalidArgument,
+		fmt.Sprintf("Group '%s' was rejected by the server as invalid.",
+			groupName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "group",
+		ResourceName: groupName,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewQueryIndexMissingStatus(bucketName, indexName string) *status.Status {
+	st := status.New(codes.NotFound,
+		fmt.Sprintf("Query index '%s' not found in '%s'.",
+			indexName, bucketName))
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "queryindex",
+		ResourceName: fmt.Sprintf("%s/%s", bucketName, indexName),
+		Description:  "",
+	})
+	return st
+}
+
+func (e ErrorHandler) NewQueryIndexesNotOnlineStatus(bucketName string, indexNames []string) *status.Status {
+	st := status.New(codes.DeadlineExceeded,
+		fmt.Sprintf("Query indexes in '%s' did not come online in time (waiting on: %s).",
+			bucketName, strings.Join(indexNames, ", ")))
+	return st
+}
+
 func (e ErrorHandler) NewUnsupportedFieldStatus(fieldPath string) *status.Status {
 	st := status.New(codes.Unimplemented,
 		fmt.Sprintf("The '%s' field is not currently supported", fieldPath))
@@ -448,6 +668,62 @@ func (e ErrorHandler) NewQueryNoAccessStatus(baseErr error) *status.Status {
 	return st
 }
 
+func (e ErrorHandler) NewInvalidAnalyticsQueryStatus(baseErr error, queryErrStr string) *status.Status {
+	st := status.New(codes.InvalidArgument,
+		fmt.Sprintf("Analytics query compilation failed: %s", queryErrStr))
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewAnalyticsNoAccessStatus(baseErr error) *status.Status {
+	st := status.New(codes.PermissionDenied,
+		"No permissions to perform analytics queries.")
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "user",
+		ResourceName: "",
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewDataverseMissingStatus(baseErr error, dataverseName string) *status.Status {
+	msg := "Analytics dataverse was not found."
+	if dataverseName != "" {
+		msg = fmt.Sprintf("Analytics dataverse '%s' was not found.", dataverseName)
+	}
+	st := status.New(codes.NotFound, msg)
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "dataverse",
+		ResourceName: dataverseName,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewDatasetMissingStatus(baseErr error, datasetName string) *status.Status {
+	msg := "Analytics dataset was not found."
+	if datasetName != "" {
+		msg = fmt.Sprintf("Analytics dataset '%s' was not found.", datasetName)
+	}
+	st := status.New(codes.NotFound, msg)
+	st = e.tryAttachStatusDetails(st, &epb.ResourceInfo{
+		ResourceType: "dataset",
+		ResourceName: datasetName,
+		Description:  "",
+	})
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
+func (e ErrorHandler) NewAnalyticsJobQueueFullStatus(baseErr error) *status.Status {
+	st := status.New(codes.ResourceExhausted,
+		"The analytics job queue is full.")
+	st = e.tryAttachExtraContext(st, baseErr)
+	return st
+}
+
 func (e ErrorHandler) NewNeedIndexFieldsStatus() *status.Status {
 	st := status.New(codes.InvalidArgument,
 		"You must specify fields when creating a new index.")
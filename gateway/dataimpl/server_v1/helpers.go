@@ -1,14 +1,18 @@
 package server_v1
 
 import (
+	"context"
 	"time"
 
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/gocbcorex/cbmgmtx"
+	"github.com/couchbase/gocbcorex/cbviewsx"
 	"github.com/couchbase/gocbcorex/memdx"
 	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
 	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_view_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/couchbase/stellar-gateway/gateway/topology"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -276,3 +280,44 @@ func indexStateFromQueryTableString(s string) (admin_query_v1.IndexState, *statu
 
 	return admin_query_v1.IndexState(0), status.New(codes.Internal, "invalid index state specified")
 }
+
+func designDocumentNamespaceToCbviewsx(ns admin_view_v1.DesignDocumentNamespace) (cbviewsx.DesignDocumentNamespace, *status.Status) {
+	switch ns {
+	case admin_view_v1.DesignDocumentNamespace_DESIGN_DOCUMENT_NAMESPACE_PRODUCTION:
+		return cbviewsx.DesignDocumentNamespaceProduction, nil
+	case admin_view_v1.DesignDocumentNamespace_DESIGN_DOCUMENT_NAMESPACE_DEVELOPMENT:
+		return cbviewsx.DesignDocumentNamespaceDevelopment, nil
+	}
+
+	return cbviewsx.DesignDocumentNamespace(0), status.New(codes.InvalidArgument, "invalid design document namespace specified")
+}
+
+// getVbucketRouting fetches the current vbucket routing for a bucket from the
+// topology the gateway is already tracking.
+func getVbucketRouting(
+	ctx context.Context,
+	topologyProvider topology.Provider,
+	errorHandler *ErrorHandler,
+	bucketName string,
+) (*topology.VbucketRouting, *status.Status) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	topologyCh, err := topologyProvider.Watch(watchCtx, bucketName)
+	if err != nil {
+		return nil, errorHandler.NewGenericStatus(err)
+	}
+
+	var topo *topology.Topology
+	select {
+	case topo = <-topologyCh:
+	case <-ctx.Done():
+		return nil, errorHandler.NewGenericStatus(ctx.Err())
+	}
+
+	if topo == nil || topo.VbucketRouting == nil {
+		return nil, status.New(codes.FailedPrecondition, "the bucket does not support vbucket routing")
+	}
+
+	return topo.VbucketRouting, nil
+}
@@ -0,0 +1,266 @@
+package server_v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kvMaxSubdocOps is the maximum number of operations the server will accept
+// in a single subdocument request.
+const kvMaxSubdocOps = 16
+
+type projectionPathPart struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// parseProjectionPath splits a subdocument path such as `a.b[2].c` into its
+// individual components.
+func parseProjectionPath(path string) ([]projectionPathPart, error) {
+	if path == "" {
+		return nil, errors.New("path cannot be empty")
+	}
+
+	var parts []projectionPathPart
+	var key []byte
+	inEscape := false
+	expectKey := true
+
+	flushKey := func() error {
+		if len(key) == 0 {
+			if expectKey {
+				return fmt.Errorf("path `%s` contains an empty field name", path)
+			}
+			return nil
+		}
+
+		parts = append(parts, projectionPathPart{Key: string(key)})
+		key = nil
+		expectKey = false
+		return nil
+	}
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+
+		if inEscape {
+			if c == '`' {
+				if i+1 < len(path) && path[i+1] == '`' {
+					key = append(key, '`')
+					i++
+				} else {
+					inEscape = false
+				}
+			} else {
+				key = append(key, c)
+			}
+			continue
+		}
+
+		switch c {
+		case '`':
+			inEscape = true
+		case '.':
+			if err := flushKey(); err != nil {
+				return nil, err
+			}
+			expectKey = true
+		case '[':
+			if err := flushKey(); err != nil {
+				return nil, err
+			}
+
+			closeIdx := strings.IndexByte(path[i:], ']')
+			if closeIdx < 0 {
+				return nil, fmt.Errorf("path `%s` contains an unterminated array index", path)
+			}
+
+			index, err := strconv.Atoi(path[i+1 : i+closeIdx])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("path `%s` contains an invalid array index", path)
+			}
+
+			parts = append(parts, projectionPathPart{Index: index, IsIndex: true})
+			i += closeIdx
+		default:
+			key = append(key, c)
+		}
+	}
+
+	if inEscape {
+		return nil, fmt.Errorf("path `%s` contains an unterminated escape", path)
+	}
+
+	if err := flushKey(); err != nil {
+		return nil, err
+	}
+
+	if parts[0].IsIndex {
+		return nil, fmt.Errorf("path `%s` cannot begin with an array index", path)
+	}
+
+	return parts, nil
+}
+
+// getProjectionValue walks a decoded JSON document to find the value at
+// the specified path.
+func getProjectionValue(doc interface{}, parts []projectionPathPart) (interface{}, bool) {
+	cur := doc
+	for _, part := range parts {
+		if part.IsIndex {
+			arr, ok := cur.([]interface{})
+			if !ok || part.Index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[part.Index]
+		} else {
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			val, ok := obj[part.Key]
+			if !ok {
+				return nil, false
+			}
+			cur = val
+		}
+	}
+
+	return cur, true
+}
+
+// projectionNode is a single object, array or leaf value within a projected
+// document.  Array elements are keyed by their index within the source document
+// so that multiple paths through the same element are merged together, and paths
+// through different elements are kept apart.
+type projectionNode struct {
+	Value    interface{}
+	IsLeaf   bool
+	IsArray  bool
+	Fields   map[string]*projectionNode
+	Elements map[int]*projectionNode
+}
+
+func newProjectionNode() *projectionNode {
+	return &projectionNode{}
+}
+
+func (n *projectionNode) field(key string) *projectionNode {
+	if n.Fields == nil {
+		n.Fields = make(map[string]*projectionNode)
+	}
+
+	child, ok := n.Fields[key]
+	if !ok {
+		child = newProjectionNode()
+		n.Fields[key] = child
+	}
+	return child
+}
+
+func (n *projectionNode) element(index int) *projectionNode {
+	if n.Elements == nil {
+		n.Elements = make(map[int]*projectionNode)
+	}
+
+	child, ok := n.Elements[index]
+	if !ok {
+		child = newProjectionNode()
+		n.Elements[index] = child
+	}
+	return child
+}
+
+// Build produces the JSON-encodable value of the projected node.  Projected array
+// elements keep the relative order they had in the source array, but are packed
+// together rather than keeping their original positions.
+func (n *projectionNode) Build() interface{} {
+	if n.IsLeaf {
+		return n.Value
+	}
+
+	if n.IsArray {
+		indexes := make([]int, 0, len(n.Elements))
+		for index := range n.Elements {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+
+		arr := make([]interface{}, len(indexes))
+		for arrIdx, index := range indexes {
+			arr[arrIdx] = n.Elements[index].Build()
+		}
+		return arr
+	}
+
+	obj := make(map[string]interface{}, len(n.Fields))
+	for key, child := range n.Fields {
+		obj[key] = child.Build()
+	}
+	return obj
+}
+
+// setProjectionValue writes a value into a projected document, creating any
+// objects or arrays along the path which do not yet exist.
+func setProjectionValue(root *projectionNode, parts []projectionPathPart, value interface{}) {
+	cur := root
+	for _, part := range parts {
+		if cur.IsLeaf {
+			// a parent of this path has already been projected in full, so
+			// the value is already part of the projected document.
+			return
+		}
+
+		if part.IsIndex {
+			cur.IsArray = true
+			cur = cur.element(part.Index)
+		} else {
+			cur = cur.field(part.Key)
+		}
+	}
+
+	*cur = projectionNode{
+		Value:  value,
+		IsLeaf: true,
+	}
+}
+
+func decodeProjectionJson(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// projectDocument trims a full document down to only the fields specified by
+// the paths.  Paths which do not exist in the document are omitted.
+func projectDocument(docValue []byte, paths [][]projectionPathPart) ([]byte, error) {
+	doc, err := decodeProjectionJson(docValue)
+	if err != nil {
+		return nil, err
+	}
+
+	projected := newProjectionNode()
+	for _, parts := range paths {
+		value, ok := getProjectionValue(doc, parts)
+		if !ok {
+			continue
+		}
+
+		setProjectionValue(projected, parts, value)
+	}
+
+	return json.Marshal(projected.Build())
+}
@@ -0,0 +1,229 @@
+package server_v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseProjectionPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected []projectionPathPart
+		wantErr  bool
+	}{
+		{
+			name:     "SingleField",
+			path:     "a",
+			expected: []projectionPathPart{{Key: "a"}},
+		},
+		{
+			name:     "NestedFields",
+			path:     "a.b.c",
+			expected: []projectionPathPart{{Key: "a"}, {Key: "b"}, {Key: "c"}},
+		},
+		{
+			name: "ArrayIndexes",
+			path: "a[2].b[0][1]",
+			expected: []projectionPathPart{
+				{Key: "a"},
+				{Index: 2, IsIndex: true},
+				{Key: "b"},
+				{Index: 0, IsIndex: true},
+				{Index: 1, IsIndex: true},
+			},
+		},
+		{
+			name:     "EscapedField",
+			path:     "`a.b`.c",
+			expected: []projectionPathPart{{Key: "a.b"}, {Key: "c"}},
+		},
+		{
+			name:     "EscapedBacktick",
+			path:     "`a``b`",
+			expected: []projectionPathPart{{Key: "a`b"}},
+		},
+		{name: "Empty", path: "", wantErr: true},
+		{name: "EmptyField", path: "a..b", wantErr: true},
+		{name: "TrailingDot", path: "a.", wantErr: true},
+		{name: "LeadingIndex", path: "[0].a", wantErr: true},
+		{name: "UnterminatedIndex", path: "a[0", wantErr: true},
+		{name: "NegativeIndex", path: "a[-1]", wantErr: true},
+		{name: "InvalidIndex", path: "a[x]", wantErr: true},
+		{name: "UnterminatedEscape", path: "`a", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parts, err := parseProjectionPath(tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error parsing `%s`, got %+v", tc.path, parts)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse `%s`: %s", tc.path, err)
+			}
+			if !reflect.DeepEqual(parts, tc.expected) {
+				t.Fatalf("unexpected parts for `%s`: %+v", tc.path, parts)
+			}
+		})
+	}
+}
+
+func TestSetProjectionValue(t *testing.T) {
+	type projectionSet struct {
+		path  string
+		value interface{}
+	}
+
+	testCases := []struct {
+		name     string
+		sets     []projectionSet
+		expected string
+	}{
+		{
+			name:     "Field",
+			sets:     []projectionSet{{"a", "x"}},
+			expected: `{"a":"x"}`,
+		},
+		{
+			name:     "MergedObjects",
+			sets:     []projectionSet{{"a.b", 1}, {"a.c", 2}},
+			expected: `{"a":{"b":1,"c":2}}`,
+		},
+		{
+			name:     "DistinctArrayElements",
+			sets:     []projectionSet{{"a[0].x", 1}, {"a[1].x", 2}},
+			expected: `{"a":[{"x":1},{"x":2}]}`,
+		},
+		{
+			name:     "MergedArrayElement",
+			sets:     []projectionSet{{"a[1].x", 1}, {"a[1].y", 2}},
+			expected: `{"a":[{"x":1,"y":2}]}`,
+		},
+		{
+			name:     "ArrayElementsKeepSourceOrder",
+			sets:     []projectionSet{{"a[3]", "d"}, {"a[0]", "a"}, {"a[2].x", "c"}},
+			expected: `{"a":["a",{"x":"c"},"d"]}`,
+		},
+		{
+			name:     "NestedArrays",
+			sets:     []projectionSet{{"a[0][1]", 1}, {"a[1][0]", 2}, {"a[0][0]", 3}},
+			expected: `{"a":[[3,1],[2]]}`,
+		},
+		{
+			name:     "ParentThenChild",
+			sets:     []projectionSet{{"a", map[string]interface{}{"b": 1, "c": 2}}, {"a.b", 1}},
+			expected: `{"a":{"b":1,"c":2}}`,
+		},
+		{
+			name:     "ChildThenParent",
+			sets:     []projectionSet{{"a.b", 1}, {"a", map[string]interface{}{"b": 1, "c": 2}}},
+			expected: `{"a":{"b":1,"c":2}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			projected := newProjectionNode()
+			for _, set := range tc.sets {
+				parts, err := parseProjectionPath(set.path)
+				if err != nil {
+					t.Fatalf("failed to parse `%s`: %s", set.path, err)
+				}
+
+				setProjectionValue(projected, parts, set.value)
+			}
+
+			projectedBytes, err := json.Marshal(projected.Build())
+			if err != nil {
+				t.Fatalf("failed to marshal projection: %s", err)
+			}
+
+			if string(projectedBytes) != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, projectedBytes)
+			}
+		})
+	}
+}
+
+func TestProjectDocument(t *testing.T) {
+	doc := []byte(`{
+		"name": "test",
+		"num": 12345678901234567890,
+		"address": {"city": "x", "zip": "y"},
+		"tags": ["a", "b", "c"],
+		"items": [{"id": 1, "qty": 2}, {"id": 3, "qty": 4}]
+	}`)
+
+	testCases := []struct {
+		name     string
+		paths    []string
+		expected string
+	}{
+		{
+			name:     "Fields",
+			paths:    []string{"name", "address.city"},
+			expected: `{"address":{"city":"x"},"name":"test"}`,
+		},
+		{
+			name:     "LargeNumber",
+			paths:    []string{"num"},
+			expected: `{"num":12345678901234567890}`,
+		},
+		{
+			name:     "ArrayElements",
+			paths:    []string{"tags[2]", "tags[0]"},
+			expected: `{"tags":["a","c"]}`,
+		},
+		{
+			name:     "ArrayElementFields",
+			paths:    []string{"items[0].id", "items[1].id", "items[1].qty"},
+			expected: `{"items":[{"id":1},{"id":3,"qty":4}]}`,
+		},
+		{
+			name:     "MissingPaths",
+			paths:    []string{"missing", "tags[10]", "name.child", "address.city"},
+			expected: `{"address":{"city":"x"}}`,
+		},
+		{
+			name:     "NothingFound",
+			paths:    []string{"missing"},
+			expected: `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths := make([][]projectionPathPart, len(tc.paths))
+			for pathIdx, path := range tc.paths {
+				parts, err := parseProjectionPath(path)
+				if err != nil {
+					t.Fatalf("failed to parse `%s`: %s", path, err)
+				}
+				paths[pathIdx] = parts
+			}
+
+			projected, err := projectDocument(doc, paths)
+			if err != nil {
+				t.Fatalf("failed to project document: %s", err)
+			}
+
+			if string(projected) != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, projected)
+			}
+		})
+	}
+
+	t.Run("InvalidJson", func(t *testing.T) {
+		parts, _ := parseProjectionPath("a")
+		_, err := projectDocument([]byte(`{"a":`), [][]projectionPathPart{parts})
+		if err == nil {
+			t.Fatalf("expected an error projecting invalid json")
+		}
+	})
+}
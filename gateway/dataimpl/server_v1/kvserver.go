@@ -2,13 +2,17 @@ package server_v1
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/gocbcorex/memdx"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/couchbase/stellar-gateway/gateway/topology"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -17,29 +21,58 @@ import (
 type KvServer struct {
 	kv_v1.UnimplementedKvServiceServer
 
-	logger       *zap.Logger
-	errorHandler *ErrorHandler
-	authHandler  *AuthHandler
+	logger           *zap.Logger
+	errorHandler     *ErrorHandler
+	authHandler      *AuthHandler
+	topologyProvider topology.Provider
 }
 
 func NewKvServer(
 	logger *zap.Logger,
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
+	topologyProvider topology.Provider,
 ) *KvServer {
 	return &KvServer{
-		logger:       logger,
-		errorHandler: errorHandler,
-		authHandler:  authHandler,
+		logger:           logger,
+		errorHandler:     errorHandler,
+		authHandler:      authHandler,
+		topologyProvider: topologyProvider,
 	}
 }
 
+// translateGetError maps the errors which can occur when reading a document into
+// the appropriate status.  It is shared by all the various forms of Get.
+func (s *KvServer) translateGetError(err error, bucketName, scopeName, collectionName, key string) *status.Status {
+	if errors.Is(err, memdx.ErrDocLocked) {
+		return s.errorHandler.NewDocLockedStatus(err, bucketName, scopeName, collectionName, key)
+	} else if errors.Is(err, memdx.ErrDocNotFound) {
+		return s.errorHandler.NewDocMissingStatus(err, bucketName, scopeName, collectionName, key)
+	} else if errors.Is(err, memdx.ErrUnknownCollectionName) {
+		return s.errorHandler.NewCollectionMissingStatus(err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, memdx.ErrUnknownScopeName) {
+		return s.errorHandler.NewScopeMissingStatus(err, bucketName, scopeName)
+	} else if errors.Is(err, memdx.ErrAccessError) {
+		return s.errorHandler.NewCollectionNoReadAccessStatus(err, bucketName, scopeName, collectionName)
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
 func (s *KvServer) Get(ctx context.Context, in *kv_v1.GetRequest) (*kv_v1.GetResponse, error) {
 	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
 	if errSt != nil {
 		return nil, errSt.Err()
 	}
 
+	projectPaths := make([][]projectionPathPart, len(in.Project))
+	for pathIdx, path := range in.Project {
+		parts, err := parseProjectionPath(path)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid projection path: %s", err)
+		}
+		projectPaths[pathIdx] = parts
+	}
+
 	var opts gocbcorex.LookupInOptions
 	opts.OnBehalfOf = oboUser
 	opts.ScopeName = in.ScopeName
@@ -56,27 +89,31 @@ func (s *KvServer) Get(ctx context.Context, in *kv_v1.GetRequest) (*kv_v1.GetRes
 			Flags: memdx.SubdocOpFlagXattrPath,
 			Path:  []byte("$document.flags"),
 		},
-		{
+	}
+
+	// If the projection fits within a single subdocument request, we let the
+	// server do the work of fetching the individual fields.  Otherwise we
+	// fetch the whole document and trim it ourselves.
+	projectWithSubdoc := len(in.Project) > 0 && len(opts.Ops)+len(in.Project) <= kvMaxSubdocOps
+	if projectWithSubdoc {
+		for _, path := range in.Project {
+			opts.Ops = append(opts.Ops, memdx.LookupInOp{
+				Op:    memdx.LookupInOpTypeGet,
+				Flags: memdx.SubdocOpFlagNone,
+				Path:  []byte(path),
+			})
+		}
+	} else {
+		opts.Ops = append(opts.Ops, memdx.LookupInOp{
 			Op:    memdx.LookupInOpTypeGetDoc,
 			Flags: memdx.SubdocOpFlagNone,
 			Path:  nil,
-		},
+		})
 	}
 
 	result, err := bucketAgent.LookupIn(ctx, &opts)
 	if err != nil {
-		if errors.Is(err, memdx.ErrDocLocked) {
-			return nil, s.errorHandler.NewDocLockedStatus(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
-		} else if errors.Is(err, memdx.ErrDocNotFound) {
-			return nil, s.errorHandler.NewDocMissingStatus(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
-		} else if errors.Is(err, memdx.ErrUnknownCollectionName) {
-			return nil, s.errorHandler.NewCollectionMissingStatus(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
-		} else if errors.Is(err, memdx.ErrUnknownScopeName) {
-			return nil, s.errorHandler.NewScopeMissingStatus(err, in.BucketName, in.ScopeName).Err()
-		} else if errors.Is(err, memdx.ErrAccessError) {
-			return nil, s.errorHandler.NewCollectionNoReadAccessStatus(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
-		}
-		return nil, s.errorHandler.NewGenericStatus(err).Err()
+		return nil, s.translateGetError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
 	}
 
 	expiryTimeSecs, err := strconv.ParseInt(string(result.Ops[0].Value), 10, 64)
@@ -90,7 +127,45 @@ func (s *KvServer) Get(ctx context.Context, in *kv_v1.GetRequest) (*kv_v1.GetRes
 	}
 
 	expiryTime := time.Unix(expiryTimeSecs, 0)
-	docValue := result.Ops[2].Value
+
+	var docValue []byte
+	if projectWithSubdoc {
+		projected := newProjectionNode()
+		for pathIdx, op := range result.Ops[2:] {
+			if op.Err != nil {
+				if errors.Is(op.Err, memdx.ErrSubDocPathNotFound) || errors.Is(op.Err, memdx.ErrSubDocPathMismatch) {
+					// fields which do not exist are simply omitted from the projection
+					continue
+				} else if errors.Is(op.Err, memdx.ErrSubDocDocTooDeep) {
+					return nil, s.errorHandler.NewSdDocTooDeepStatus(op.Err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+				} else if errors.Is(op.Err, memdx.ErrSubDocNotJSON) {
+					return nil, s.errorHandler.NewSdDocNotJsonStatus(op.Err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+				} else if errors.Is(op.Err, memdx.ErrSubDocPathInvalid) {
+					return nil, s.errorHandler.NewSdPathInvalidStatus(op.Err, in.Project[pathIdx]).Err()
+				}
+				return nil, s.errorHandler.NewGenericStatus(op.Err).Err()
+			}
+
+			value, err := decodeProjectionJson(op.Value)
+			if err != nil {
+				return nil, s.errorHandler.NewGenericStatus(err).Err()
+			}
+
+			setProjectionValue(projected, projectPaths[pathIdx], value)
+		}
+
+		docValue, err = json.Marshal(projected.Build())
+		if err != nil {
+			return nil, s.errorHandler.NewGenericStatus(err).Err()
+		}
+	} else if len(in.Project) > 0 {
+		docValue, err = projectDocument(result.Ops[2].Value, projectPaths)
+		if err != nil {
+			return nil, s.errorHandler.NewSdDocNotJsonStatus(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+	} else {
+		docValue = result.Ops[2].Value
+	}
 
 	return &kv_v1.GetResponse{
 		Content:      docValue,
@@ -100,6 +175,144 @@ func (s *KvServer) Get(ctx context.Context, in *kv_v1.GetRequest) (*kv_v1.GetRes
 	}, nil
 }
 
+func (s *KvServer) GetReplica(ctx context.Context, in *kv_v1.GetReplicaRequest) (*kv_v1.GetReplicaResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	// replica index 0 refers to the active, replicas are numbered from 1.
+	if in.ReplicaIndex < 1 || in.ReplicaIndex > kvMaxReplicas {
+		return nil, status.Errorf(codes.InvalidArgument, "replica index must be between 1 and %d", kvMaxReplicas)
+	}
+
+	var opts gocbcorex.GetReplicaOptions
+	opts.OnBehalfOf = oboUser
+	opts.ScopeName = in.ScopeName
+	opts.CollectionName = in.CollectionName
+	opts.Key = []byte(in.Key)
+	opts.ReplicaIdx = in.ReplicaIndex
+
+	result, err := bucketAgent.GetReplica(ctx, &opts)
+	if err != nil {
+		if errors.Is(err, gocbcorex.ErrInvalidReplica) {
+			return nil, s.errorHandler.NewReplicaMissingStatus(err, in.BucketName, in.ReplicaIndex).Err()
+		}
+		return nil, s.translateGetError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return &kv_v1.GetReplicaResponse{
+		Content:      result.Value,
+		ContentFlags: result.Flags,
+		Cas:          result.Cas,
+	}, nil
+}
+
+// kvMaxReplicas is the maximum number of replicas a bucket can be configured with.
+const kvMaxReplicas = 3
+
+type kvReplicaReadResult struct {
+	ReplicaIdx uint32
+	Content    []byte
+	Flags      uint32
+	Cas        uint64
+	Err        error
+}
+
+func (s *KvServer) GetAllReplicas(in *kv_v1.GetAllReplicasRequest, out kv_v1.KvService_GetAllReplicasServer) error {
+	ctx := out.Context()
+
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	// we fan out to the active and every possible replica at once, replicas which
+	// are not configured for the bucket will simply report an invalid replica.
+	resultsCh := make(chan kvReplicaReadResult, kvMaxReplicas+1)
+
+	go func() {
+		result, err := bucketAgent.Get(ctx, &gocbcorex.GetOptions{
+			OnBehalfOf:     oboUser,
+			ScopeName:      in.ScopeName,
+			CollectionName: in.CollectionName,
+			Key:            []byte(in.Key),
+		})
+		if err != nil {
+			resultsCh <- kvReplicaReadResult{Err: err}
+			return
+		}
+
+		resultsCh <- kvReplicaReadResult{
+			Content: result.Value,
+			Flags:   result.Flags,
+			Cas:     result.Cas,
+		}
+	}()
+
+	for replicaIdx := uint32(1); replicaIdx <= kvMaxReplicas; replicaIdx++ {
+		go func(replicaIdx uint32) {
+			result, err := bucketAgent.GetReplica(ctx, &gocbcorex.GetReplicaOptions{
+				OnBehalfOf:     oboUser,
+				ScopeName:      in.ScopeName,
+				CollectionName: in.CollectionName,
+				Key:            []byte(in.Key),
+				ReplicaIdx:     replicaIdx,
+			})
+			if err != nil {
+				resultsCh <- kvReplicaReadResult{ReplicaIdx: replicaIdx, Err: err}
+				return
+			}
+
+			resultsCh <- kvReplicaReadResult{
+				ReplicaIdx: replicaIdx,
+				Content:    result.Value,
+				Flags:      result.Flags,
+				Cas:        result.Cas,
+			}
+		}(replicaIdx)
+	}
+
+	var firstErr error
+	numSent := 0
+	for i := 0; i < kvMaxReplicas+1; i++ {
+		result := <-resultsCh
+
+		if result.Err != nil {
+			if errors.Is(result.Err, gocbcorex.ErrInvalidReplica) {
+				continue
+			}
+
+			// we prefer to report errors from the active, as those are generally
+			// more meaningful than errors from the replicas.
+			if firstErr == nil || result.ReplicaIdx == 0 {
+				firstErr = result.Err
+			}
+			continue
+		}
+
+		err := out.Send(&kv_v1.GetAllReplicasResponse{
+			Content:      result.Content,
+			ContentFlags: result.Flags,
+			Cas:          result.Cas,
+			IsReplica:    result.ReplicaIdx != 0,
+		})
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
+		}
+
+		numSent++
+	}
+
+	// if we managed to read from any of the nodes, the individual errors are
+	// not surfaced to the client, otherwise we return the most relevant one.
+	if numSent == 0 && firstErr != nil {
+		return s.translateGetError(firstErr, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return nil
+}
+
 func (s *KvServer) GetAndTouch(ctx context.Context, in *kv_v1.GetAndTouchRequest) (*kv_v1.GetAndTouchResponse, error) {
 	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
 	if errSt != nil {
@@ -1029,3 +1242,411 @@ func (s *KvServer) MutateIn(ctx context.Context, in *kv_v1.MutateInRequest) (*kv
 		MutationToken: tokenFromGocbcorex(in.BucketName, result.MutationToken),
 	}, nil
 }
+
+// kvScanMaxPrefixKey is appended to a prefix to produce the end key of a prefix scan,
+// it is the largest valid utf8 character and thus sorts after every real key.  It is
+// also used as the end key of range scans which do not specify one.
+const kvScanMaxPrefixKey = "\xf4\x8f\xbf\xbf"
+
+// kvScanMinKey is the start key used for range scans which do not specify one, it
+// sorts before every real key.
+const kvScanMinKey = "\x00"
+
+func (s *KvServer) Scan(in *kv_v1.ScanRequest, out kv_v1.KvService_ScanServer) error {
+	ctx := out.Context()
+
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	vbRouting, errSt := getVbucketRouting(ctx, s.topologyProvider, s.errorHandler, in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	var createOpts gocbcorex.RangeScanCreateOptions
+	createOpts.OnBehalfOf = oboUser
+	createOpts.ScopeName = in.ScopeName
+	createOpts.CollectionName = in.CollectionName
+	createOpts.KeysOnly = in.IdsOnly
+
+	// sampleLimit is the total number of items to return for sampling scans,
+	// 0 indicates that there is no limit.
+	var sampleLimit uint64
+
+	// vbOrder is the order in which the vbuckets are scanned.  Sampling scans
+	// visit the vbuckets in a random order so that the sample is not biased
+	// towards the lower vbuckets.
+	vbOrder := make([]uint16, vbRouting.NumVbuckets)
+	for vbIdx := range vbOrder {
+		vbOrder[vbIdx] = uint16(vbIdx)
+	}
+
+	switch scanSpec := in.Scan.(type) {
+	case *kv_v1.ScanRequest_Range:
+		createOpts.Range = &memdx.RangeScanCreateRangeScanConfig{}
+		if scanSpec.Range.StartKey == "" {
+			createOpts.Range.Start = []byte(kvScanMinKey)
+		} else if scanSpec.Range.ExclusiveStart {
+			createOpts.Range.ExclStart = []byte(scanSpec.Range.StartKey)
+		} else {
+			createOpts.Range.Start = []byte(scanSpec.Range.StartKey)
+		}
+		if scanSpec.Range.EndKey == "" {
+			createOpts.Range.End = []byte(kvScanMaxPrefixKey)
+		} else if scanSpec.Range.ExclusiveEnd {
+			createOpts.Range.ExclEnd = []byte(scanSpec.Range.EndKey)
+		} else {
+			createOpts.Range.End = []byte(scanSpec.Range.EndKey)
+		}
+	case *kv_v1.ScanRequest_Prefix:
+		createOpts.Range = &memdx.RangeScanCreateRangeScanConfig{
+			Start: []byte(scanSpec.Prefix.Prefix),
+			End:   []byte(scanSpec.Prefix.Prefix + kvScanMaxPrefixKey),
+		}
+	case *kv_v1.ScanRequest_Sampling:
+		if scanSpec.Sampling.Limit == 0 {
+			return status.Errorf(codes.InvalidArgument, "sampling scan limit must be greater than 0")
+		}
+
+		seed := rand.Uint64()
+		if scanSpec.Sampling.Seed != nil {
+			seed = *scanSpec.Sampling.Seed
+		}
+
+		createOpts.Sampling = &memdx.RangeScanCreateRandomSamplingConfig{
+			Seed: seed,
+		}
+		sampleLimit = scanSpec.Sampling.Limit
+
+		vbRand := rand.New(rand.NewSource(int64(seed)))
+		vbRand.Shuffle(len(vbOrder), func(i, j int) {
+			vbOrder[i], vbOrder[j] = vbOrder[j], vbOrder[i]
+		})
+	default:
+		return status.Errorf(codes.InvalidArgument, "a scan type must be specified")
+	}
+
+	batchItemLimit := uint32(kvScanDefaultBatchItemLimit)
+	if in.BatchItemLimit != nil {
+		if *in.BatchItemLimit == 0 {
+			return status.Errorf(codes.InvalidArgument, "batch item limit must be greater than 0")
+		}
+		batchItemLimit = *in.BatchItemLimit
+	}
+
+	var itemCache []*kv_v1.ScanResponse_Item
+	var numItemsSent uint64
+
+	flushItems := func() error {
+		// sampling scans run against every vbucket, so we trim anything past
+		// the requested limit before it is sent to the client.
+		if sampleLimit > 0 && numItemsSent+uint64(len(itemCache)) > sampleLimit {
+			itemCache = itemCache[:sampleLimit-numItemsSent]
+		}
+
+		if len(itemCache) == 0 {
+			return nil
+		}
+
+		err := out.Send(&kv_v1.ScanResponse{
+			Items: itemCache,
+		})
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
+		}
+
+		numItemsSent += uint64(len(itemCache))
+		itemCache = nil
+		return nil
+	}
+
+	for vbIdx, vbID := range vbOrder {
+		if sampleLimit > 0 {
+			numItemsFound := numItemsSent + uint64(len(itemCache))
+			if numItemsFound >= sampleLimit {
+				break
+			}
+
+			// the remaining samples are spread evenly across the remaining
+			// vbuckets, so that no single vbucket can fill the whole sample.
+			numVbsLeft := uint64(len(vbOrder) - vbIdx)
+			createOpts.Sampling.Samples = (sampleLimit - numItemsFound + numVbsLeft - 1) / numVbsLeft
+		}
+
+		createOpts.VbucketID = vbID
+		createRes, err := bucketAgent.RangeScanCreate(ctx, &createOpts)
+		if err != nil {
+			if errors.Is(err, memdx.ErrRangeScanEmpty) {
+				continue
+			}
+
+			return s.translateScanError(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
+		}
+
+		for {
+			var itemsErr error
+			continueRes, err := bucketAgent.RangeScanContinue(ctx, &gocbcorex.RangeScanContinueOptions{
+				OnBehalfOf: oboUser,
+				ScanUUID:   createRes.ScanUUID,
+				VbucketID:  vbID,
+				MaxCount:   batchItemLimit,
+			}, func(data memdx.RangeScanDataResponse) {
+				for _, item := range data.Items {
+					psItem := &kv_v1.ScanResponse_Item{
+						Key: string(item.Key),
+					}
+
+					if !data.KeysOnly {
+						psItem.Content = item.Value
+						psItem.ContentFlags = item.Flags
+						psItem.Cas = item.Cas
+						if item.Expiry != 0 {
+							psItem.Expiry = timeFromGo(time.Unix(int64(item.Expiry), 0))
+						}
+					}
+
+					itemCache = append(itemCache, psItem)
+
+					if uint32(len(itemCache)) >= batchItemLimit && itemsErr == nil {
+						itemsErr = flushItems()
+					}
+				}
+			})
+			if err != nil {
+				// the scan may still be open on the server if the continue failed
+				// partway through, so it is always cancelled before returning.
+				s.cancelScan(bucketAgent, oboUser, createRes.ScanUUID, vbID)
+				return s.translateScanError(err, in.BucketName, in.ScopeName, in.CollectionName).Err()
+			}
+			if itemsErr != nil {
+				s.cancelScan(bucketAgent, oboUser, createRes.ScanUUID, vbID)
+				return itemsErr
+			}
+
+			if continueRes.Complete || !continueRes.More {
+				break
+			}
+
+			if sampleLimit > 0 && numItemsSent+uint64(len(itemCache)) >= sampleLimit {
+				s.cancelScan(bucketAgent, oboUser, createRes.ScanUUID, vbID)
+				break
+			}
+		}
+	}
+
+	return flushItems()
+}
+
+// kvScanDefaultBatchItemLimit is the number of items we batch into each
+// response when the client does not specify a limit.
+const kvScanDefaultBatchItemLimit = 50
+
+// kvScanCancelTimeout is how long we allow for cancelling a range scan.
+const kvScanCancelTimeout = 5 * time.Second
+
+// cancelScan cancels an in-progress range scan.  This is frequently called after
+// the client has gone away, so it does not use the context of the stream.
+func (s *KvServer) cancelScan(
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	scanUUID []byte,
+	vbID uint16,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvScanCancelTimeout)
+	defer cancel()
+
+	_, err := bucketAgent.RangeScanCancel(ctx, &gocbcorex.RangeScanCancelOptions{
+		OnBehalfOf: oboUser,
+		ScanUUID:   scanUUID,
+		VbucketID:  vbID,
+	})
+	if err != nil {
+		s.logger.Debug("failed to cancel range scan", zap.Error(err), zap.Uint16("vbId", vbID))
+	}
+}
+
+func (s *KvServer) translateScanError(err error, bucketName, scopeName, collectionName string) *status.Status {
+	if errors.Is(err, memdx.ErrUnknownCollectionName) {
+		return s.errorHandler.NewCollectionMissingStatus(err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, memdx.ErrUnknownScopeName) {
+		return s.errorHandler.NewScopeMissingStatus(err, bucketName, scopeName)
+	} else if errors.Is(err, memdx.ErrAccessError) {
+		return s.errorHandler.NewCollectionNoReadAccessStatus(err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, memdx.ErrNotSupported) {
+		return status.New(codes.Unimplemented, "Range scans are not supported by this cluster.")
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
+const (
+	// kvMultiMaxConcurrency is the maximum number of operations from a single
+	// batch which we will have in flight against the bucket agent at once.
+	kvMultiMaxConcurrency = 64
+
+	// kvMultiMaxItems is the maximum number of keys or items which may be
+	// specified in a single GetMulti or UpsertMulti request.
+	kvMultiMaxItems = 1000
+
+	// kvMultiMaxResponseBytes is the most document content which GetMulti will
+	// return in one response.  This keeps responses below the default 4MiB gRPC
+	// client receive limit, items beyond it fail individually instead.
+	kvMultiMaxResponseBytes = 3 * 1024 * 1024
+)
+
+// runMulti invokes fn for each index in [0, numItems) with a bounded level of
+// concurrency, returning once all the invocations have completed.  No further
+// invocations are started once ctx is done, and those indexes are skipped.
+func runMulti(ctx context.Context, numItems int, fn func(itemIdx int)) {
+	sem := make(chan struct{}, kvMultiMaxConcurrency)
+	var wg sync.WaitGroup
+
+	for itemIdx := 0; itemIdx < numItems; itemIdx++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+
+		go func(itemIdx int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fn(itemIdx)
+		}(itemIdx)
+	}
+
+	wg.Wait()
+}
+
+func (s *KvServer) GetMulti(ctx context.Context, in *kv_v1.GetMultiRequest) (*kv_v1.GetMultiResponse, error) {
+	if len(in.Keys) > kvMultiMaxItems {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys may be fetched in a single request", kvMultiMaxItems)
+	}
+
+	// we validate access to the bucket up-front so that requests which would
+	// fail in their entirety don't produce a status for every single item.
+	_, _, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	items := make([]*kv_v1.GetMultiResponse_Item, len(in.Keys))
+	runMulti(ctx, len(in.Keys), func(itemIdx int) {
+		key := in.Keys[itemIdx]
+
+		resp, err := s.Get(ctx, &kv_v1.GetRequest{
+			BucketName:     in.BucketName,
+			ScopeName:      in.ScopeName,
+			CollectionName: in.CollectionName,
+			Key:            key,
+		})
+		if err != nil {
+			items[itemIdx] = &kv_v1.GetMultiResponse_Item{
+				Key:    key,
+				Status: status.Convert(err).Proto(),
+			}
+			return
+		}
+
+		items[itemIdx] = &kv_v1.GetMultiResponse_Item{
+			Key:          key,
+			Content:      resp.Content,
+			ContentFlags: resp.ContentFlags,
+			Cas:          resp.Cas,
+			Expiry:       resp.Expiry,
+		}
+	})
+
+	contentBytes := 0
+	for itemIdx, item := range items {
+		if item == nil {
+			items[itemIdx] = &kv_v1.GetMultiResponse_Item{
+				Key:    in.Keys[itemIdx],
+				Status: status.FromContextError(ctx.Err()).Proto(),
+			}
+			continue
+		}
+
+		if item.Status != nil {
+			continue
+		}
+
+		contentBytes += len(item.Content)
+		if contentBytes > kvMultiMaxResponseBytes {
+			items[itemIdx] = &kv_v1.GetMultiResponse_Item{
+				Key: item.Key,
+				Status: status.New(codes.ResourceExhausted,
+					"The response size limit was reached, this document must be fetched separately.").Proto(),
+			}
+		}
+	}
+
+	return &kv_v1.GetMultiResponse{
+		Items: items,
+	}, nil
+}
+
+func (s *KvServer) UpsertMulti(ctx context.Context, in *kv_v1.UpsertMultiRequest) (*kv_v1.UpsertMultiResponse, error) {
+	if len(in.Items) > kvMultiMaxItems {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d items may be upserted in a single request", kvMultiMaxItems)
+	}
+
+	_, _, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	items := make([]*kv_v1.UpsertMultiResponse_Item, len(in.Items))
+	runMulti(ctx, len(in.Items), func(itemIdx int) {
+		item := in.Items[itemIdx]
+
+		upsertReq := &kv_v1.UpsertRequest{
+			BucketName:      in.BucketName,
+			ScopeName:       in.ScopeName,
+			CollectionName:  in.CollectionName,
+			Key:             item.Key,
+			Content:         item.Content,
+			ContentFlags:    item.ContentFlags,
+			DurabilityLevel: in.DurabilityLevel,
+		}
+		if item.ExpirySecs != nil {
+			upsertReq.Expiry = &kv_v1.UpsertRequest_ExpirySecs{
+				ExpirySecs: *item.ExpirySecs,
+			}
+		}
+
+		resp, err := s.Upsert(ctx, upsertReq)
+		if err != nil {
+			items[itemIdx] = &kv_v1.UpsertMultiResponse_Item{
+				Key:    item.Key,
+				Status: status.Convert(err).Proto(),
+			}
+			return
+		}
+
+		items[itemIdx] = &kv_v1.UpsertMultiResponse_Item{
+			Key:           item.Key,
+			Cas:           resp.Cas,
+			MutationToken: resp.MutationToken,
+		}
+	})
+
+	for itemIdx, item := range items {
+		if item == nil {
+			items[itemIdx] = &kv_v1.UpsertMultiResponse_Item{
+				Key:    in.Items[itemIdx].Key,
+				Status: status.FromContextError(ctx.Err()).Proto(),
+			}
+		}
+	}
+
+	return &kv_v1.UpsertMultiResponse{
+		Items: items,
+	}, nil
+}
@@ -3,11 +3,15 @@ package server_v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	backoff "github.com/cenkalti/backoff/v4"
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
 	"google.golang.org/grpc/status"
 
 	"github.com/couchbase/gocbcorex"
@@ -266,10 +270,20 @@ func (s *QueryIndexAdminServer) CreateIndex(
 
 	encodedFields := make([]string, len(in.Fields))
 	for fieldIdx, field := range in.Fields {
-		encodedFields[fieldIdx] = cbqueryx.EncodeIdentifier(field)
+		if in.GetRawFields() {
+			// raw fields are index key expressions, such as those returned by
+			// GetAllIndexes or AdviseIndexes, and are used as-is.
+			encodedFields[fieldIdx] = field
+		} else {
+			encodedFields[fieldIdx] = cbqueryx.EncodeIdentifier(field)
+		}
 	}
 	qs += " (" + strings.Join(encodedFields, ",") + ")"
 
+	if in.Condition != nil {
+		qs += " WHERE " + *in.Condition
+	}
+
 	with := make(map[string]interface{})
 
 	if in.Deferred != nil {
@@ -440,3 +454,245 @@ func (s *QueryIndexAdminServer) BuildDeferredIndexes(
 
 	return &admin_query_v1.BuildDeferredIndexesResponse{}, nil
 }
+
+const (
+	// queryIndexPollInitialInterval and queryIndexPollMaxInterval bound how frequently
+	// we poll the index states when waiting for, or watching, index state transitions.
+	// Polling starts quickly and backs off exponentially while nothing is changing.
+	queryIndexPollInitialInterval = 100 * time.Millisecond
+	queryIndexPollMaxInterval     = 5 * time.Second
+
+	// queryIndexDefaultWaitTimeout is how long WaitForIndexesOnline waits when the
+	// request specifies neither a timeout nor a deadline.
+	queryIndexDefaultWaitTimeout = 5 * time.Minute
+
+	// queryIndexDefaultWatchTimeout is how long WatchIndexes streams changes when the
+	// call has no deadline, after which the stream ends and the client may re-watch.
+	queryIndexDefaultWatchTimeout = 30 * time.Minute
+)
+
+func newQueryIndexPollBackoff() *backoff.ExponentialBackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = queryIndexPollInitialInterval
+	b.MaxInterval = queryIndexPollMaxInterval
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return b
+}
+
+// queryIndexKey identifies an index within a bucket.  Index names are only
+// unique within a collection, so a bucket-level request can see several indexes
+// with the same name.
+type queryIndexKey struct {
+	ScopeName      string
+	CollectionName string
+	Name           string
+}
+
+func sortQueryIndexKeys(keys []queryIndexKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ScopeName != keys[j].ScopeName {
+			return keys[i].ScopeName < keys[j].ScopeName
+		}
+		if keys[i].CollectionName != keys[j].CollectionName {
+			return keys[i].CollectionName < keys[j].CollectionName
+		}
+		return keys[i].Name < keys[j].Name
+	})
+}
+
+// getIndexStates fetches the current state of every index in the specified
+// keyspace, keyed by the scope, collection and name of the index.
+func (s *QueryIndexAdminServer) getIndexStates(
+	ctx context.Context,
+	bucketName string,
+	scopeName, collectionName *string,
+) (map[queryIndexKey]admin_query_v1.IndexState, error) {
+	getIndexesResp, err := s.GetAllIndexes(ctx, &admin_query_v1.GetAllIndexesRequest{
+		BucketName:     &bucketName,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[queryIndexKey]admin_query_v1.IndexState, len(getIndexesResp.Indexes))
+	for _, index := range getIndexesResp.Indexes {
+		states[queryIndexKey{
+			ScopeName:      index.ScopeName,
+			CollectionName: index.CollectionName,
+			Name:           index.Name,
+		}] = index.State
+	}
+
+	return states, nil
+}
+
+func (s *QueryIndexAdminServer) WaitForIndexesOnline(
+	ctx context.Context,
+	in *admin_query_v1.WaitForIndexesOnlineRequest,
+) (*admin_query_v1.WaitForIndexesOnlineResponse, error) {
+	if in.Timeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, durationToGo(in.Timeout))
+		defer cancel()
+	} else if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queryIndexDefaultWaitTimeout)
+		defer cancel()
+	}
+
+	pollBackoff := newQueryIndexPollBackoff()
+
+	var notOnlineIndexNames []string
+	for {
+		states, err := s.getIndexStates(ctx, in.BucketName, in.ScopeName, in.CollectionName)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && notOnlineIndexNames != nil {
+				return nil, s.errorHandler.NewQueryIndexesNotOnlineStatus(in.BucketName, notOnlineIndexNames).Err()
+			}
+			return nil, err
+		}
+
+		// an index name matches every index with that name in the keyspace, so for
+		// bucket-level requests an index must be online in every collection.  If no
+		// index names were specified, we wait for every index in the keyspace.
+		waitAll := len(in.IndexNames) == 0
+		foundNames := make(map[string]bool, len(in.IndexNames))
+		notOnlineNames := make(map[string]bool)
+		for key, state := range states {
+			if !waitAll && !slices.Contains(in.IndexNames, key.Name) {
+				continue
+			}
+
+			foundNames[key.Name] = true
+			if state != admin_query_v1.IndexState_INDEX_STATE_ONLINE {
+				notOnlineNames[key.Name] = true
+			}
+		}
+
+		for _, indexName := range in.IndexNames {
+			if !foundNames[indexName] {
+				return nil, s.errorHandler.NewQueryIndexMissingStatus(in.BucketName, indexName).Err()
+			}
+		}
+
+		notOnlineIndexNames = []string{}
+		for indexName := range notOnlineNames {
+			notOnlineIndexNames = append(notOnlineIndexNames, indexName)
+		}
+		sort.Strings(notOnlineIndexNames)
+
+		if len(notOnlineIndexNames) == 0 {
+			break
+		}
+
+		select {
+		case <-time.After(pollBackoff.NextBackOff()):
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, s.errorHandler.NewQueryIndexesNotOnlineStatus(in.BucketName, notOnlineIndexNames).Err()
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	return &admin_query_v1.WaitForIndexesOnlineResponse{}, nil
+}
+
+func (s *QueryIndexAdminServer) WatchIndexes(
+	in *admin_query_v1.WatchIndexesRequest,
+	out admin_query_v1.QueryAdminService_WatchIndexesServer,
+) error {
+	ctx := out.Context()
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queryIndexDefaultWatchTimeout)
+		defer cancel()
+	}
+
+	pollBackoff := newQueryIndexPollBackoff()
+
+	watchAll := len(in.IndexNames) == 0
+	watchedNames := make(map[string]bool, len(in.IndexNames))
+	for _, indexName := range in.IndexNames {
+		watchedNames[indexName] = true
+	}
+
+	// knownStates is nil until the first poll, which causes the initial states of
+	// all the watched indexes to be sent as the first message of the stream.
+	var knownStates map[queryIndexKey]admin_query_v1.IndexState
+
+	for {
+		states, err := s.getIndexStates(ctx, in.BucketName, in.ScopeName, in.CollectionName)
+		if err != nil {
+			return err
+		}
+
+		var changes []*admin_query_v1.WatchIndexesResponse_Change
+
+		var indexKeys []queryIndexKey
+		for key := range states {
+			if watchAll || watchedNames[key.Name] {
+				indexKeys = append(indexKeys, key)
+			}
+		}
+		sortQueryIndexKeys(indexKeys)
+
+		for _, key := range indexKeys {
+			state := states[key]
+			if oldState, ok := knownStates[key]; ok && oldState == state {
+				continue
+			}
+
+			changes = append(changes, &admin_query_v1.WatchIndexesResponse_Change{
+				ScopeName:      key.ScopeName,
+				CollectionName: key.CollectionName,
+				Name:           key.Name,
+				State:          state,
+			})
+		}
+
+		droppedKeys := []queryIndexKey{}
+		for key := range knownStates {
+			if _, ok := states[key]; !ok {
+				droppedKeys = append(droppedKeys, key)
+			}
+		}
+		sortQueryIndexKeys(droppedKeys)
+
+		for _, key := range droppedKeys {
+			changes = append(changes, &admin_query_v1.WatchIndexesResponse_Change{
+				ScopeName:      key.ScopeName,
+				CollectionName: key.CollectionName,
+				Name:           key.Name,
+				Dropped:        true,
+			})
+		}
+
+		if len(changes) > 0 || knownStates == nil {
+			err := out.Send(&admin_query_v1.WatchIndexesResponse{
+				Changes: changes,
+			})
+			if err != nil {
+				return s.errorHandler.NewGenericStatus(err).Err()
+			}
+
+			// indexes which are changing are likely to change again soon, so we
+			// go back to polling quickly.
+			pollBackoff.Reset()
+		}
+
+		knownStates = make(map[queryIndexKey]admin_query_v1.IndexState, len(indexKeys))
+		for _, key := range indexKeys {
+			knownStates[key] = states[key]
+		}
+
+		select {
+		case <-time.After(pollBackoff.NextBackOff()):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
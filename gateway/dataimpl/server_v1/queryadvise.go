@@ -0,0 +1,250 @@
+package server_v1
+
+import (
+	"errors"
+	"strings"
+)
+
+// advisedIndex is the structured form of a CREATE INDEX statement which has
+// been recommended by the query index advisor.
+type advisedIndex struct {
+	Name           string
+	IsPrimary      bool
+	BucketName     string
+	ScopeName      string
+	CollectionName string
+	Fields         []string
+	Condition      string
+}
+
+// adviseStatementParser is a minimal scanner for the CREATE INDEX statements
+// which the query service emits from ADVISE.  It is not a general purpose N1QL
+// parser and only understands the shape of statements that ADVISE produces.
+type adviseStatementParser struct {
+	stmt string
+	pos  int
+}
+
+func (p *adviseStatementParser) skipSpace() {
+	for p.pos < len(p.stmt) && isAdviseSpace(p.stmt[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *adviseStatementParser) peekKeyword(keyword string) bool {
+	p.skipSpace()
+	end := p.pos + len(keyword)
+	if end > len(p.stmt) || !strings.EqualFold(p.stmt[p.pos:end], keyword) {
+		return false
+	}
+	return end == len(p.stmt) || !isAdviseIdentChar(p.stmt[end])
+}
+
+func (p *adviseStatementParser) readKeyword(keyword string) bool {
+	if !p.peekKeyword(keyword) {
+		return false
+	}
+	p.pos += len(keyword)
+	return true
+}
+
+func (p *adviseStatementParser) readIdentifier() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.stmt) {
+		return "", errors.New("unexpected end of statement")
+	}
+
+	if p.stmt[p.pos] == '`' {
+		var ident strings.Builder
+		p.pos++
+		for p.pos < len(p.stmt) {
+			c := p.stmt[p.pos]
+			p.pos++
+			if c == '`' {
+				// a doubled backtick is an escaped backtick within the identifier
+				if p.pos < len(p.stmt) && p.stmt[p.pos] == '`' {
+					ident.WriteByte('`')
+					p.pos++
+					continue
+				}
+				return ident.String(), nil
+			}
+			ident.WriteByte(c)
+		}
+		return "", errors.New("unterminated escaped identifier")
+	}
+
+	start := p.pos
+	for p.pos < len(p.stmt) && isAdviseIdentChar(p.stmt[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", errors.New("expected identifier")
+	}
+	return p.stmt[start:p.pos], nil
+}
+
+// readKeyspace reads a keyspace path, also reporting whether it was prefixed
+// with a namespace.  Paths with a namespace are never relative to the query
+// context.
+func (p *adviseStatementParser) readKeyspace() ([]string, bool, error) {
+	var parts []string
+	hasNamespace := false
+	for {
+		part, err := p.readIdentifier()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if p.pos < len(p.stmt) && p.stmt[p.pos] == ':' {
+			// namespace prefixes (default:) are not part of the keyspace path
+			p.pos++
+			hasNamespace = true
+			continue
+		}
+
+		parts = append(parts, part)
+
+		if p.pos < len(p.stmt) && p.stmt[p.pos] == '.' {
+			p.pos++
+			continue
+		}
+
+		return parts, hasNamespace, nil
+	}
+}
+
+// readIndexKeys reads the parenthesized list of index keys, splitting it on
+// any commas which are not nested within brackets or escaped identifiers.
+func (p *adviseStatementParser) readIndexKeys() ([]string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.stmt) || p.stmt[p.pos] != '(' {
+		return nil, errors.New("expected index key list")
+	}
+	p.pos++
+
+	var keys []string
+	depth := 0
+	inEscape := false
+	inString := byte(0)
+	start := p.pos
+	for ; p.pos < len(p.stmt); p.pos++ {
+		c := p.stmt[p.pos]
+		switch {
+		case inEscape:
+			if c == '`' {
+				inEscape = false
+			}
+		case inString != 0:
+			if c == inString {
+				inString = 0
+			}
+		case c == '`':
+			inEscape = true
+		case c == '"' || c == '\'':
+			inString = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			if depth == 0 {
+				keys = append(keys, strings.TrimSpace(p.stmt[start:p.pos]))
+				p.pos++
+				return keys, nil
+			}
+			depth--
+		case c == ',' && depth == 0:
+			keys = append(keys, strings.TrimSpace(p.stmt[start:p.pos]))
+			start = p.pos + 1
+		}
+	}
+
+	return nil, errors.New("unterminated index key list")
+}
+
+// parseAdvisedIndexStatement parses a CREATE INDEX or CREATE PRIMARY INDEX
+// statement produced by ADVISE into its component parts.  Index keys and the
+// condition are returned verbatim as N1QL expressions, matching the form that
+// GetAllIndexes returns and that CreateIndex accepts with RawFields set.
+//
+// When ADVISE is run with a scope query context, the advisor emits keyspaces
+// relative to that scope, so a single part keyspace names a collection within
+// the bucket and scope passed here rather than a bucket.
+func parseAdvisedIndexStatement(stmt string, bucketName, scopeName string) (*advisedIndex, error) {
+	p := &adviseStatementParser{stmt: stmt}
+	idx := &advisedIndex{}
+
+	if !p.readKeyword("CREATE") {
+		return nil, errors.New("expected CREATE")
+	}
+	if p.readKeyword("PRIMARY") {
+		idx.IsPrimary = true
+	}
+	if !p.readKeyword("INDEX") {
+		return nil, errors.New("expected INDEX")
+	}
+
+	if !p.peekKeyword("ON") {
+		name, err := p.readIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		idx.Name = name
+	}
+
+	if !p.readKeyword("ON") {
+		return nil, errors.New("expected ON")
+	}
+
+	keyspace, hasNamespace, err := p.readKeyspace()
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(keyspace) {
+	case 1:
+		if !hasNamespace && bucketName != "" && scopeName != "" {
+			idx.BucketName = bucketName
+			idx.ScopeName = scopeName
+			idx.CollectionName = keyspace[0]
+			break
+		}
+		idx.BucketName = keyspace[0]
+	case 3:
+		idx.BucketName = keyspace[0]
+		idx.ScopeName = keyspace[1]
+		idx.CollectionName = keyspace[2]
+	default:
+		return nil, errors.New("unexpected keyspace path")
+	}
+
+	if !idx.IsPrimary {
+		keys, err := p.readIndexKeys()
+		if err != nil {
+			return nil, err
+		}
+
+		idx.Fields = keys
+	}
+
+	if p.readKeyword("WHERE") {
+		p.skipSpace()
+		cond := p.stmt[p.pos:]
+		if usingIdx := strings.LastIndex(strings.ToUpper(cond), " USING "); usingIdx >= 0 {
+			cond = cond[:usingIdx]
+		}
+		idx.Condition = strings.TrimSpace(cond)
+	}
+
+	return idx, nil
+}
+
+func isAdviseSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isAdviseIdentChar(c byte) bool {
+	return c == '_' || c == '$' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
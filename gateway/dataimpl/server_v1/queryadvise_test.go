@@ -0,0 +1,191 @@
+package server_v1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAdvisedIndexStatement(t *testing.T) {
+	testCases := []struct {
+		name       string
+		stmt       string
+		bucketName string
+		scopeName  string
+		expected   *advisedIndex
+		wantErr    bool
+	}{
+		{
+			name: "BucketIndex",
+			stmt: "CREATE INDEX adv_type ON `travel-sample`(`type`)",
+			expected: &advisedIndex{
+				Name:       "adv_type",
+				BucketName: "travel-sample",
+				Fields:     []string{"`type`"},
+			},
+		},
+		{
+			name: "CollectionIndex",
+			stmt: "CREATE INDEX adv_city ON `default`:`travel-sample`.`inventory`.`hotel`(`city`)",
+			expected: &advisedIndex{
+				Name:           "adv_city",
+				BucketName:     "travel-sample",
+				ScopeName:      "inventory",
+				CollectionName: "hotel",
+				Fields:         []string{"`city`"},
+			},
+		},
+		{
+			name: "EscapedIndexName",
+			stmt: "CREATE INDEX `adv``name` ON `b`(`a`)",
+			expected: &advisedIndex{
+				Name:       "adv`name",
+				BucketName: "b",
+				Fields:     []string{"`a`"},
+			},
+		},
+		{
+			name: "ExpressionKeys",
+			stmt: "CREATE INDEX adv_expr ON `b`.`s`.`c`(`a`.`b`, lower(`name`), " +
+				"DISTINCT ARRAY `v`.`x` FOR v IN `items` END, `c` DESC)",
+			expected: &advisedIndex{
+				Name:           "adv_expr",
+				BucketName:     "b",
+				ScopeName:      "s",
+				CollectionName: "c",
+				Fields: []string{
+					"`a`.`b`",
+					"lower(`name`)",
+					"DISTINCT ARRAY `v`.`x` FOR v IN `items` END",
+					"`c` DESC",
+				},
+			},
+		},
+		{
+			name: "NestedCommas",
+			stmt: "CREATE INDEX adv_nested ON `b`(substr(`a`, 0, 2), `x,y`, `c`[0], \"),(\" || `d`)",
+			expected: &advisedIndex{
+				Name:       "adv_nested",
+				BucketName: "b",
+				Fields: []string{
+					"substr(`a`, 0, 2)",
+					"`x,y`",
+					"`c`[0]",
+					"\"),(\" || `d`",
+				},
+			},
+		},
+		{
+			name: "Condition",
+			stmt: "CREATE INDEX adv_cond ON `b`(`a`) WHERE `type` = 'hotel' AND `a` > 1",
+			expected: &advisedIndex{
+				Name:       "adv_cond",
+				BucketName: "b",
+				Fields:     []string{"`a`"},
+				Condition:  "`type` = 'hotel' AND `a` > 1",
+			},
+		},
+		{
+			name: "ConditionWithUsing",
+			stmt: "CREATE INDEX adv_cond ON `b`(`a`) WHERE (`a` > 1) USING GSI",
+			expected: &advisedIndex{
+				Name:       "adv_cond",
+				BucketName: "b",
+				Fields:     []string{"`a`"},
+				Condition:  "(`a` > 1)",
+			},
+		},
+		{
+			name:       "ScopeContextCollection",
+			stmt:       "CREATE INDEX adv_city ON `hotel`(`city`)",
+			bucketName: "travel-sample",
+			scopeName:  "inventory",
+			expected: &advisedIndex{
+				Name:           "adv_city",
+				BucketName:     "travel-sample",
+				ScopeName:      "inventory",
+				CollectionName: "hotel",
+				Fields:         []string{"`city`"},
+			},
+		},
+		{
+			name:       "ScopeContextFullPath",
+			stmt:       "CREATE INDEX adv_city ON `b`.`s`.`c`(`city`)",
+			bucketName: "travel-sample",
+			scopeName:  "inventory",
+			expected: &advisedIndex{
+				Name:           "adv_city",
+				BucketName:     "b",
+				ScopeName:      "s",
+				CollectionName: "c",
+				Fields:         []string{"`city`"},
+			},
+		},
+		{
+			name:       "ScopeContextNamespacedBucket",
+			stmt:       "CREATE INDEX adv_city ON `default`:`b`(`city`)",
+			bucketName: "travel-sample",
+			scopeName:  "inventory",
+			expected: &advisedIndex{
+				Name:       "adv_city",
+				BucketName: "b",
+				Fields:     []string{"`city`"},
+			},
+		},
+		{
+			name:       "BucketOnlyContext",
+			stmt:       "CREATE INDEX adv_city ON `b`(`city`)",
+			bucketName: "travel-sample",
+			expected: &advisedIndex{
+				Name:       "adv_city",
+				BucketName: "b",
+				Fields:     []string{"`city`"},
+			},
+		},
+		{
+			name: "PrimaryIndex",
+			stmt: "CREATE PRIMARY INDEX ON `b`.`s`.`c`",
+			expected: &advisedIndex{
+				IsPrimary:      true,
+				BucketName:     "b",
+				ScopeName:      "s",
+				CollectionName: "c",
+			},
+		},
+		{
+			name: "NamedPrimaryIndex",
+			stmt: "create primary index adv_primary on b",
+			expected: &advisedIndex{
+				Name:       "adv_primary",
+				IsPrimary:  true,
+				BucketName: "b",
+			},
+		},
+		{name: "Empty", stmt: "", wantErr: true},
+		{name: "NotCreate", stmt: "SELECT * FROM `b`", wantErr: true},
+		{name: "MissingIndex", stmt: "CREATE adv ON `b`(`a`)", wantErr: true},
+		{name: "MissingOn", stmt: "CREATE INDEX adv `b`(`a`)", wantErr: true},
+		{name: "ScopeKeyspace", stmt: "CREATE INDEX adv ON `b`.`s`(`a`)", wantErr: true},
+		{name: "MissingKeys", stmt: "CREATE INDEX adv ON `b`", wantErr: true},
+		{name: "UnterminatedKeys", stmt: "CREATE INDEX adv ON `b`(`a`, lower(`c`)", wantErr: true},
+		{name: "UnterminatedIdentifier", stmt: "CREATE INDEX adv ON `b(`a`)", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			idx, err := parseAdvisedIndexStatement(tc.stmt, tc.bucketName, tc.scopeName)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error parsing `%s`, got %+v", tc.stmt, idx)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse `%s`: %s", tc.stmt, err)
+			}
+			if !reflect.DeepEqual(idx, tc.expected) {
+				t.Fatalf("unexpected index for `%s`: %+v", tc.stmt, idx)
+			}
+		})
+	}
+}
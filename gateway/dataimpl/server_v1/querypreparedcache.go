@@ -0,0 +1,190 @@
+package server_v1
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/topology"
+	"github.com/couchbase/stellar-gateway/pkg/metrics"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	queryPreparedCacheDefaultMaxEntries = 5000
+	queryPreparedCacheDefaultMaxAge     = 1 * time.Hour
+)
+
+type QueryPreparedCacheOptions struct {
+	// Ctx bounds the lifetime of the cache, the topology watcher is stopped
+	// once it is cancelled.
+	Ctx context.Context
+
+	Logger           *zap.Logger
+	TopologyProvider topology.Provider
+	Metrics          *metrics.SnMetrics
+
+	// MaxEntries is the maximum number of prepared statements which are kept
+	// before the least recently used is evicted.
+	MaxEntries int
+
+	// MaxAge is the maximum amount of time a prepared statement is reused
+	// before it is prepared again.
+	MaxAge time.Duration
+}
+
+type queryPreparedCacheKey struct {
+	Statement    string
+	QueryContext string
+}
+
+type queryPreparedCacheEntry struct {
+	Key       queryPreparedCacheKey
+	Name      string
+	CreatedAt time.Time
+}
+
+// QueryPreparedCache maps query statements to the name of the prepared
+// statement which the query service generated for them.  The cache is
+// cleared whenever the cluster topology changes, since query nodes which
+// have been added or restarted will not know about existing statements.
+//
+// Each gateway instance has its own cache, nothing is shared between the
+// instances of a gateway or with other gateways, so a statement is prepared
+// once by every instance which executes it.
+type QueryPreparedCache struct {
+	logger     *zap.Logger
+	metrics    *metrics.SnMetrics
+	maxEntries int
+	maxAge     time.Duration
+
+	lock    sync.Mutex
+	entries map[queryPreparedCacheKey]*list.Element
+	lru     *list.List
+}
+
+func NewQueryPreparedCache(opts *QueryPreparedCacheOptions) *QueryPreparedCache {
+	maxEntries := opts.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = queryPreparedCacheDefaultMaxEntries
+	}
+
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = queryPreparedCacheDefaultMaxAge
+	}
+
+	c := &QueryPreparedCache{
+		logger:     opts.Logger,
+		metrics:    opts.Metrics,
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		entries:    make(map[queryPreparedCacheKey]*list.Element),
+		lru:        list.New(),
+	}
+
+	if opts.TopologyProvider != nil {
+		ctx := opts.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		go c.watchTopology(ctx, opts.TopologyProvider)
+	}
+
+	return c
+}
+
+func (c *QueryPreparedCache) watchTopology(ctx context.Context, topologyProvider topology.Provider) {
+	topologyCh, err := topologyProvider.Watch(ctx, "")
+	if err != nil {
+		c.logger.Error("failed to watch topology for prepared statement invalidation", zap.Error(err))
+		return
+	}
+
+	var lastRevision []uint64
+	for topo := range topologyCh {
+		if lastRevision != nil && !slices.Equal(lastRevision, topo.Revision) {
+			c.logger.Debug("topology changed, clearing prepared statement cache",
+				zap.Any("revision", topo.Revision))
+			c.Clear()
+		}
+
+		lastRevision = topo.Revision
+	}
+}
+
+// Get returns the prepared statement name for a statement, if one is cached
+// and has not exceeded the maximum age.
+func (c *QueryPreparedCache) Get(statement, queryContext string) (string, bool) {
+	key := queryPreparedCacheKey{Statement: statement, QueryContext: queryContext}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok {
+		entry := elem.Value.(*queryPreparedCacheEntry)
+		if time.Since(entry.CreatedAt) < c.maxAge {
+			c.lru.MoveToFront(elem)
+			if c.metrics != nil {
+				c.metrics.QueryPreparedCacheHits.Inc()
+			}
+			return entry.Name, true
+		}
+
+		c.removeLocked(elem)
+	}
+
+	if c.metrics != nil {
+		c.metrics.QueryPreparedCacheMisses.Inc()
+	}
+	return "", false
+}
+
+func (c *QueryPreparedCache) Put(statement, queryContext, name string) {
+	key := queryPreparedCacheKey{Statement: statement, QueryContext: queryContext}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+
+	c.entries[key] = c.lru.PushFront(&queryPreparedCacheEntry{
+		Key:       key,
+		Name:      name,
+		CreatedAt: time.Now(),
+	})
+
+	for c.lru.Len() > c.maxEntries {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *QueryPreparedCache) Invalidate(statement, queryContext string) {
+	key := queryPreparedCacheKey{Statement: statement, QueryContext: queryContext}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+}
+
+func (c *QueryPreparedCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = make(map[queryPreparedCacheKey]*list.Element)
+	c.lru.Init()
+}
+
+func (c *QueryPreparedCache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*queryPreparedCacheEntry)
+	delete(c.entries, entry.Key)
+}
@@ -0,0 +1,134 @@
+package server_v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/topology"
+	"go.uber.org/zap"
+)
+
+type testTopologyProvider struct {
+	topologyCh chan *topology.Topology
+}
+
+func (p *testTopologyProvider) Watch(ctx context.Context, bucketName string) (<-chan *topology.Topology, error) {
+	return p.topologyCh, nil
+}
+
+func TestQueryPreparedCacheGetPut(t *testing.T) {
+	c := NewQueryPreparedCache(&QueryPreparedCacheOptions{
+		Logger: zap.NewNop(),
+	})
+
+	_, ok := c.Get("SELECT 1", "")
+	if ok {
+		t.Fatalf("expected an empty cache to miss")
+	}
+
+	c.Put("SELECT 1", "", "prep-1")
+	c.Put("SELECT 1", "`b`.`s`", "prep-2")
+
+	name, ok := c.Get("SELECT 1", "")
+	if !ok || name != "prep-1" {
+		t.Fatalf("expected prep-1, got %s (found: %t)", name, ok)
+	}
+
+	// the query context is part of the key
+	name, ok = c.Get("SELECT 1", "`b`.`s`")
+	if !ok || name != "prep-2" {
+		t.Fatalf("expected prep-2, got %s (found: %t)", name, ok)
+	}
+
+	c.Invalidate("SELECT 1", "")
+	_, ok = c.Get("SELECT 1", "")
+	if ok {
+		t.Fatalf("expected an invalidated statement to miss")
+	}
+}
+
+func TestQueryPreparedCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewQueryPreparedCache(&QueryPreparedCacheOptions{
+		Logger:     zap.NewNop(),
+		MaxEntries: 2,
+	})
+
+	c.Put("SELECT 1", "", "prep-1")
+	c.Put("SELECT 2", "", "prep-2")
+
+	// using the first statement makes the second the least recently used
+	_, ok := c.Get("SELECT 1", "")
+	if !ok {
+		t.Fatalf("expected SELECT 1 to be cached")
+	}
+
+	c.Put("SELECT 3", "", "prep-3")
+
+	if _, ok := c.Get("SELECT 2", ""); ok {
+		t.Fatalf("expected the least recently used statement to be evicted")
+	}
+	if _, ok := c.Get("SELECT 1", ""); !ok {
+		t.Fatalf("expected the recently used statement to be kept")
+	}
+	if _, ok := c.Get("SELECT 3", ""); !ok {
+		t.Fatalf("expected the newest statement to be kept")
+	}
+}
+
+func TestQueryPreparedCacheMaxAge(t *testing.T) {
+	c := NewQueryPreparedCache(&QueryPreparedCacheOptions{
+		Logger: zap.NewNop(),
+		MaxAge: 10 * time.Millisecond,
+	})
+
+	c.Put("SELECT 1", "", "prep-1")
+	if _, ok := c.Get("SELECT 1", ""); !ok {
+		t.Fatalf("expected a new statement to be cached")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("SELECT 1", ""); ok {
+		t.Fatalf("expected a statement past its max age to miss")
+	}
+}
+
+func TestQueryPreparedCacheClearsOnTopologyChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider := &testTopologyProvider{
+		topologyCh: make(chan *topology.Topology),
+	}
+	c := NewQueryPreparedCache(&QueryPreparedCacheOptions{
+		Ctx:              ctx,
+		Logger:           zap.NewNop(),
+		TopologyProvider: provider,
+	})
+
+	provider.topologyCh <- &topology.Topology{Revision: []uint64{1, 1}}
+	c.Put("SELECT 1", "", "prep-1")
+
+	// the same revision again must not clear the cache.  The send on the
+	// unbuffered channel only returns once the previous topology is handled.
+	provider.topologyCh <- &topology.Topology{Revision: []uint64{1, 1}}
+	provider.topologyCh <- &topology.Topology{Revision: []uint64{1, 1}}
+	if _, ok := c.Get("SELECT 1", ""); !ok {
+		t.Fatalf("expected an unchanged topology to keep the cache")
+	}
+
+	provider.topologyCh <- &topology.Topology{Revision: []uint64{1, 2}}
+	close(provider.topologyCh)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := c.Get("SELECT 1", ""); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a topology change to clear the cache")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
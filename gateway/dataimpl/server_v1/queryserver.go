@@ -1,12 +1,14 @@
 package server_v1
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 
 	"github.com/couchbase/gocbcorex"
 	"github.com/couchbase/gocbcorex/cbqueryx"
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -16,20 +18,26 @@ import (
 type QueryServer struct {
 	query_v1.UnimplementedQueryServiceServer
 
-	logger       *zap.Logger
-	errorHandler *ErrorHandler
-	authHandler  *AuthHandler
+	logger         *zap.Logger
+	errorHandler   *ErrorHandler
+	authHandler    *AuthHandler
+	preparedCache  *QueryPreparedCache
+	rowBatcherOpts *RowBatcherOptions
 }
 
 func NewQueryServer(
 	logger *zap.Logger,
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
+	preparedCache *QueryPreparedCache,
+	rowBatcherOpts *RowBatcherOptions,
 ) *QueryServer {
 	return &QueryServer{
-		logger:       logger,
-		errorHandler: errorHandler,
-		authHandler:  authHandler,
+		logger:         logger,
+		errorHandler:   errorHandler,
+		authHandler:    authHandler,
+		preparedCache:  preparedCache,
+		rowBatcherOpts: rowBatcherOpts,
 	}
 }
 
@@ -51,6 +59,126 @@ func (s *QueryServer) translateError(err error) *status.Status {
 	return s.errorHandler.NewGenericStatus(err)
 }
 
+// scanVectorsFromMutationTokens builds the at_plus scan vectors from a set of
+// mutation tokens, grouping them by the bucket each token belongs to and keeping
+// only the newest sequence number seen for each vbucket.  If bucketName is not
+// blank, all the tokens must belong to that bucket.
+func (s *QueryServer) scanVectorsFromMutationTokens(
+	bucketName string,
+	tokens []*kv_v1.MutationToken,
+) (map[string]cbqueryx.SparseScanVector, *status.Status) {
+	scanVectors := make(map[string]cbqueryx.SparseScanVector)
+	for _, token := range tokens {
+		if token.BucketName == "" {
+			return nil, status.New(codes.InvalidArgument,
+				"mutation tokens must specify the bucket they belong to")
+		}
+
+		if bucketName != "" && token.BucketName != bucketName {
+			return nil, status.New(codes.InvalidArgument,
+				fmt.Sprintf("mutation token for bucket '%s' cannot be used to query bucket '%s'",
+					token.BucketName, bucketName))
+		}
+
+		scanVector, ok := scanVectors[token.BucketName]
+		if !ok {
+			scanVector = make(cbqueryx.SparseScanVector)
+			scanVectors[token.BucketName] = scanVector
+		}
+
+		existing, ok := scanVector[token.VbucketId]
+		if ok && existing.SeqNo >= token.SeqNo {
+			continue
+		}
+
+		scanVector[token.VbucketId] = cbqueryx.ScanVectorEntry{
+			SeqNo:  token.SeqNo,
+			VbUuid: fmt.Sprintf("%d", token.VbucketUuid),
+		}
+	}
+
+	return scanVectors, nil
+}
+
+// prepareStatement prepares a statement with the query service and returns the
+// name of the resulting prepared statement.
+func (s *QueryServer) prepareStatement(
+	ctx context.Context,
+	agent *gocbcorex.Agent,
+	opts *gocbcorex.QueryOptions,
+) (string, error) {
+	var prepOpts gocbcorex.QueryOptions
+	prepOpts.OnBehalfOf = opts.OnBehalfOf
+	prepOpts.QueryContext = opts.QueryContext
+	prepOpts.Statement = "PREPARE " + opts.Statement
+
+	result, err := agent.Query(ctx, &prepOpts)
+	if err != nil {
+		return "", err
+	}
+
+	var prepRow struct {
+		Name string `json:"name"`
+	}
+	for result.HasMoreRows() {
+		rowBytes, err := result.ReadRow()
+		if err != nil {
+			return "", err
+		}
+
+		err = json.Unmarshal(rowBytes, &prepRow)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if prepRow.Name == "" {
+		return "", errors.New("query service did not return a prepared statement name")
+	}
+
+	return prepRow.Name, nil
+}
+
+// preparedQuery executes a statement using a prepared statement from the
+// gateway prepared statement cache, preparing the statement if necessary.
+// Cached statements which the query service no longer recognizes are
+// invalidated and prepared again.
+func (s *QueryServer) preparedQuery(
+	ctx context.Context,
+	agent *gocbcorex.Agent,
+	opts *gocbcorex.QueryOptions,
+) (gocbcorex.QueryResultStream, error) {
+	statement := opts.Statement
+	queryContext := opts.QueryContext
+
+	execOpts := *opts
+	execOpts.Statement = ""
+
+	name, ok := s.preparedCache.Get(statement, queryContext)
+	if ok {
+		execOpts.Prepared = name
+		result, err := agent.Query(ctx, &execOpts)
+		if !errors.Is(err, cbqueryx.ErrPreparedStatementFailure) {
+			return result, err
+		}
+
+		s.logger.Debug("cached prepared statement was rejected, preparing again",
+			zap.Error(err),
+			zap.String("name", name))
+		s.preparedCache.Invalidate(statement, queryContext)
+	}
+
+	name, err := s.prepareStatement(ctx, agent, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.preparedCache.Put(statement, queryContext, name)
+
+	execOpts.Prepared = name
+	return agent.Query(ctx, &execOpts)
+}
+
 func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService_QueryServer) error {
 	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(out.Context(), in.BucketName)
 	if errSt != nil {
@@ -85,10 +213,6 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 		opts.PreserveExpiry = *in.PreserveExpiry
 	}
 
-	if in.ConsistentWith != nil {
-		return s.errorHandler.NewUnsupportedFieldStatus("ConsistentWith").Err()
-	}
-
 	if in.TuningOptions != nil {
 		if in.TuningOptions.MaxParallelism != nil {
 			opts.MaxParallelism = *in.TuningOptions.MaxParallelism
@@ -124,6 +248,20 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 			return status.Errorf(codes.InvalidArgument, "invalid scan consistency option specified")
 		}
 	}
+
+	if len(in.ConsistentWith) > 0 {
+		if in.ScanConsistency != nil {
+			return status.Errorf(codes.InvalidArgument, "scan consistency and consistent with must be used exclusively")
+		}
+
+		scanVectors, errSt := s.scanVectorsFromMutationTokens(in.GetBucketName(), in.ConsistentWith)
+		if errSt != nil {
+			return errSt.Err()
+		}
+
+		opts.ScanConsistency = cbqueryx.QueryScanConsistencyAtPlus
+		opts.SparseScanVectors = scanVectors
+	}
 	named := in.GetNamedParameters()
 	pos := in.GetPositionalParameters()
 	if len(named) > 0 && len(pos) > 0 {
@@ -159,7 +297,7 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 	var result gocbcorex.QueryResultStream
 	var err error
 	if in.Prepared != nil && *in.Prepared {
-		result, err = agent.PreparedQuery(out.Context(), &opts)
+		result, err = s.preparedQuery(out.Context(), agent, &opts)
 	} else {
 		result, err = agent.Query(out.Context(), &opts)
 	}
@@ -167,9 +305,13 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 		return s.translateError(err).Err()
 	}
 
-	var rowCache [][]byte
-	rowCacheNumBytes := 0
-	const MaxRowBytes = 1024
+	batcher := newRowBatcher(s.rowBatcherOpts, func(rows [][]byte) error {
+		return out.Send(&query_v1.QueryResponse{
+			Rows:     rows,
+			MetaData: nil,
+		})
+	})
+	defer batcher.Close()
 
 	for result.HasMoreRows() {
 		rowBytes, err := result.ReadRow()
@@ -177,25 +319,10 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 			return s.translateError(err).Err()
 		}
 
-		rowNumBytes := len(rowBytes)
-
-		if rowCacheNumBytes+rowNumBytes > MaxRowBytes {
-			// adding this row to the cache would exceed its maximum number of
-			// bytes, so we need to evict all these rows...
-			err := out.Send(&query_v1.QueryResponse{
-				Rows:     rowCache,
-				MetaData: nil,
-			})
-			if err != nil {
-				return s.errorHandler.NewGenericStatus(err).Err()
-			}
-
-			rowCache = nil
-			rowCacheNumBytes = 0
+		err = batcher.Add(rowBytes, len(rowBytes))
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
 		}
-
-		rowCache = append(rowCache, rowBytes)
-		rowCacheNumBytes += rowNumBytes
 	}
 
 	var psMetaData *query_v1.QueryResponse_MetaData
@@ -269,6 +396,7 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 
 	// if we have any rows or meta-data left to stream, we send that first
 	// before we process any errors that occurred.
+	rowCache := batcher.Take()
 	if rowCache != nil || psMetaData != nil {
 		err := out.Send(&query_v1.QueryResponse{
 			Rows:     rowCache,
@@ -281,3 +409,223 @@ func (s *QueryServer) Query(in *query_v1.QueryRequest, out query_v1.QueryService
 
 	return nil
 }
+
+// executeStatement runs a statement to completion using the same query context
+// rules as Query and returns all of the rows it produced.
+func (s *QueryServer) executeStatement(
+	ctx context.Context,
+	bucketName *string,
+	scopeName *string,
+	statement string,
+	namedParameters map[string][]byte,
+	positionalParameters [][]byte,
+) ([]json.RawMessage, *status.Status) {
+	if bucketName == nil && scopeName != nil {
+		return nil, status.New(codes.InvalidArgument, "invalid scope and bucket name combination options specified")
+	}
+
+	if len(namedParameters) > 0 && len(positionalParameters) > 0 {
+		return nil, status.New(codes.InvalidArgument, "named and positional parameters must be used exclusively")
+	}
+
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, bucketName)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	var opts gocbcorex.QueryOptions
+	opts.OnBehalfOf = oboInfo
+	opts.Statement = statement
+
+	if bucketName != nil && scopeName != nil {
+		opts.QueryContext = fmt.Sprintf("`%s`.`%s`", *bucketName, *scopeName)
+	}
+
+	if len(namedParameters) > 0 {
+		params := make(map[string]json.RawMessage, len(namedParameters))
+		for k, v := range namedParameters {
+			params[k] = v
+		}
+		opts.NamedArgs = params
+	}
+	if len(positionalParameters) > 0 {
+		params := make([]json.RawMessage, len(positionalParameters))
+		for i, p := range positionalParameters {
+			params[i] = p
+		}
+		opts.Args = params
+	}
+
+	result, err := agent.Query(ctx, &opts)
+	if err != nil {
+		return nil, s.translateError(err)
+	}
+
+	var rows []json.RawMessage
+	for result.HasMoreRows() {
+		rowBytes, err := result.ReadRow()
+		if err != nil {
+			return nil, s.translateError(err)
+		}
+
+		rows = append(rows, rowBytes)
+	}
+
+	return rows, nil
+}
+
+func (s *QueryServer) Explain(ctx context.Context, in *query_v1.ExplainRequest) (*query_v1.ExplainResponse, error) {
+	if in.Statement == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "a statement must be specified")
+	}
+
+	rows, errSt := s.executeStatement(ctx,
+		in.BucketName, in.ScopeName,
+		"EXPLAIN "+in.Statement,
+		in.NamedParameters, in.PositionalParameters)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if len(rows) != 1 {
+		return nil, s.errorHandler.NewGenericStatus(
+			fmt.Errorf("expected a single explain row but received %d", len(rows))).Err()
+	}
+
+	var explainRow struct {
+		Plan json.RawMessage `json:"plan"`
+	}
+	err := json.Unmarshal(rows[0], &explainRow)
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &query_v1.ExplainResponse{
+		Plan: explainRow.Plan,
+	}, nil
+}
+
+type queryAdviseRowJson struct {
+	Advice struct {
+		AdviseInfo json.RawMessage `json:"adviseinfo"`
+	} `json:"advice"`
+}
+
+type queryAdviseInfoJson struct {
+	// RecommendedIndexes is a string rather than an object when the advisor
+	// has no recommendations to make.
+	RecommendedIndexes json.RawMessage `json:"recommended_indexes"`
+}
+
+type queryAdviseRecommendationsJson struct {
+	Indexes         []queryAdvisedIndexJson `json:"indexes"`
+	CoveringIndexes []queryAdvisedIndexJson `json:"covering_indexes"`
+}
+
+type queryAdvisedIndexJson struct {
+	IndexStatement   string `json:"index_statement"`
+	KeyspaceAlias    string `json:"keyspace_alias"`
+	RecommendingRule string `json:"recommending_rule"`
+	IndexProperty    string `json:"index_property"`
+}
+
+func (s *QueryServer) advisedIndexFromJson(
+	indexJson queryAdvisedIndexJson,
+	bucketName, scopeName string,
+	covering bool,
+) *query_v1.AdviseIndexesResponse_Index {
+	psIndex := &query_v1.AdviseIndexesResponse_Index{
+		IndexStatement:   indexJson.IndexStatement,
+		Covering:         covering,
+		RecommendingRule: indexJson.RecommendingRule,
+	}
+
+	idx, err := parseAdvisedIndexStatement(indexJson.IndexStatement, bucketName, scopeName)
+	if err != nil {
+		// the statement is still returned verbatim so that it can be run by hand
+		s.logger.Debug("failed to parse advised index statement",
+			zap.Error(err),
+			zap.String("statement", indexJson.IndexStatement))
+		return psIndex
+	}
+
+	psIndex.Name = idx.Name
+	psIndex.IsPrimary = idx.IsPrimary
+	psIndex.BucketName = idx.BucketName
+	if idx.ScopeName != "" {
+		psIndex.ScopeName = &idx.ScopeName
+		psIndex.CollectionName = &idx.CollectionName
+	}
+	psIndex.Fields = idx.Fields
+	if idx.Condition != "" {
+		psIndex.Condition = &idx.Condition
+	}
+
+	return psIndex
+}
+
+func (s *QueryServer) AdviseIndexes(ctx context.Context, in *query_v1.AdviseIndexesRequest) (*query_v1.AdviseIndexesResponse, error) {
+	if in.Statement == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "a statement must be specified")
+	}
+
+	rows, errSt := s.executeStatement(ctx,
+		in.BucketName, in.ScopeName,
+		"ADVISE "+in.Statement,
+		in.NamedParameters, in.PositionalParameters)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if len(rows) != 1 {
+		return nil, s.errorHandler.NewGenericStatus(
+			fmt.Errorf("expected a single advise row but received %d", len(rows))).Err()
+	}
+
+	var adviseRow queryAdviseRowJson
+	err := json.Unmarshal(rows[0], &adviseRow)
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	// depending on the server version, adviseinfo is either a single object
+	// or a list of objects (one per query block).
+	var adviseInfos []queryAdviseInfoJson
+	if len(adviseRow.Advice.AdviseInfo) > 0 && adviseRow.Advice.AdviseInfo[0] == '[' {
+		err = json.Unmarshal(adviseRow.Advice.AdviseInfo, &adviseInfos)
+	} else if len(adviseRow.Advice.AdviseInfo) > 0 {
+		var adviseInfo queryAdviseInfoJson
+		err = json.Unmarshal(adviseRow.Advice.AdviseInfo, &adviseInfo)
+		adviseInfos = append(adviseInfos, adviseInfo)
+	}
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	var psIndexes []*query_v1.AdviseIndexesResponse_Index
+	for _, adviseInfo := range adviseInfos {
+		if len(adviseInfo.RecommendedIndexes) == 0 || adviseInfo.RecommendedIndexes[0] != '{' {
+			continue
+		}
+
+		var recommendations queryAdviseRecommendationsJson
+		err := json.Unmarshal(adviseInfo.RecommendedIndexes, &recommendations)
+		if err != nil {
+			return nil, s.errorHandler.NewGenericStatus(err).Err()
+		}
+
+		for _, indexJson := range recommendations.Indexes {
+			psIndexes = append(psIndexes, s.advisedIndexFromJson(indexJson,
+				in.GetBucketName(), in.GetScopeName(), false))
+		}
+		for _, indexJson := range recommendations.CoveringIndexes {
+			psIndexes = append(psIndexes, s.advisedIndexFromJson(indexJson,
+				in.GetBucketName(), in.GetScopeName(), true))
+		}
+	}
+
+	return &query_v1.AdviseIndexesResponse{
+		Indexes: psIndexes,
+		Advice:  adviseRow.Advice.AdviseInfo,
+	}, nil
+}
@@ -0,0 +1,211 @@
+package server_v1
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	rowBatcherDefaultMaxBatchBytes = 1024 * 1024
+	rowBatcherDefaultTargetLatency = 50 * time.Millisecond
+	rowBatcherMinBatchBytes        = 4 * 1024
+
+	// sends which block for longer than this are taken as a sign that the
+	// client's flow control window is exhausted.
+	rowBatcherSlowSendThreshold = 5 * time.Millisecond
+)
+
+type RowBatcherOptions struct {
+	// MaxBatchBytes is the largest number of row bytes which will be placed
+	// into a single streamed message.
+	MaxBatchBytes int
+
+	// TargetLatency is the longest that a row will be held waiting for more
+	// rows to fill its batch, a timer sends partial batches once it elapses.
+	TargetLatency time.Duration
+}
+
+// rowBatcher groups the rows of a streaming result into messages.  The first
+// row is always sent immediately, after which the batch size grows while the
+// client keeps up and shrinks once gRPC flow control starts blocking sends.
+// A timer flushes partial batches so that rows are not held back when the
+// upstream stalls, which means send may be called from another goroutine,
+// though never concurrently with itself or after Take or Close return.
+type rowBatcher[T any] struct {
+	maxBatchBytes int
+	targetLatency time.Duration
+	send          func(rows []T) error
+
+	lock           sync.Mutex
+	targetBytes    int
+	rows           []T
+	numBytes       int
+	batchStartTime time.Time
+	sentFirst      bool
+	flushTimer     *time.Timer
+	flushTimerGen  uint64
+	flushErr       error
+	closed         bool
+}
+
+func newRowBatcher[T any](opts *RowBatcherOptions, send func(rows []T) error) *rowBatcher[T] {
+	b := &rowBatcher[T]{
+		maxBatchBytes: rowBatcherDefaultMaxBatchBytes,
+		targetLatency: rowBatcherDefaultTargetLatency,
+		send:          send,
+	}
+
+	if opts != nil {
+		if opts.MaxBatchBytes > 0 {
+			b.maxBatchBytes = opts.MaxBatchBytes
+		}
+		if opts.TargetLatency > 0 {
+			b.targetLatency = opts.TargetLatency
+		}
+	}
+
+	b.targetBytes = rowBatcherMinBatchBytes
+	if b.targetBytes > b.maxBatchBytes {
+		b.targetBytes = b.maxBatchBytes
+	}
+
+	return b
+}
+
+// Add queues a row for sending, sending any batched rows first if the new row
+// would push the batch beyond its current target size.  Errors from sends
+// triggered by the flush timer are returned by the next call to Add.
+func (b *rowBatcher[T]) Add(row T, rowNumBytes int) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.flushErr != nil {
+		return b.flushErr
+	}
+
+	if len(b.rows) > 0 && b.numBytes+rowNumBytes > b.targetBytes {
+		err := b.flushLocked()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(b.rows) == 0 {
+		b.batchStartTime = time.Now()
+	}
+
+	b.rows = append(b.rows, row)
+	b.numBytes += rowNumBytes
+
+	if !b.sentFirst || time.Since(b.batchStartTime) >= b.targetLatency {
+		return b.flushLocked()
+	}
+
+	if b.flushTimer == nil {
+		b.startTimerLocked(b.targetLatency)
+	}
+
+	return nil
+}
+
+func (b *rowBatcher[T]) startTimerLocked(delay time.Duration) {
+	gen := b.flushTimerGen
+	b.flushTimer = time.AfterFunc(delay, func() {
+		b.timerFlush(gen)
+	})
+}
+
+func (b *rowBatcher[T]) stopTimerLocked() {
+	if b.flushTimer != nil {
+		b.flushTimer.Stop()
+		b.flushTimer = nil
+	}
+
+	// a timer which has already fired may be waiting for the lock, bumping
+	// the generation ensures that it does nothing once it acquires it.
+	b.flushTimerGen++
+}
+
+func (b *rowBatcher[T]) timerFlush(gen uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if gen != b.flushTimerGen || b.closed || b.flushErr != nil {
+		return
+	}
+
+	b.flushTimer = nil
+	b.flushErr = b.flushLocked()
+}
+
+// Flush sends any batched rows and adjusts the target batch size according
+// to how long the send blocked for.
+func (b *rowBatcher[T]) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.flushLocked()
+}
+
+func (b *rowBatcher[T]) flushLocked() error {
+	rows := b.takeLocked()
+	if len(rows) == 0 {
+		return nil
+	}
+
+	sendStartTime := time.Now()
+	err := b.send(rows)
+	if err != nil {
+		return err
+	}
+	sendTime := time.Since(sendStartTime)
+
+	b.sentFirst = true
+
+	if sendTime > rowBatcherSlowSendThreshold {
+		b.targetBytes /= 2
+		if b.targetBytes < rowBatcherMinBatchBytes {
+			b.targetBytes = rowBatcherMinBatchBytes
+		}
+		if b.targetBytes > b.maxBatchBytes {
+			b.targetBytes = b.maxBatchBytes
+		}
+	} else {
+		b.targetBytes *= 2
+		if b.targetBytes > b.maxBatchBytes {
+			b.targetBytes = b.maxBatchBytes
+		}
+	}
+
+	return nil
+}
+
+// Take returns any batched rows without sending them, this allows the final
+// rows of a result to be sent in the same message as its meta-data.
+func (b *rowBatcher[T]) Take() []T {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.takeLocked()
+}
+
+// Close stops the flush timer, discarding any batched rows.  It must be
+// called before the stream handler returns so that no further sends occur.
+func (b *rowBatcher[T]) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.closed = true
+	b.stopTimerLocked()
+	b.rows = nil
+	b.numBytes = 0
+}
+
+func (b *rowBatcher[T]) takeLocked() []T {
+	b.stopTimerLocked()
+
+	rows := b.rows
+	b.rows = nil
+	b.numBytes = 0
+	return rows
+}
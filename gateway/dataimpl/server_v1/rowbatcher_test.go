@@ -0,0 +1,157 @@
+package server_v1
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRowBatcherFirstRowImmediate(t *testing.T) {
+	var batches [][]int
+	b := newRowBatcher(&RowBatcherOptions{
+		TargetLatency: time.Hour,
+	}, func(rows []int) error {
+		batches = append(batches, rows)
+		return nil
+	})
+
+	err := b.Add(1, 10)
+	if err != nil {
+		t.Fatalf("failed to add row: %s", err)
+	}
+
+	if len(batches) != 1 || len(batches[0]) != 1 {
+		t.Fatalf("first row was not sent immediately")
+	}
+}
+
+func TestRowBatcherGrowsBatches(t *testing.T) {
+	var batches [][]int
+	b := newRowBatcher(&RowBatcherOptions{
+		MaxBatchBytes: 64 * 1024,
+		TargetLatency: time.Hour,
+	}, func(rows []int) error {
+		batches = append(batches, rows)
+		return nil
+	})
+
+	for i := 0; i < 1000; i++ {
+		err := b.Add(i, 1024)
+		if err != nil {
+			t.Fatalf("failed to add row: %s", err)
+		}
+	}
+
+	numRows := len(b.Take())
+	for batchIdx, batch := range batches {
+		if len(batch) > 64 {
+			t.Fatalf("batch %d exceeded the maximum batch size", batchIdx)
+		}
+		numRows += len(batch)
+	}
+
+	if numRows != 1000 {
+		t.Fatalf("expected 1000 rows but got %d", numRows)
+	}
+
+	lastBatch := batches[len(batches)-1]
+	if len(lastBatch) != 64 {
+		t.Fatalf("batches did not grow to the maximum size, last batch had %d rows", len(lastBatch))
+	}
+}
+
+func TestRowBatcherTakeLeavesRows(t *testing.T) {
+	b := newRowBatcher(&RowBatcherOptions{
+		TargetLatency: time.Hour,
+	}, func(rows []int) error {
+		return nil
+	})
+
+	_ = b.Add(1, 10)
+	_ = b.Add(2, 10)
+	_ = b.Add(3, 10)
+
+	rows := b.Take()
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 pending rows but got %d", len(rows))
+	}
+
+	if len(b.Take()) != 0 {
+		t.Fatalf("take did not reset the pending rows")
+	}
+}
+
+func TestRowBatcherFlushesOnTimer(t *testing.T) {
+	sentCh := make(chan []int, 10)
+	b := newRowBatcher(&RowBatcherOptions{
+		TargetLatency: 10 * time.Millisecond,
+	}, func(rows []int) error {
+		sentCh <- rows
+		return nil
+	})
+	defer b.Close()
+
+	_ = b.Add(1, 10)
+	<-sentCh
+
+	// no further rows arrive, so the timer must send the pending row
+	_ = b.Add(2, 10)
+
+	select {
+	case rows := <-sentCh:
+		if len(rows) != 1 || rows[0] != 2 {
+			t.Fatalf("unexpected rows sent by the timer: %v", rows)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pending row was not sent once the target latency elapsed")
+	}
+}
+
+func TestRowBatcherTimerSendError(t *testing.T) {
+	sendErr := errors.New("send failed")
+	sentCh := make(chan struct{}, 10)
+	numSends := 0
+	b := newRowBatcher(&RowBatcherOptions{
+		TargetLatency: 10 * time.Millisecond,
+	}, func(rows []int) error {
+		numSends++
+		sentCh <- struct{}{}
+		if numSends > 1 {
+			return sendErr
+		}
+		return nil
+	})
+	defer b.Close()
+
+	_ = b.Add(1, 10)
+	_ = b.Add(2, 10)
+	<-sentCh
+	<-sentCh
+
+	err := b.Add(3, 10)
+	if !errors.Is(err, sendErr) {
+		t.Fatalf("expected the timer send error to be returned, got %v", err)
+	}
+}
+
+func TestRowBatcherCloseStopsTimer(t *testing.T) {
+	sentCh := make(chan []int, 10)
+	b := newRowBatcher(&RowBatcherOptions{
+		TargetLatency: 10 * time.Millisecond,
+	}, func(rows []int) error {
+		sentCh <- rows
+		return nil
+	})
+
+	_ = b.Add(1, 10)
+	<-sentCh
+
+	_ = b.Add(2, 10)
+	b.Close()
+
+	select {
+	case rows := <-sentCh:
+		t.Fatalf("rows were sent after the batcher was closed: %v", rows)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
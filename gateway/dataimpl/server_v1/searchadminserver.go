@@ -2,10 +2,16 @@ package server_v1
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbhttpx"
 	"github.com/couchbase/gocbcorex/cbsearchx"
 
 	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
@@ -31,23 +37,180 @@ func NewSearchIndexAdminServer(
 	}
 }
 
+// getSearchAgent fetches the agent to use for a search index management request,
+// validating the bucket and scope combination used for scoped indexes.
+func (s *SearchIndexAdminServer) getSearchAgent(
+	ctx context.Context,
+	bucketName, scopeName *string,
+) (*gocbcorex.Agent, *cbhttpx.OnBehalfOfInfo, *status.Status) {
+	if bucketName == nil && scopeName != nil {
+		return nil, nil, status.New(codes.InvalidArgument, "invalid scope and bucket name combination options specified")
+	}
+
+	return s.authHandler.GetHttpOboAgent(ctx, bucketName)
+}
+
+func (s *SearchIndexAdminServer) translateError(err error, indexName string) *status.Status {
+	if errors.Is(err, cbsearchx.ErrIndexNotFound) {
+		return s.errorHandler.NewSearchIndexMissingStatus(err, indexName)
+	} else if errors.Is(err, cbsearchx.ErrIndexExists) {
+		return s.errorHandler.NewSearchIndexExistsStatus(err, indexName)
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
+func searchIndexParamsToCbsearchx(params map[string][]byte) map[string]json.RawMessage {
+	out := make(map[string]json.RawMessage, len(params))
+	for key, param := range params {
+		out[key] = param
+	}
+	return out
+}
+
+func searchIndexParamsFromCbsearchx(params map[string]json.RawMessage) map[string][]byte {
+	out := make(map[string][]byte, len(params))
+	for key, param := range params {
+		out[key] = param
+	}
+	return out
+}
+
+func searchIndexFromCbsearchx(index *cbsearchx.Index) *admin_search_v1.Index {
+	psIndex := &admin_search_v1.Index{
+		Name:         index.Name,
+		Type:         index.Type,
+		Uuid:         index.UUID,
+		Params:       searchIndexParamsFromCbsearchx(index.Params),
+		PlanParams:   searchIndexParamsFromCbsearchx(index.PlanParams),
+		SourceParams: searchIndexParamsFromCbsearchx(index.SourceParams),
+	}
+
+	if index.SourceName != "" {
+		sourceName := index.SourceName
+		psIndex.SourceName = &sourceName
+	}
+
+	if index.SourceType != "" {
+		sourceType := index.SourceType
+		psIndex.SourceType = &sourceType
+	}
+
+	if index.SourceUUID != "" {
+		sourceUuid := index.SourceUUID
+		psIndex.SourceUuid = &sourceUuid
+	}
+
+	return psIndex
+}
+
+type searchIndexMappingJson struct {
+	DefaultMapping *searchIndexDocMappingJson           `json:"default_mapping"`
+	Types          map[string]searchIndexDocMappingJson `json:"types"`
+}
+
+type searchIndexDocMappingJson struct {
+	Properties map[string]searchIndexDocMappingJson `json:"properties"`
+	Fields     []searchIndexFieldMappingJson        `json:"fields"`
+}
+
+type searchIndexFieldMappingJson struct {
+	Name                    string `json:"name"`
+	Type                    string `json:"type"`
+	Dims                    int    `json:"dims"`
+	Similarity              string `json:"similarity"`
+	VectorIndexOptimizedFor string `json:"vector_index_optimized_for"`
+}
+
+// searchIndexMaxVectorDims is the largest vector dimension supported by the
+// search service.
+const searchIndexMaxVectorDims = 4096
+
+func validateSearchIndexDocMapping(path string, mapping *searchIndexDocMappingJson) *status.Status {
+	for _, field := range mapping.Fields {
+		if field.Type != "vector" {
+			continue
+		}
+
+		fieldPath := path + field.Name
+		if field.Dims <= 0 || field.Dims > searchIndexMaxVectorDims {
+			return status.New(codes.InvalidArgument,
+				fmt.Sprintf("vector field '%s' must specify dims between 1 and %d", fieldPath, searchIndexMaxVectorDims))
+		}
+
+		// a blank similarity uses the search service default of l2_norm.
+		switch field.Similarity {
+		case "", "dot_product", "l2_norm":
+		default:
+			return status.New(codes.InvalidArgument,
+				fmt.Sprintf("vector field '%s' must specify a similarity of dot_product or l2_norm", fieldPath))
+		}
+
+		switch field.VectorIndexOptimizedFor {
+		case "", "recall", "latency":
+		default:
+			return status.New(codes.InvalidArgument,
+				fmt.Sprintf("vector field '%s' must be optimized for either recall or latency", fieldPath))
+		}
+	}
+
+	for propName, propMapping := range mapping.Properties {
+		propMapping := propMapping
+		if errSt := validateSearchIndexDocMapping(path+propName+".", &propMapping); errSt != nil {
+			return errSt
+		}
+	}
+
+	return nil
+}
+
+// validateSearchIndexVectorFields checks any vector fields defined within an
+// index mapping, so that users receive a useful error rather than a generic one
+// from the search service.
+func validateSearchIndexVectorFields(params map[string][]byte) *status.Status {
+	mappingBytes, ok := params["mapping"]
+	if !ok {
+		return nil
+	}
+
+	var mapping searchIndexMappingJson
+	err := json.Unmarshal(mappingBytes, &mapping)
+	if err != nil {
+		return status.New(codes.InvalidArgument, "index mapping params could not be parsed")
+	}
+
+	if mapping.DefaultMapping != nil {
+		if errSt := validateSearchIndexDocMapping("", mapping.DefaultMapping); errSt != nil {
+			return errSt
+		}
+	}
+
+	for typeName, typeMapping := range mapping.Types {
+		typeMapping := typeMapping
+		if errSt := validateSearchIndexDocMapping(typeName+".", &typeMapping); errSt != nil {
+			return errSt
+		}
+	}
+
+	return nil
+}
+
 func (s *SearchIndexAdminServer) UpsertIndex(ctx context.Context, in *admin_search_v1.UpsertIndexRequest) (*admin_search_v1.UpsertIndexResponse, error) {
-	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, in.BucketName)
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
 	if errSt != nil {
 		return nil, errSt.Err()
 	}
 
-	index := cbsearchx.Index{
-		Name: in.Name,
-		Type: in.Type,
-	}
-
-	for key, param := range in.Params {
-		index.Params[key] = param
+	errSt = validateSearchIndexVectorFields(in.Params)
+	if errSt != nil {
+		return nil, errSt.Err()
 	}
 
-	for key, param := range in.PlanParams {
-		index.PlanParams[key] = param
+	index := cbsearchx.Index{
+		Name:         in.Name,
+		Type:         in.Type,
+		Params:       searchIndexParamsToCbsearchx(in.Params),
+		PlanParams:   searchIndexParamsToCbsearchx(in.PlanParams),
+		SourceParams: searchIndexParamsToCbsearchx(in.SourceParams),
 	}
 
 	if in.PrevIndexUuid != nil {
@@ -58,10 +221,6 @@ func (s *SearchIndexAdminServer) UpsertIndex(ctx context.Context, in *admin_sear
 		index.SourceName = in.GetSourceName()
 	}
 
-	for key, param := range in.SourceParams {
-		index.SourceParams[key] = param
-	}
-
 	if in.SourceType != nil {
 		index.SourceType = in.GetSourceType()
 	}
@@ -76,34 +235,264 @@ func (s *SearchIndexAdminServer) UpsertIndex(ctx context.Context, in *admin_sear
 
 	err := agent.UpsertSearchIndex(ctx, &cbsearchx.UpsertIndexOptions{
 		OnBehalfOf: oboInfo,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
 		Index:      index,
 	})
 	if err != nil {
-		if errors.Is(err, cbsearchx.ErrIndexExists) {
-			return nil, s.errorHandler.NewSearchIndexExistsStatus(err, in.Name).Err()
-		}
-		return nil, s.errorHandler.NewGenericStatus(err).Err()
+		return nil, s.translateError(err, in.Name).Err()
 	}
 
 	return &admin_search_v1.UpsertIndexResponse{}, nil
 }
 
 func (s *SearchIndexAdminServer) DeleteIndex(ctx context.Context, in *admin_search_v1.DeleteIndexRequest) (*admin_search_v1.DeleteIndexResponse, error) {
-	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, in.BucketName)
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
 	if errSt != nil {
 		return nil, errSt.Err()
 	}
 
 	err := agent.DeleteSearchIndex(ctx, &cbsearchx.DeleteIndexOptions{
 		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
 		OnBehalfOf: oboInfo,
 	})
 	if err != nil {
-		if errors.Is(err, cbsearchx.ErrIndexExists) {
-			return nil, s.errorHandler.NewSearchIndexMissingStatus(err, in.Name).Err()
-		}
-		return nil, s.errorHandler.NewGenericStatus(err).Err()
+		return nil, s.translateError(err, in.Name).Err()
 	}
 
 	return &admin_search_v1.DeleteIndexResponse{}, nil
 }
+
+func (s *SearchIndexAdminServer) GetIndex(ctx context.Context, in *admin_search_v1.GetIndexRequest) (*admin_search_v1.GetIndexResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	index, err := agent.GetSearchIndex(ctx, &cbsearchx.GetIndexOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.GetIndexResponse{
+		Index: searchIndexFromCbsearchx(index),
+	}, nil
+}
+
+func (s *SearchIndexAdminServer) ListIndexes(ctx context.Context, in *admin_search_v1.ListIndexesRequest) (*admin_search_v1.ListIndexesResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	indexes, err := agent.GetAllSearchIndexes(ctx, &cbsearchx.GetAllIndexesOptions{
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	psIndexes := make([]*admin_search_v1.Index, len(indexes))
+	for indexIdx, index := range indexes {
+		psIndexes[indexIdx] = searchIndexFromCbsearchx(&index)
+	}
+
+	return &admin_search_v1.ListIndexesResponse{
+		Indexes: psIndexes,
+	}, nil
+}
+
+func (s *SearchIndexAdminServer) GetIndexedDocumentsCount(
+	ctx context.Context,
+	in *admin_search_v1.GetIndexedDocumentsCountRequest,
+) (*admin_search_v1.GetIndexedDocumentsCountResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	count, err := agent.GetSearchIndexedDocumentsCount(ctx, &cbsearchx.GetIndexedDocumentsCountOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.GetIndexedDocumentsCountResponse{
+		Count: count,
+	}, nil
+}
+
+func (s *SearchIndexAdminServer) PauseIndexIngest(
+	ctx context.Context,
+	in *admin_search_v1.PauseIndexIngestRequest,
+) (*admin_search_v1.PauseIndexIngestResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.PauseSearchIndexIngest(ctx, &cbsearchx.PauseIngestOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.PauseIndexIngestResponse{}, nil
+}
+
+func (s *SearchIndexAdminServer) ResumeIndexIngest(
+	ctx context.Context,
+	in *admin_search_v1.ResumeIndexIngestRequest,
+) (*admin_search_v1.ResumeIndexIngestResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.ResumeSearchIndexIngest(ctx, &cbsearchx.ResumeIngestOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.ResumeIndexIngestResponse{}, nil
+}
+
+func (s *SearchIndexAdminServer) AllowIndexQuerying(
+	ctx context.Context,
+	in *admin_search_v1.AllowIndexQueryingRequest,
+) (*admin_search_v1.AllowIndexQueryingResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.AllowSearchIndexQuerying(ctx, &cbsearchx.AllowQueryingOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.AllowIndexQueryingResponse{}, nil
+}
+
+func (s *SearchIndexAdminServer) DisallowIndexQuerying(
+	ctx context.Context,
+	in *admin_search_v1.DisallowIndexQueryingRequest,
+) (*admin_search_v1.DisallowIndexQueryingResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.DisallowSearchIndexQuerying(ctx, &cbsearchx.DisallowQueryingOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.DisallowIndexQueryingResponse{}, nil
+}
+
+func (s *SearchIndexAdminServer) FreezeIndexPlan(
+	ctx context.Context,
+	in *admin_search_v1.FreezeIndexPlanRequest,
+) (*admin_search_v1.FreezeIndexPlanResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.FreezeSearchIndexPlan(ctx, &cbsearchx.FreezePlanOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.FreezeIndexPlanResponse{}, nil
+}
+
+func (s *SearchIndexAdminServer) UnfreezeIndexPlan(
+	ctx context.Context,
+	in *admin_search_v1.UnfreezeIndexPlanRequest,
+) (*admin_search_v1.UnfreezeIndexPlanResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.UnfreezeSearchIndexPlan(ctx, &cbsearchx.UnfreezePlanOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.UnfreezeIndexPlanResponse{}, nil
+}
+
+func (s *SearchIndexAdminServer) AnalyzeDocument(
+	ctx context.Context,
+	in *admin_search_v1.AnalyzeDocumentRequest,
+) (*admin_search_v1.AnalyzeDocumentResponse, error) {
+	agent, oboInfo, errSt := s.getSearchAgent(ctx, in.BucketName, in.ScopeName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if !json.Valid(in.Doc) {
+		return nil, status.Errorf(codes.InvalidArgument, "document to analyze must be valid JSON")
+	}
+
+	result, err := agent.AnalyzeDocument(ctx, &cbsearchx.AnalyzeDocumentOptions{
+		IndexName:  in.Name,
+		BucketName: in.GetBucketName(),
+		ScopeName:  in.GetScopeName(),
+		DocContent: in.Doc,
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.translateError(err, in.Name).Err()
+	}
+
+	return &admin_search_v1.AnalyzeDocumentResponse{
+		Status:   result.Status,
+		Analyzed: result.Analyzed,
+	}, nil
+}
@@ -1,9 +1,12 @@
 package server_v1
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/couchbase/gocbcorex/cbsearchx"
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/search_v1"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -15,31 +18,73 @@ import (
 type SearchServer struct {
 	search_v1.UnimplementedSearchServiceServer
 
-	logger       *zap.Logger
-	errorHandler *ErrorHandler
-	authHandler  *AuthHandler
+	logger         *zap.Logger
+	errorHandler   *ErrorHandler
+	authHandler    *AuthHandler
+	rowBatcherOpts *RowBatcherOptions
 }
 
 func NewSearchServer(
 	logger *zap.Logger,
 	errorHandler *ErrorHandler,
 	authHandler *AuthHandler,
+	rowBatcherOpts *RowBatcherOptions,
 ) *SearchServer {
 	return &SearchServer{
-		logger:       logger,
-		errorHandler: errorHandler,
-		authHandler:  authHandler,
+		logger:         logger,
+		errorHandler:   errorHandler,
+		authHandler:    authHandler,
+		rowBatcherOpts: rowBatcherOpts,
 	}
 }
 
+// consistencyVectorsFromMutationTokens builds the at_plus consistency vectors for an
+// index, keeping only the newest sequence number seen for each vbucket.  An index is
+// sourced from a single bucket, so all of the tokens must belong to the same bucket.
+// This is validated without fetching the index definition, to avoid adding a
+// management request to the query path.
+func (s *SearchServer) consistencyVectorsFromMutationTokens(
+	tokens []*kv_v1.MutationToken,
+) (cbsearchx.ConsistencyVectors, *status.Status) {
+	vectors := make(cbsearchx.ConsistencyVectors, len(tokens))
+	for _, token := range tokens {
+		if token.BucketName == "" {
+			return nil, status.New(codes.InvalidArgument, "mutation tokens must specify a bucket name")
+		}
+		if token.BucketName != tokens[0].BucketName {
+			return nil, status.New(codes.InvalidArgument,
+				fmt.Sprintf("mutation tokens for buckets '%s' and '%s' cannot be used together, as an index is sourced from a single bucket",
+					tokens[0].BucketName, token.BucketName))
+		}
+
+		vectorKey := fmt.Sprintf("%d/%d", token.VbucketId, token.VbucketUuid)
+		if existing, ok := vectors[vectorKey]; ok && existing >= token.SeqNo {
+			continue
+		}
+
+		vectors[vectorKey] = token.SeqNo
+	}
+
+	return vectors, nil
+}
+
+func (s *SearchServer) translateError(err error, indexName string) *status.Status {
+	if errors.Is(err, cbsearchx.ErrConsistencyMismatch) {
+		return s.errorHandler.NewSearchConsistencyTimeoutStatus(err, indexName)
+	} else if errors.Is(err, cbsearchx.ErrIndexNotFound) {
+		return s.errorHandler.NewSearchIndexMissingStatus(err, indexName)
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
 func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_v1.SearchService_SearchQueryServer) error {
 	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(out.Context(), nil)
 	if errSt != nil {
 		return errSt.Err()
 	}
 
-	if in.Query == nil {
-		return status.Errorf(codes.InvalidArgument, "query option must be specified")
+	if in.Query == nil && len(in.Knn) == 0 {
+		return status.Errorf(codes.InvalidArgument, "query or knn option must be specified")
 	}
 	if in.IndexName == "" {
 		return status.Errorf(codes.InvalidArgument, "index name option must be specified")
@@ -50,7 +95,6 @@ func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_
 
 	opts.Collections = in.Collections
 
-	// At present protostellar only supports not bounded.
 	switch in.ScanConsistency {
 	case search_v1.SearchQueryRequest_SCAN_CONSISTENCY_NOT_BOUNDED:
 		opts.Control = &cbsearchx.Control{
@@ -62,6 +106,22 @@ func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_
 		return status.Errorf(codes.InvalidArgument, "invalid scan consistency option specified")
 	}
 
+	// when mutation tokens are provided, we wait for the index to have caught up
+	// to those mutations before executing the search.
+	if len(in.ConsistentWith) > 0 {
+		vectors, errSt := s.consistencyVectorsFromMutationTokens(in.ConsistentWith)
+		if errSt != nil {
+			return errSt.Err()
+		}
+
+		opts.Control.Consistency = &cbsearchx.Consistency{
+			Level: cbsearchx.ConsistencyLevelAtPlus,
+			Vectors: map[string]cbsearchx.ConsistencyVectors{
+				in.IndexName: vectors,
+			},
+		}
+	}
+
 	opts.Explain = in.IncludeExplanation
 
 	if len(in.Facets) > 0 {
@@ -133,12 +193,50 @@ func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_
 	opts.IncludeLocations = in.IncludeLocations
 
 	var err error
-	opts.Query, err = s.translatePSQueryToCBSearchX(in.Query)
-	if err != nil {
-		return err
+	if in.Query != nil {
+		opts.Query, err = s.translatePSQueryToCBSearchX(in.Query)
+		if err != nil {
+			return err
+		}
+	} else {
+		// a pure vector search still requires a classic query to be specified,
+		// so we use one which matches nothing and let the knn results through.
+		opts.Query = &cbsearchx.MatchNoneQuery{}
+	}
+
+	if len(in.Knn) > 0 {
+		opts.Knn, err = s.translatePSKnnToCBSearchX(in.Knn)
+		if err != nil {
+			return err
+		}
+
+		if in.KnnOperator != nil {
+			switch *in.KnnOperator {
+			case search_v1.SearchQueryRequest_KNN_OPERATOR_OR:
+				opts.KnnOperator = cbsearchx.KnnOperatorOr
+			case search_v1.SearchQueryRequest_KNN_OPERATOR_AND:
+				// without a classic query we substitute one which matches nothing,
+				// and requiring hits to match that as well would never return any.
+				if in.Query == nil {
+					return status.Errorf(codes.InvalidArgument, "knn operator and requires a query to be specified")
+				}
+
+				opts.KnnOperator = cbsearchx.KnnOperatorAnd
+			default:
+				return status.Errorf(codes.InvalidArgument, "invalid knn operator option specified")
+			}
+		}
+	} else if in.KnnOperator != nil {
+		return status.Errorf(codes.InvalidArgument, "knn operator cannot be specified without a knn query")
 	}
 
 	if in.DisableScoring {
+		// vector search results are ranked by their similarity score, which
+		// the server does not compute if scoring is disabled.
+		if len(in.Knn) > 0 {
+			return status.Errorf(codes.InvalidArgument, "scoring cannot be disabled for knn queries")
+		}
+
 		opts.Score = "none"
 	}
 
@@ -157,17 +255,20 @@ func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_
 
 	result, err := agent.Search(out.Context(), &opts)
 	if err != nil {
-		return s.errorHandler.NewGenericStatus(err).Err()
+		return s.translateError(err, in.IndexName).Err()
 	}
 
-	var rowCache []*search_v1.SearchQueryResponse_SearchQueryRow
-	rowCacheNumBytes := 0
-	const MaxRowBytes = 1024
+	batcher := newRowBatcher(s.rowBatcherOpts, func(rows []*search_v1.SearchQueryResponse_SearchQueryRow) error {
+		return out.Send(&search_v1.SearchQueryResponse{
+			Hits: rows,
+		})
+	})
+	defer batcher.Close()
 
 	for result.HasMoreHits() {
 		row, err := result.ReadHit()
 		if err != nil {
-			return s.errorHandler.NewGenericStatus(err).Err()
+			return s.translateError(err, in.IndexName).Err()
 		}
 
 		fragments := make(map[string]*search_v1.SearchQueryResponse_Fragment, len(row.Fragments))
@@ -209,20 +310,10 @@ func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_
 			Fragments:   fragments,
 		}
 
-		psRowSize := proto.Size(psRow)
-		if rowCacheNumBytes+psRowSize > MaxRowBytes {
-			err := out.Send(&search_v1.SearchQueryResponse{
-				Hits: rowCache,
-			})
-			if err != nil {
-				return s.errorHandler.NewGenericStatus(err).Err()
-			}
-			rowCache = nil
-			rowCacheNumBytes = 0
+		err = batcher.Add(psRow, proto.Size(psRow))
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
 		}
-
-		rowCache = append(rowCache, psRow)
-		rowCacheNumBytes += psRowSize
 	}
 
 	facetsPs := make(map[string]*search_v1.SearchQueryResponse_FacetResult)
@@ -331,6 +422,7 @@ func (s *SearchServer) SearchQuery(in *search_v1.SearchQueryRequest, out search_
 
 	// if we have any rows, meta-data, or facets left to stream, we send that first
 	// before we process any errors that occurred.
+	rowCache := batcher.Take()
 	if len(rowCache) > 0 || metadataPs != nil || len(facetsPs) > 0 {
 		err := out.Send(&search_v1.SearchQueryResponse{
 			Hits:     rowCache,
@@ -387,6 +479,41 @@ func (s *SearchServer) translatePSSortToCBSearchX(in []*search_v1.Sorting) ([]cb
 	return sorts, nil
 }
 
+func (s *SearchServer) translatePSKnnToCBSearchX(in []*search_v1.KnnQuery) ([]cbsearchx.KnnQuery, error) {
+	knnQueries := make([]cbsearchx.KnnQuery, len(in))
+	for i, knn := range in {
+		if knn.Field == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "knn query field must be specified")
+		}
+		if len(knn.Vector) == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "knn query vector must be specified")
+		}
+		if knn.K == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "knn query k must be greater than 0")
+		}
+
+		knnQuery := cbsearchx.KnnQuery{
+			Field:  knn.Field,
+			Vector: knn.Vector,
+			K:      int64(knn.K),
+			Boost:  knn.GetBoost(),
+		}
+
+		if knn.Filter != nil {
+			filter, err := s.translatePSQueryToCBSearchX(knn.Filter)
+			if err != nil {
+				return nil, err
+			}
+
+			knnQuery.Filter = filter
+		}
+
+		knnQueries[i] = knnQuery
+	}
+
+	return knnQueries, nil
+}
+
 func (s *SearchServer) translatePSQueryToCBSearchX(in *search_v1.Query) (cbsearchx.Query, error) {
 	switch query := in.Query.(type) {
 	case *search_v1.Query_BooleanFieldQuery:
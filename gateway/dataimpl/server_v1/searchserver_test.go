@@ -0,0 +1,96 @@
+package server_v1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/couchbase/gocbcorex/cbsearchx"
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"go.uber.org/zap"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func newTestSearchServer() *SearchServer {
+	return NewSearchServer(zap.NewNop(), &ErrorHandler{Logger: zap.NewNop()}, nil, nil)
+}
+
+func TestSearchConsistencyVectorsFromMutationTokens(t *testing.T) {
+	s := newTestSearchServer()
+
+	vectors, errSt := s.consistencyVectorsFromMutationTokens([]*kv_v1.MutationToken{
+		{BucketName: "default", VbucketId: 1, VbucketUuid: 100, SeqNo: 5},
+		{BucketName: "default", VbucketId: 1, VbucketUuid: 100, SeqNo: 3},
+		{BucketName: "default", VbucketId: 2, VbucketUuid: 200, SeqNo: 7},
+	})
+	if errSt != nil {
+		t.Fatalf("failed to build consistency vectors: %s", errSt.Message())
+	}
+
+	if len(vectors) != 2 {
+		t.Fatalf("expected 2 vector entries, got %d", len(vectors))
+	}
+	if vectors["1/100"] != 5 {
+		t.Fatalf("expected the newest seqno to be kept, got %d", vectors["1/100"])
+	}
+	if vectors["2/200"] != 7 {
+		t.Fatalf("unexpected seqno for vbucket 2, got %d", vectors["2/200"])
+	}
+}
+
+func TestSearchConsistencyVectorsInvalidBuckets(t *testing.T) {
+	s := newTestSearchServer()
+
+	testCases := []struct {
+		name   string
+		tokens []*kv_v1.MutationToken
+	}{
+		{
+			name: "MixedBuckets",
+			tokens: []*kv_v1.MutationToken{
+				{BucketName: "default", VbucketId: 1, VbucketUuid: 100, SeqNo: 5},
+				{BucketName: "other", VbucketId: 2, VbucketUuid: 200, SeqNo: 7},
+			},
+		},
+		{
+			name: "MissingBucket",
+			tokens: []*kv_v1.MutationToken{
+				{VbucketId: 1, VbucketUuid: 100, SeqNo: 5},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errSt := s.consistencyVectorsFromMutationTokens(tc.tokens)
+			if errSt == nil {
+				t.Fatalf("expected the tokens to be rejected")
+			}
+			if errSt.Code() != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument, got %s", errSt.Code())
+			}
+		})
+	}
+}
+
+func TestSearchTranslateConsistencyMismatch(t *testing.T) {
+	s := newTestSearchServer()
+
+	st := s.translateError(fmt.Errorf("search failed: %w", cbsearchx.ErrConsistencyMismatch), "idx")
+	if st.Code() != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %s", st.Code())
+	}
+
+	var resourceInfo *epb.ResourceInfo
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*epb.ResourceInfo); ok {
+			resourceInfo = d
+		}
+	}
+	if resourceInfo == nil {
+		t.Fatalf("expected resource info details to be attached")
+	}
+	if resourceInfo.ResourceType != "searchindex" || resourceInfo.ResourceName != "idx" {
+		t.Fatalf("unexpected resource info: %s/%s", resourceInfo.ResourceType, resourceInfo.ResourceName)
+	}
+}
@@ -0,0 +1,263 @@
+package server_v1
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// transactionsNumAtrs is the number of active transaction records that are
+	// spread across each bucket.  This matches the SDK default so that cleanup
+	// performed by the SDKs will also discover attempts created by the gateway.
+	transactionsNumAtrs = 1024
+
+	// transactionsDefaultExpiry is how long an attempt is allowed to remain
+	// pending before it is considered expired.
+	transactionsDefaultExpiry = 15 * time.Second
+
+	transactionsAtrScopeName      = "_default"
+	transactionsAtrCollectionName = "_default"
+)
+
+type transactionAttemptState int
+
+const (
+	transactionAttemptStatePending transactionAttemptState = iota
+	transactionAttemptStateCommitted
+	transactionAttemptStateRolledBack
+)
+
+type transactionStagedOpType string
+
+const (
+	transactionStagedOpTypeInsert  = transactionStagedOpType("insert")
+	transactionStagedOpTypeReplace = transactionStagedOpType("replace")
+	transactionStagedOpTypeRemove  = transactionStagedOpType("remove")
+)
+
+type transactionStagedMutation struct {
+	OpType         transactionStagedOpType
+	ScopeName      string
+	CollectionName string
+	Key            string
+	Cas            uint64
+	Value          []byte
+}
+
+// transactionAttempt holds the gateway-side state of a single transaction
+// attempt.  All of the staged data lives in the documents themselves, this
+// simply tracks what needs to be unstaged when the attempt completes.
+//
+// Note that this state is held only in the memory of the gateway instance which
+// began the attempt.  When multiple gateway instances are deployed behind a load
+// balancer, all of the requests for an attempt must be routed to the same
+// instance (for instance via client affinity), otherwise the attempt will not be
+// found.  Every document is recorded in the attempt's ATR entry before its data
+// is staged, so attempts which are lost this way (or abandoned by the client)
+// are rolled back by the standard SDK cleanup once they expire.
+type transactionAttempt struct {
+	lock sync.Mutex
+
+	TransactionID string
+	AttemptID     string
+	BucketName    string
+	ExpiryTime    time.Time
+	State         transactionAttemptState
+
+	// AtrKey is blank until the first mutation is staged.
+	AtrKey    string
+	Mutations []*transactionStagedMutation
+}
+
+func (a *transactionAttempt) isExpired() bool {
+	return time.Now().After(a.ExpiryTime)
+}
+
+func (a *transactionAttempt) findMutation(scopeName, collectionName, key string) (int, *transactionStagedMutation) {
+	for mutIdx, mut := range a.Mutations {
+		if mut.ScopeName == scopeName && mut.CollectionName == collectionName && mut.Key == key {
+			return mutIdx, mut
+		}
+	}
+	return -1, nil
+}
+
+func (a *transactionAttempt) removeMutation(mutIdx int) {
+	a.Mutations = append(a.Mutations[:mutIdx], a.Mutations[mutIdx+1:]...)
+}
+
+// putMutation records a newly staged mutation, replacing any mutation which was
+// previously staged against the same document.  This must only be called once
+// the new mutation has been successfully staged, otherwise we would lose track
+// of a document which still holds our staged metadata.
+func (a *transactionAttempt) putMutation(mut *transactionStagedMutation) {
+	mutIdx, _ := a.findMutation(mut.ScopeName, mut.CollectionName, mut.Key)
+	if mutIdx >= 0 {
+		a.Mutations[mutIdx] = mut
+		return
+	}
+
+	a.Mutations = append(a.Mutations, mut)
+}
+
+type transactionAttemptRegistry struct {
+	lock     sync.Mutex
+	attempts map[string]*transactionAttempt
+}
+
+func newTransactionAttemptRegistry() *transactionAttemptRegistry {
+	return &transactionAttemptRegistry{
+		attempts: make(map[string]*transactionAttempt),
+	}
+}
+
+func (r *transactionAttemptRegistry) makeKey(transactionID, attemptID string) string {
+	return transactionID + "/" + attemptID
+}
+
+func (r *transactionAttemptRegistry) Add(attempt *transactionAttempt) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// we take the opportunity to drop any attempts which were abandoned by
+	// the client, their staged data is left for the standard cleanup process.
+	for key, existing := range r.attempts {
+		if time.Since(existing.ExpiryTime) > transactionsDefaultExpiry {
+			delete(r.attempts, key)
+		}
+	}
+
+	r.attempts[r.makeKey(attempt.TransactionID, attempt.AttemptID)] = attempt
+}
+
+func (r *transactionAttemptRegistry) Get(transactionID, attemptID string) *transactionAttempt {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.attempts[r.makeKey(transactionID, attemptID)]
+}
+
+func (r *transactionAttemptRegistry) Remove(transactionID, attemptID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.attempts, r.makeKey(transactionID, attemptID))
+}
+
+type transactionXattrIdJson struct {
+	Transaction string `json:"txn"`
+	Attempt     string `json:"atmpt"`
+}
+
+type transactionXattrAtrJson struct {
+	DocID          string `json:"id"`
+	BucketName     string `json:"bkt"`
+	ScopeName      string `json:"scp"`
+	CollectionName string `json:"coll"`
+}
+
+type transactionXattrOpJson struct {
+	Type   string          `json:"type"`
+	Staged json.RawMessage `json:"stgd,omitempty"`
+}
+
+type transactionXattrJson struct {
+	ID        transactionXattrIdJson  `json:"id"`
+	ATR       transactionXattrAtrJson `json:"atr"`
+	Operation transactionXattrOpJson  `json:"op"`
+}
+
+type transactionAtrMutationJson struct {
+	BucketName     string `json:"bkt"`
+	ScopeName      string `json:"scp"`
+	CollectionName string `json:"col"`
+	DocID          string `json:"id"`
+}
+
+type transactionAtrEntryJson struct {
+	TransactionID string `json:"tid"`
+	State         string `json:"st"`
+	ExpiryTimeMs  uint64 `json:"exp"`
+
+	Inserts  []transactionAtrMutationJson `json:"ins,omitempty"`
+	Replaces []transactionAtrMutationJson `json:"rep,omitempty"`
+	Removes  []transactionAtrMutationJson `json:"rem,omitempty"`
+}
+
+// transactionAtrEntryStateJson is the subset of an ATR entry which is needed to
+// determine whether another attempt is still in progress.
+type transactionAtrEntryStateJson struct {
+	State        string `json:"st"`
+	StartCas     string `json:"tst"`
+	ExpiryTimeMs uint64 `json:"exp"`
+}
+
+type transactionVbucketHlcJson struct {
+	Now string `json:"now"`
+}
+
+// transactionsCasToMs converts an expanded ${Mutation.CAS} macro, which is
+// a little-endian hex encoding of the CAS, into a time in milliseconds.
+func transactionsCasToMs(casStr string) (uint64, error) {
+	casBytes, err := hex.DecodeString(strings.TrimPrefix(casStr, "0x"))
+	if err != nil {
+		return 0, err
+	}
+	if len(casBytes) != 8 {
+		return 0, errors.New("invalid cas macro length")
+	}
+
+	return binary.LittleEndian.Uint64(casBytes) / uint64(time.Millisecond), nil
+}
+
+// transactionsAtrEntryExpired checks whether an ATR entry has outlived its
+// expiry, using the hybrid logical clock of the vbucket holding the ATR.
+func transactionsAtrEntryExpired(entry *transactionAtrEntryStateJson, hlc *transactionVbucketHlcJson) (bool, error) {
+	startMs, err := transactionsCasToMs(entry.StartCas)
+	if err != nil {
+		return false, err
+	}
+
+	nowSecs, err := strconv.ParseUint(hlc.Now, 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	nowMs := nowSecs * 1000
+	return nowMs > startMs && nowMs-startMs > entry.ExpiryTimeMs, nil
+}
+
+const (
+	transactionAtrStatePending    = "PENDING"
+	transactionAtrStateCommitted  = "COMMITTED"
+	transactionAtrStateCompleted  = "COMPLETED"
+	transactionAtrStateAborted    = "ABORTED"
+	transactionAtrStateRolledBack = "ROLLED_BACK"
+)
+
+func transactionsVbucketForKey(key []byte, numVbuckets uint32) uint32 {
+	crc := crc32.ChecksumIEEE(key)
+	return ((crc >> 16) & 0x7fff) % numVbuckets
+}
+
+// transactionsAtrKeyForKey returns the ATR document key which should be used for
+// an attempt whose first mutation is against the specified key.  The ATR key is
+// chosen such that it lives within the same vbucket as the key.
+func transactionsAtrKeyForKey(key []byte) string {
+	atrIdx := transactionsVbucketForKey(key, transactionsNumAtrs)
+
+	for suffix := 0; ; suffix++ {
+		atrKey := fmt.Sprintf("_txn:atr-%d-#%x", atrIdx, suffix)
+		if transactionsVbucketForKey([]byte(atrKey), transactionsNumAtrs) == atrIdx {
+			return atrKey
+		}
+	}
+}
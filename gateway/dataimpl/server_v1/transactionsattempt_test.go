@@ -0,0 +1,48 @@
+package server_v1
+
+import "testing"
+
+func TestTransactionsCasToMs(t *testing.T) {
+	ms, err := transactionsCasToMs("0x00002a36fe9c9717")
+	if err != nil {
+		t.Fatalf("failed to parse cas: %s", err)
+	}
+	if ms != 1700000000000 {
+		t.Fatalf("unexpected cas time, got %d", ms)
+	}
+
+	_, err = transactionsCasToMs("0x1234")
+	if err == nil {
+		t.Fatalf("expected a short cas to be rejected")
+	}
+}
+
+func TestTransactionsAtrEntryExpired(t *testing.T) {
+	testCases := []struct {
+		name     string
+		now      string
+		expected bool
+	}{
+		{name: "BeforeStart", now: "1699999990", expected: false},
+		{name: "WithinExpiry", now: "1700000010", expected: false},
+		{name: "PastExpiry", now: "1700000016", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expired, err := transactionsAtrEntryExpired(&transactionAtrEntryStateJson{
+				State:        transactionAtrStatePending,
+				StartCas:     "0x00002a36fe9c9717",
+				ExpiryTimeMs: 15000,
+			}, &transactionVbucketHlcJson{
+				Now: tc.now,
+			})
+			if err != nil {
+				t.Fatalf("failed to check expiry: %s", err)
+			}
+			if expired != tc.expected {
+				t.Fatalf("expected expired to be %t", tc.expected)
+			}
+		})
+	}
+}
@@ -1,8 +1,18 @@
 package server_v1
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/memdx"
 	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type TransactionsServer struct {
@@ -11,6 +21,8 @@ type TransactionsServer struct {
 	logger       *zap.Logger
 	errorHandler *ErrorHandler
 	authHandler  *AuthHandler
+
+	attempts *transactionAttemptRegistry
 }
 
 func NewTransactionsServer(
@@ -22,5 +34,1094 @@ func NewTransactionsServer(
 		logger:       logger,
 		errorHandler: errorHandler,
 		authHandler:  authHandler,
+		attempts:     newTransactionAttemptRegistry(),
+	}
+}
+
+func (s *TransactionsServer) translateDocError(
+	err error,
+	bucketName, scopeName, collectionName, key string,
+) *status.Status {
+	if errors.Is(err, memdx.ErrCasMismatch) {
+		return s.errorHandler.NewDocCasMismatchStatus(err, bucketName, scopeName, collectionName, key)
+	} else if errors.Is(err, memdx.ErrDocLocked) {
+		return s.errorHandler.NewDocLockedStatus(err, bucketName, scopeName, collectionName, key)
+	} else if errors.Is(err, memdx.ErrDocNotFound) {
+		return s.errorHandler.NewDocMissingStatus(err, bucketName, scopeName, collectionName, key)
+	} else if errors.Is(err, memdx.ErrDocExists) {
+		return s.errorHandler.NewDocExistsStatus(err, bucketName, scopeName, collectionName, key)
+	} else if errors.Is(err, memdx.ErrUnknownCollectionName) {
+		return s.errorHandler.NewCollectionMissingStatus(err, bucketName, scopeName, collectionName)
+	} else if errors.Is(err, memdx.ErrUnknownScopeName) {
+		return s.errorHandler.NewScopeMissingStatus(err, bucketName, scopeName)
+	} else if errors.Is(err, memdx.ErrAccessError) {
+		return s.errorHandler.NewCollectionNoWriteAccessStatus(err, bucketName, scopeName, collectionName)
+	}
+	return s.errorHandler.NewGenericStatus(err)
+}
+
+// getPendingAttempt fetches an attempt and validates that it is still able to
+// perform operations.  The returned attempt is locked and must be unlocked by
+// the caller.
+func (s *TransactionsServer) getPendingAttempt(
+	bucketName, transactionID, attemptID string,
+) (*transactionAttempt, *status.Status) {
+	attempt := s.attempts.Get(transactionID, attemptID)
+	if attempt == nil {
+		return nil, s.errorHandler.NewTransactionAttemptMissingStatus(transactionID, attemptID)
+	}
+
+	attempt.lock.Lock()
+
+	if attempt.BucketName != bucketName {
+		attempt.lock.Unlock()
+		return nil, status.New(codes.InvalidArgument, "bucket name does not match the bucket the transaction was started in")
+	}
+
+	if attempt.State != transactionAttemptStatePending {
+		attempt.lock.Unlock()
+		return nil, s.errorHandler.NewTransactionNotPendingStatus(transactionID, attemptID)
+	}
+
+	return attempt, nil
+}
+
+type transactionDocInfo struct {
+	Cas       uint64
+	IsDeleted bool
+	Value     []byte
+	TxnMeta   *transactionXattrJson
+}
+
+func (s *TransactionsServer) lookupDoc(
+	ctx context.Context,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	scopeName, collectionName, key string,
+) (*transactionDocInfo, error) {
+	result, err := bucketAgent.LookupIn(ctx, &gocbcorex.LookupInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+		Key:            []byte(key),
+		Flags:          memdx.SubdocDocFlagAccessDeleted,
+		Ops: []memdx.LookupInOp{
+			{
+				Op:    memdx.LookupInOpTypeGet,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte("txn"),
+			},
+			{
+				Op:    memdx.LookupInOpTypeGet,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte("$document.deleted"),
+			},
+			{
+				Op:    memdx.LookupInOpTypeGetDoc,
+				Flags: memdx.SubdocOpFlagNone,
+				Path:  nil,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &transactionDocInfo{
+		Cas: result.Cas,
+	}
+
+	if result.Ops[0].Err == nil {
+		var txnMeta transactionXattrJson
+		err := json.Unmarshal(result.Ops[0].Value, &txnMeta)
+		if err != nil {
+			return nil, err
+		}
+
+		info.TxnMeta = &txnMeta
+	} else if !errors.Is(result.Ops[0].Err, memdx.ErrSubDocPathNotFound) {
+		return nil, result.Ops[0].Err
+	}
+
+	if result.Ops[1].Err != nil {
+		return nil, result.Ops[1].Err
+	}
+	err = json.Unmarshal(result.Ops[1].Value, &info.IsDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Ops[2].Err == nil {
+		info.Value = result.Ops[2].Value
+	}
+
+	return info, nil
+}
+
+// fetchForeignAtrState looks up the ATR state of another transaction attempt
+// which has staged data in a document we are accessing, and whether that attempt
+// has expired.  A blank state indicates that the attempt no longer exists in its ATR.
+func (s *TransactionsServer) fetchForeignAtrState(
+	ctx context.Context,
+	oboUser string,
+	txnMeta *transactionXattrJson,
+) (string, bool, error) {
+	atrAgent, err := s.authHandler.CbClient.GetBucketAgent(ctx, txnMeta.ATR.BucketName)
+	if err != nil {
+		return "", false, err
+	}
+
+	result, err := atrAgent.LookupIn(ctx, &gocbcorex.LookupInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      txnMeta.ATR.ScopeName,
+		CollectionName: txnMeta.ATR.CollectionName,
+		Key:            []byte(txnMeta.ATR.DocID),
+		Ops: []memdx.LookupInOp{
+			{
+				Op:    memdx.LookupInOpTypeGet,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte("attempts." + txnMeta.ID.Attempt),
+			},
+			{
+				Op:    memdx.LookupInOpTypeGet,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte("$vbucket.HLC"),
+			},
+		},
+	})
+	if err != nil {
+		if errors.Is(err, memdx.ErrDocNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	if result.Ops[0].Err != nil {
+		if errors.Is(result.Ops[0].Err, memdx.ErrSubDocPathNotFound) {
+			return "", false, nil
+		}
+		return "", false, result.Ops[0].Err
+	}
+
+	var atrEntry transactionAtrEntryStateJson
+	err = json.Unmarshal(result.Ops[0].Value, &atrEntry)
+	if err != nil {
+		return "", false, err
+	}
+
+	if result.Ops[1].Err != nil {
+		return "", false, result.Ops[1].Err
+	}
+
+	var hlc transactionVbucketHlcJson
+	err = json.Unmarshal(result.Ops[1].Value, &hlc)
+	if err != nil {
+		return "", false, err
+	}
+
+	expired, err := transactionsAtrEntryExpired(&atrEntry, &hlc)
+	if err != nil {
+		return "", false, err
+	}
+
+	return atrEntry.State, expired, nil
+}
+
+// checkWriteWriteConflict returns a status if the document has data staged within it
+// by another transaction attempt which is still in progress.
+func (s *TransactionsServer) checkWriteWriteConflict(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	oboUser string,
+	docInfo *transactionDocInfo,
+	scopeName, collectionName, key string,
+) *status.Status {
+	if docInfo.TxnMeta == nil || docInfo.TxnMeta.ID.Attempt == attempt.AttemptID {
+		return nil
+	}
+
+	atrState, expired, err := s.fetchForeignAtrState(ctx, oboUser, docInfo.TxnMeta)
+	if err != nil {
+		return s.errorHandler.NewGenericStatus(err)
+	}
+
+	switch atrState {
+	case transactionAtrStatePending:
+		// a pending attempt which has expired will never commit, so its staged
+		// data can be overwritten, leaving the ATR entry for cleanup.
+		if expired {
+			return nil
+		}
+		return s.errorHandler.NewWriteWriteConflictStatus(nil, attempt.BucketName, scopeName, collectionName, key)
+	case transactionAtrStateCommitted, transactionAtrStateAborted:
+		return s.errorHandler.NewWriteWriteConflictStatus(nil, attempt.BucketName, scopeName, collectionName, key)
+	}
+
+	// the other attempt has completed, so whatever it left behind is stale.
+	return nil
+}
+
+func (s *TransactionsServer) ensureAtrEntry(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	firstKey string,
+) *status.Status {
+	if attempt.AtrKey != "" {
+		return nil
+	}
+
+	atrKey := transactionsAtrKeyForKey([]byte(firstKey))
+
+	entryBytes, err := json.Marshal(transactionAtrEntryJson{
+		TransactionID: attempt.TransactionID,
+		State:         transactionAtrStatePending,
+		ExpiryTimeMs:  uint64(transactionsDefaultExpiry / time.Millisecond),
+	})
+	if err != nil {
+		return s.errorHandler.NewGenericStatus(err)
+	}
+
+	entryPath := "attempts." + attempt.AttemptID
+	_, err = bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      transactionsAtrScopeName,
+		CollectionName: transactionsAtrCollectionName,
+		Key:            []byte(atrKey),
+		Flags:          memdx.SubdocDocFlagMkDoc,
+		Ops: []memdx.MutateInOp{
+			{
+				Op:    memdx.MutateInOpTypeDictSet,
+				Flags: memdx.SubdocOpFlagXattrPath | memdx.SubdocOpFlagMkDirP,
+				Path:  []byte(entryPath),
+				Value: entryBytes,
+			},
+			{
+				Op:    memdx.MutateInOpTypeDictSet,
+				Flags: memdx.SubdocOpFlagXattrPath | memdx.SubdocOpFlagExpandMacros,
+				Path:  []byte(entryPath + ".tst"),
+				Value: []byte(`"${Mutation.CAS}"`),
+			},
+		},
+	})
+	if err != nil {
+		return s.errorHandler.NewGenericStatus(err)
+	}
+
+	attempt.AtrKey = atrKey
+	return nil
+}
+
+func (s *TransactionsServer) setAtrState(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	atrState string,
+	includeMutations bool,
+) error {
+	entryPath := "attempts." + attempt.AttemptID
+
+	stateBytes, _ := json.Marshal(atrState)
+	ops := []memdx.MutateInOp{
+		{
+			Op:    memdx.MutateInOpTypeDictSet,
+			Flags: memdx.SubdocOpFlagXattrPath,
+			Path:  []byte(entryPath + ".st"),
+			Value: stateBytes,
+		},
+	}
+
+	if includeMutations {
+		var entry transactionAtrEntryJson
+		for _, mut := range attempt.Mutations {
+			atrMut := transactionAtrMutationJson{
+				BucketName:     attempt.BucketName,
+				ScopeName:      mut.ScopeName,
+				CollectionName: mut.CollectionName,
+				DocID:          mut.Key,
+			}
+
+			switch mut.OpType {
+			case transactionStagedOpTypeInsert:
+				entry.Inserts = append(entry.Inserts, atrMut)
+			case transactionStagedOpTypeReplace:
+				entry.Replaces = append(entry.Replaces, atrMut)
+			case transactionStagedOpTypeRemove:
+				entry.Removes = append(entry.Removes, atrMut)
+			}
+		}
+
+		for path, muts := range map[string][]transactionAtrMutationJson{
+			".ins": entry.Inserts,
+			".rep": entry.Replaces,
+			".rem": entry.Removes,
+		} {
+			if muts == nil {
+				muts = []transactionAtrMutationJson{}
+			}
+
+			mutsBytes, err := json.Marshal(muts)
+			if err != nil {
+				return err
+			}
+
+			ops = append(ops, memdx.MutateInOp{
+				Op:    memdx.MutateInOpTypeDictSet,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte(entryPath + path),
+				Value: mutsBytes,
+			})
+		}
+	}
+
+	_, err := bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      transactionsAtrScopeName,
+		CollectionName: transactionsAtrCollectionName,
+		Key:            []byte(attempt.AtrKey),
+		Ops:            ops,
+	})
+	return err
+}
+
+func (s *TransactionsServer) removeAtrEntry(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+) error {
+	_, err := bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      transactionsAtrScopeName,
+		CollectionName: transactionsAtrCollectionName,
+		Key:            []byte(attempt.AtrKey),
+		Ops: []memdx.MutateInOp{
+			{
+				Op:    memdx.MutateInOpTypeDelete,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte("attempts." + attempt.AttemptID),
+			},
+		},
+	})
+	return err
+}
+
+// recordAtrMutation appends a document to the ins/rep/rem list of the attempt's
+// ATR entry.  This is done before the document is staged, so that the ATR always
+// lists every document which may hold staged data, allowing cleanup to find them
+// should the attempt be lost.  The lists are rewritten exactly at commit or
+// rollback, so entries left behind by restaged documents are harmless.
+func (s *TransactionsServer) recordAtrMutation(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	mut *transactionStagedMutation,
+) error {
+	var listPath string
+	switch mut.OpType {
+	case transactionStagedOpTypeInsert:
+		listPath = ".ins"
+	case transactionStagedOpTypeReplace:
+		listPath = ".rep"
+	case transactionStagedOpTypeRemove:
+		listPath = ".rem"
+	default:
+		return errors.New("invalid staged mutation type")
+	}
+
+	atrMutBytes, err := json.Marshal(transactionAtrMutationJson{
+		BucketName:     attempt.BucketName,
+		ScopeName:      mut.ScopeName,
+		CollectionName: mut.CollectionName,
+		DocID:          mut.Key,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      transactionsAtrScopeName,
+		CollectionName: transactionsAtrCollectionName,
+		Key:            []byte(attempt.AtrKey),
+		Ops: []memdx.MutateInOp{
+			{
+				Op:    memdx.MutateInOpTypeArrayPushLast,
+				Flags: memdx.SubdocOpFlagXattrPath | memdx.SubdocOpFlagMkDirP,
+				Path:  []byte("attempts." + attempt.AttemptID + listPath),
+				Value: atrMutBytes,
+			},
+		},
+	})
+	return err
+}
+
+// stageMutation records a mutation in the attempt's ATR entry and then writes the
+// transactional metadata (and staged content) into the txn xattr of the document,
+// returning the new CAS.
+func (s *TransactionsServer) stageMutation(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	mut *transactionStagedMutation,
+	docInfo *transactionDocInfo,
+) (uint64, error) {
+	txnMeta := transactionXattrJson{
+		ID: transactionXattrIdJson{
+			Transaction: attempt.TransactionID,
+			Attempt:     attempt.AttemptID,
+		},
+		ATR: transactionXattrAtrJson{
+			DocID:          attempt.AtrKey,
+			BucketName:     attempt.BucketName,
+			ScopeName:      transactionsAtrScopeName,
+			CollectionName: transactionsAtrCollectionName,
+		},
+		Operation: transactionXattrOpJson{
+			Type:   string(mut.OpType),
+			Staged: mut.Value,
+		},
+	}
+
+	txnMetaBytes, err := json.Marshal(txnMeta)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.recordAtrMutation(ctx, attempt, bucketAgent, oboUser, mut)
+	if err != nil {
+		return 0, err
+	}
+
+	opts := &gocbcorex.MutateInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      mut.ScopeName,
+		CollectionName: mut.CollectionName,
+		Key:            []byte(mut.Key),
+		Flags:          memdx.SubdocDocFlagAccessDeleted,
+		Ops: []memdx.MutateInOp{
+			{
+				Op:    memdx.MutateInOpTypeDictSet,
+				Flags: memdx.SubdocOpFlagXattrPath | memdx.SubdocOpFlagMkDirP,
+				Path:  []byte("txn"),
+				Value: txnMetaBytes,
+			},
+		},
+	}
+
+	if docInfo == nil {
+		// there is no document (or tombstone) at all, so we create a tombstone
+		// to hold the staged insert.
+		opts.Flags |= memdx.SubdocDocFlagCreateAsDeleted | memdx.SubdocDocFlagAddDoc
+	} else {
+		opts.Cas = docInfo.Cas
+	}
+
+	result, err := bucketAgent.MutateIn(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Cas, nil
+}
+
+func (s *TransactionsServer) unstageMutation(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	mut *transactionStagedMutation,
+) error {
+	switch mut.OpType {
+	case transactionStagedOpTypeInsert:
+		// staged inserts live in a tombstone, so we revive that tombstone with the
+		// staged content rather than creating a new document over it.
+		_, err := bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+			OnBehalfOf:     oboUser,
+			ScopeName:      mut.ScopeName,
+			CollectionName: mut.CollectionName,
+			Key:            []byte(mut.Key),
+			Cas:            mut.Cas,
+			Flags:          memdx.SubdocDocFlagAccessDeleted | memdx.SubdocDocFlagReviveDocument,
+			Ops: []memdx.MutateInOp{
+				{
+					Op:    memdx.MutateInOpTypeDelete,
+					Flags: memdx.SubdocOpFlagXattrPath,
+					Path:  []byte("txn"),
+				},
+				{
+					Op:    memdx.MutateInOpTypeSetDoc,
+					Flags: memdx.SubdocOpFlagNone,
+					Path:  nil,
+					Value: mut.Value,
+				},
+			},
+		})
+		return err
+	case transactionStagedOpTypeReplace:
+		_, err := bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+			OnBehalfOf:     oboUser,
+			ScopeName:      mut.ScopeName,
+			CollectionName: mut.CollectionName,
+			Key:            []byte(mut.Key),
+			Cas:            mut.Cas,
+			Ops: []memdx.MutateInOp{
+				{
+					Op:    memdx.MutateInOpTypeDelete,
+					Flags: memdx.SubdocOpFlagXattrPath,
+					Path:  []byte("txn"),
+				},
+				{
+					Op:    memdx.MutateInOpTypeSetDoc,
+					Flags: memdx.SubdocOpFlagNone,
+					Path:  nil,
+					Value: mut.Value,
+				},
+			},
+		})
+		return err
+	case transactionStagedOpTypeRemove:
+		_, err := bucketAgent.Delete(ctx, &gocbcorex.DeleteOptions{
+			OnBehalfOf:     oboUser,
+			ScopeName:      mut.ScopeName,
+			CollectionName: mut.CollectionName,
+			Key:            []byte(mut.Key),
+			Cas:            mut.Cas,
+		})
+		return err
+	}
+
+	return errors.New("invalid staged mutation type")
+}
+
+func (s *TransactionsServer) rollbackMutation(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	mut *transactionStagedMutation,
+) error {
+	// all staged mutations are rolled back by simply removing our staged metadata,
+	// for inserts this leaves behind a tombstone which is invisible to readers.
+	_, err := bucketAgent.MutateIn(ctx, &gocbcorex.MutateInOptions{
+		OnBehalfOf:     oboUser,
+		ScopeName:      mut.ScopeName,
+		CollectionName: mut.CollectionName,
+		Key:            []byte(mut.Key),
+		Cas:            mut.Cas,
+		Flags:          memdx.SubdocDocFlagAccessDeleted,
+		Ops: []memdx.MutateInOp{
+			{
+				Op:    memdx.MutateInOpTypeDelete,
+				Flags: memdx.SubdocOpFlagXattrPath,
+				Path:  []byte("txn"),
+			},
+		},
+	})
+	return err
+}
+
+func (s *TransactionsServer) TransactionBeginAttempt(
+	ctx context.Context,
+	in *transactions_v1.TransactionBeginAttemptRequest,
+) (*transactions_v1.TransactionBeginAttemptResponse, error) {
+	// we fetch the agent here to validate the users credentials and the bucket.
+	_, _, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	transactionID := uuid.NewString()
+	if in.TransactionId != nil {
+		transactionID = *in.TransactionId
+
+		if transactionID == "" {
+			return nil, status.New(codes.InvalidArgument, "transaction id must not be blank when specified").Err()
+		}
+	}
+
+	attempt := &transactionAttempt{
+		TransactionID: transactionID,
+		AttemptID:     uuid.NewString(),
+		BucketName:    in.BucketName,
+		ExpiryTime:    time.Now().Add(transactionsDefaultExpiry),
+		State:         transactionAttemptStatePending,
+	}
+	s.attempts.Add(attempt)
+
+	return &transactions_v1.TransactionBeginAttemptResponse{
+		TransactionId: attempt.TransactionID,
+		AttemptId:     attempt.AttemptID,
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionCommit(
+	ctx context.Context,
+	in *transactions_v1.TransactionCommitRequest,
+) (*transactions_v1.TransactionCommitResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	attempt, errSt := s.getPendingAttempt(in.BucketName, in.TransactionId, in.AttemptId)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+	defer attempt.lock.Unlock()
+
+	if attempt.isExpired() {
+		return nil, s.errorHandler.NewTransactionExpiredStatus(in.TransactionId, in.AttemptId).Err()
+	}
+
+	// a read-only transaction never wrote an ATR entry, so there is nothing to commit.
+	if attempt.AtrKey == "" {
+		attempt.State = transactionAttemptStateCommitted
+		s.attempts.Remove(in.TransactionId, in.AttemptId)
+		return &transactions_v1.TransactionCommitResponse{}, nil
+	}
+
+	// once the ATR entry is marked committed, the transaction is logically committed
+	// and the remaining steps will be completed by cleanup if we fail here.
+	err := s.setAtrState(ctx, attempt, bucketAgent, oboUser, transactionAtrStateCommitted, true)
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	attempt.State = transactionAttemptStateCommitted
+	s.attempts.Remove(in.TransactionId, in.AttemptId)
+
+	// from this point on the transaction has been committed, so failures are not
+	// reported to the client.  Any mutations we fail to unstage are left for the
+	// standard cleanup process, which discovers them via the COMMITTED ATR entry.
+	unstageFailed := false
+	for _, mut := range attempt.Mutations {
+		err := s.unstageMutation(ctx, attempt, bucketAgent, oboUser, mut)
+		if err != nil {
+			s.logger.Warn("failed to unstage committed transaction mutation",
+				zap.Error(err),
+				zap.String("transactionId", in.TransactionId),
+				zap.String("attemptId", in.AttemptId),
+				zap.String("key", mut.Key))
+			unstageFailed = true
+		}
+	}
+
+	if unstageFailed {
+		return &transactions_v1.TransactionCommitResponse{}, nil
+	}
+
+	err = s.setAtrState(ctx, attempt, bucketAgent, oboUser, transactionAtrStateCompleted, false)
+	if err != nil {
+		s.logger.Warn("failed to mark committed transaction atr entry completed",
+			zap.Error(err),
+			zap.String("transactionId", in.TransactionId),
+			zap.String("attemptId", in.AttemptId))
+		return &transactions_v1.TransactionCommitResponse{}, nil
+	}
+
+	err = s.removeAtrEntry(ctx, attempt, bucketAgent, oboUser)
+	if err != nil {
+		// the transaction itself succeeded, cleanup will remove the entry.
+		s.logger.Warn("failed to remove completed transaction atr entry",
+			zap.Error(err),
+			zap.String("transactionId", in.TransactionId),
+			zap.String("attemptId", in.AttemptId))
+	}
+
+	return &transactions_v1.TransactionCommitResponse{}, nil
+}
+
+func (s *TransactionsServer) TransactionRollback(
+	ctx context.Context,
+	in *transactions_v1.TransactionRollbackRequest,
+) (*transactions_v1.TransactionRollbackResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	attempt, errSt := s.getPendingAttempt(in.BucketName, in.TransactionId, in.AttemptId)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+	defer attempt.lock.Unlock()
+
+	attempt.State = transactionAttemptStateRolledBack
+	s.attempts.Remove(in.TransactionId, in.AttemptId)
+
+	if attempt.AtrKey == "" {
+		return &transactions_v1.TransactionRollbackResponse{}, nil
+	}
+
+	err := s.setAtrState(ctx, attempt, bucketAgent, oboUser, transactionAtrStateAborted, true)
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	for _, mut := range attempt.Mutations {
+		err := s.rollbackMutation(ctx, attempt, bucketAgent, oboUser, mut)
+		if err != nil {
+			return nil, s.translateDocError(err, in.BucketName, mut.ScopeName, mut.CollectionName, mut.Key).Err()
+		}
+	}
+
+	err = s.removeAtrEntry(ctx, attempt, bucketAgent, oboUser)
+	if err != nil {
+		s.logger.Warn("failed to remove rolled back transaction atr entry",
+			zap.Error(err),
+			zap.String("transactionId", in.TransactionId),
+			zap.String("attemptId", in.AttemptId))
+	}
+
+	return &transactions_v1.TransactionRollbackResponse{}, nil
+}
+
+func (s *TransactionsServer) TransactionGet(
+	ctx context.Context,
+	in *transactions_v1.TransactionGetRequest,
+) (*transactions_v1.TransactionGetResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	attempt, errSt := s.getPendingAttempt(in.BucketName, in.TransactionId, in.AttemptId)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+	defer attempt.lock.Unlock()
+
+	if attempt.isExpired() {
+		return nil, s.errorHandler.NewTransactionExpiredStatus(in.TransactionId, in.AttemptId).Err()
+	}
+
+	docInfo, err := s.lookupDoc(ctx, bucketAgent, oboUser, in.ScopeName, in.CollectionName, in.Key)
+	if err != nil {
+		return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	// if we staged this document ourselves, we need to read our own write.
+	if docInfo.TxnMeta != nil && docInfo.TxnMeta.ID.Attempt == attempt.AttemptID {
+		_, mut := attempt.findMutation(in.ScopeName, in.CollectionName, in.Key)
+		if mut != nil {
+			if mut.OpType == transactionStagedOpTypeRemove {
+				return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+			}
+
+			return &transactions_v1.TransactionGetResponse{
+				Value: mut.Value,
+				Cas:   docInfo.Cas,
+			}, nil
+		}
+	}
+
+	// if another attempt has committed but not yet unstaged this document, the
+	// staged data is the committed version of the document.
+	if docInfo.TxnMeta != nil && docInfo.TxnMeta.ID.Attempt != attempt.AttemptID {
+		atrState, _, err := s.fetchForeignAtrState(ctx, oboUser, docInfo.TxnMeta)
+		if err != nil {
+			return nil, s.errorHandler.NewGenericStatus(err).Err()
+		}
+
+		if atrState == transactionAtrStateCommitted {
+			if docInfo.TxnMeta.Operation.Type == string(transactionStagedOpTypeRemove) {
+				return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+			}
+
+			return &transactions_v1.TransactionGetResponse{
+				Value: docInfo.TxnMeta.Operation.Staged,
+				Cas:   docInfo.Cas,
+			}, nil
+		}
+	}
+
+	if docInfo.IsDeleted {
+		return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	return &transactions_v1.TransactionGetResponse{
+		Value: docInfo.Value,
+		Cas:   docInfo.Cas,
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionInsert(
+	ctx context.Context,
+	in *transactions_v1.TransactionInsertRequest,
+) (*transactions_v1.TransactionInsertResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if !json.Valid(in.Value) {
+		return nil, status.New(codes.InvalidArgument, "transactional documents must be valid JSON").Err()
+	}
+
+	attempt, errSt := s.getPendingAttempt(in.BucketName, in.TransactionId, in.AttemptId)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+	defer attempt.lock.Unlock()
+
+	if attempt.isExpired() {
+		return nil, s.errorHandler.NewTransactionExpiredStatus(in.TransactionId, in.AttemptId).Err()
+	}
+
+	docInfo, err := s.lookupDoc(ctx, bucketAgent, oboUser, in.ScopeName, in.CollectionName, in.Key)
+	if err != nil {
+		if !errors.Is(err, memdx.ErrDocNotFound) {
+			return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+
+		docInfo = nil
+	}
+
+	_, existingMut := attempt.findMutation(in.ScopeName, in.CollectionName, in.Key)
+	if existingMut != nil {
+		if existingMut.OpType != transactionStagedOpTypeRemove {
+			return nil, s.errorHandler.NewDocExistsStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+
+		// inserting over our own staged remove turns it back into a replace.
+		cas, err := s.stageReplace(ctx, attempt, bucketAgent, oboUser, in.BucketName, in.ScopeName, in.CollectionName, in.Key, in.Value, docInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		return &transactions_v1.TransactionInsertResponse{
+			Cas: cas,
+		}, nil
+	}
+
+	if docInfo != nil {
+		if !docInfo.IsDeleted {
+			return nil, s.errorHandler.NewDocExistsStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+
+		errSt := s.checkWriteWriteConflict(ctx, attempt, oboUser, docInfo, in.ScopeName, in.CollectionName, in.Key)
+		if errSt != nil {
+			return nil, errSt.Err()
+		}
+	}
+
+	errSt = s.ensureAtrEntry(ctx, attempt, bucketAgent, oboUser, in.Key)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	mut := &transactionStagedMutation{
+		OpType:         transactionStagedOpTypeInsert,
+		ScopeName:      in.ScopeName,
+		CollectionName: in.CollectionName,
+		Key:            in.Key,
+		Value:          in.Value,
 	}
+
+	cas, err := s.stageMutation(ctx, attempt, bucketAgent, oboUser, mut, docInfo)
+	if err != nil {
+		return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	mut.Cas = cas
+	attempt.putMutation(mut)
+
+	return &transactions_v1.TransactionInsertResponse{
+		Cas: cas,
+	}, nil
+}
+
+func (s *TransactionsServer) stageReplace(
+	ctx context.Context,
+	attempt *transactionAttempt,
+	bucketAgent *gocbcorex.Agent,
+	oboUser string,
+	bucketName, scopeName, collectionName, key string,
+	value []byte,
+	docInfo *transactionDocInfo,
+) (uint64, error) {
+	mut := &transactionStagedMutation{
+		OpType:         transactionStagedOpTypeReplace,
+		ScopeName:      scopeName,
+		CollectionName: collectionName,
+		Key:            key,
+		Value:          value,
+	}
+
+	cas, err := s.stageMutation(ctx, attempt, bucketAgent, oboUser, mut, docInfo)
+	if err != nil {
+		return 0, s.translateDocError(err, bucketName, scopeName, collectionName, key).Err()
+	}
+
+	mut.Cas = cas
+	attempt.putMutation(mut)
+
+	return cas, nil
+}
+
+func (s *TransactionsServer) TransactionReplace(
+	ctx context.Context,
+	in *transactions_v1.TransactionReplaceRequest,
+) (*transactions_v1.TransactionReplaceResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if !json.Valid(in.Value) {
+		return nil, status.New(codes.InvalidArgument, "transactional documents must be valid JSON").Err()
+	}
+
+	attempt, errSt := s.getPendingAttempt(in.BucketName, in.TransactionId, in.AttemptId)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+	defer attempt.lock.Unlock()
+
+	if attempt.isExpired() {
+		return nil, s.errorHandler.NewTransactionExpiredStatus(in.TransactionId, in.AttemptId).Err()
+	}
+
+	docInfo, err := s.lookupDoc(ctx, bucketAgent, oboUser, in.ScopeName, in.CollectionName, in.Key)
+	if err != nil {
+		return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	if in.Cas != nil && *in.Cas != docInfo.Cas {
+		return nil, s.errorHandler.NewDocCasMismatchStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	_, existingMut := attempt.findMutation(in.ScopeName, in.CollectionName, in.Key)
+	if existingMut != nil {
+		if existingMut.OpType == transactionStagedOpTypeRemove {
+			return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+
+		// replacing our own staged write just updates the staged content, keeping
+		// the original operation type (an insert remains an insert).
+		updatedMut := *existingMut
+		updatedMut.Value = in.Value
+
+		cas, err := s.stageMutation(ctx, attempt, bucketAgent, oboUser, &updatedMut, docInfo)
+		if err != nil {
+			return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+
+		updatedMut.Cas = cas
+		attempt.putMutation(&updatedMut)
+
+		return &transactions_v1.TransactionReplaceResponse{
+			Cas: cas,
+		}, nil
+	}
+
+	if docInfo.IsDeleted {
+		return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	errSt = s.checkWriteWriteConflict(ctx, attempt, oboUser, docInfo, in.ScopeName, in.CollectionName, in.Key)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	errSt = s.ensureAtrEntry(ctx, attempt, bucketAgent, oboUser, in.Key)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	cas, err := s.stageReplace(ctx, attempt, bucketAgent, oboUser, in.BucketName, in.ScopeName, in.CollectionName, in.Key, in.Value, docInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transactions_v1.TransactionReplaceResponse{
+		Cas: cas,
+	}, nil
+}
+
+func (s *TransactionsServer) TransactionRemove(
+	ctx context.Context,
+	in *transactions_v1.TransactionRemoveRequest,
+) (*transactions_v1.TransactionRemoveResponse, error) {
+	bucketAgent, oboUser, errSt := s.authHandler.GetMemdOboAgent(ctx, in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	attempt, errSt := s.getPendingAttempt(in.BucketName, in.TransactionId, in.AttemptId)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+	defer attempt.lock.Unlock()
+
+	if attempt.isExpired() {
+		return nil, s.errorHandler.NewTransactionExpiredStatus(in.TransactionId, in.AttemptId).Err()
+	}
+
+	docInfo, err := s.lookupDoc(ctx, bucketAgent, oboUser, in.ScopeName, in.CollectionName, in.Key)
+	if err != nil {
+		return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	if in.Cas != nil && *in.Cas != docInfo.Cas {
+		return nil, s.errorHandler.NewDocCasMismatchStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	mutIdx, existingMut := attempt.findMutation(in.ScopeName, in.CollectionName, in.Key)
+	if existingMut != nil {
+		switch existingMut.OpType {
+		case transactionStagedOpTypeRemove:
+			return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		case transactionStagedOpTypeInsert:
+			// removing our own staged insert simply discards the insert.
+			err := s.rollbackMutation(ctx, attempt, bucketAgent, oboUser, existingMut)
+			if err != nil {
+				return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+			}
+
+			attempt.removeMutation(mutIdx)
+			return &transactions_v1.TransactionRemoveResponse{}, nil
+		}
+
+		// a staged replace is turned into a staged remove below, the existing
+		// mutation is only replaced once the remove has been staged.
+	} else {
+		if docInfo.IsDeleted {
+			return nil, s.errorHandler.NewDocMissingStatus(nil, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+		}
+
+		errSt := s.checkWriteWriteConflict(ctx, attempt, oboUser, docInfo, in.ScopeName, in.CollectionName, in.Key)
+		if errSt != nil {
+			return nil, errSt.Err()
+		}
+	}
+
+	errSt = s.ensureAtrEntry(ctx, attempt, bucketAgent, oboUser, in.Key)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	mut := &transactionStagedMutation{
+		OpType:         transactionStagedOpTypeRemove,
+		ScopeName:      in.ScopeName,
+		CollectionName: in.CollectionName,
+		Key:            in.Key,
+	}
+
+	cas, err := s.stageMutation(ctx, attempt, bucketAgent, oboUser, mut, docInfo)
+	if err != nil {
+		return nil, s.translateDocError(err, in.BucketName, in.ScopeName, in.CollectionName, in.Key).Err()
+	}
+
+	mut.Cas = cas
+	attempt.putMutation(mut)
+
+	return &transactions_v1.TransactionRemoveResponse{
+		Cas: cas,
+	}, nil
 }
@@ -0,0 +1,426 @@
+package server_v1
+
+import (
+	"context"
+	"errors"
+
+	"github.com/couchbase/gocbcorex/cbmgmtx"
+	"github.com/couchbase/goprotostellar/genproto/admin_user_v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type UserAdminServer struct {
+	admin_user_v1.UnimplementedUserAdminServiceServer
+
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+	authHandler  *AuthHandler
+}
+
+func NewUserAdminServer(
+	logger *zap.Logger,
+	errorHandler *ErrorHandler,
+	authHandler *AuthHandler,
+) *UserAdminServer {
+	return &UserAdminServer{
+		logger:       logger,
+		errorHandler: errorHandler,
+		authHandler:  authHandler,
+	}
+}
+
+func authDomainToCbmgmtx(domain *admin_user_v1.AuthDomain) (cbmgmtx.AuthDomain, *status.Status) {
+	if domain == nil {
+		return cbmgmtx.AuthDomainLocal, nil
+	}
+
+	switch *domain {
+	case admin_user_v1.AuthDomain_AUTH_DOMAIN_LOCAL:
+		return cbmgmtx.AuthDomainLocal, nil
+	case admin_user_v1.AuthDomain_AUTH_DOMAIN_EXTERNAL:
+		return cbmgmtx.AuthDomainExternal, nil
+	}
+
+	return "", status.New(codes.InvalidArgument, "invalid auth domain specified")
+}
+
+func authDomainFromCbmgmtx(domain cbmgmtx.AuthDomain) (admin_user_v1.AuthDomain, *status.Status) {
+	switch domain {
+	case cbmgmtx.AuthDomainLocal:
+		return admin_user_v1.AuthDomain_AUTH_DOMAIN_LOCAL, nil
+	case cbmgmtx.AuthDomainExternal:
+		return admin_user_v1.AuthDomain_AUTH_DOMAIN_EXTERNAL, nil
+	}
+
+	return admin_user_v1.AuthDomain(0), status.New(codes.InvalidArgument, "invalid auth domain received")
+}
+
+func rolesFromCbmgmtx(roles []cbmgmtx.Role) []*admin_user_v1.Role {
+	psRoles := make([]*admin_user_v1.Role, len(roles))
+	for roleIdx, role := range roles {
+		role := role
+
+		psRole := &admin_user_v1.Role{
+			Name: role.Name,
+		}
+		if role.BucketName != "" {
+			psRole.BucketName = &role.BucketName
+		}
+		if role.ScopeName != "" {
+			psRole.ScopeName = &role.ScopeName
+		}
+		if role.CollectionName != "" {
+			psRole.CollectionName = &role.CollectionName
+		}
+
+		psRoles[roleIdx] = psRole
+	}
+
+	return psRoles
+}
+
+func rolesToCbmgmtx(roles []*admin_user_v1.Role) []cbmgmtx.Role {
+	cbRoles := make([]cbmgmtx.Role, len(roles))
+	for roleIdx, role := range roles {
+		cbRoles[roleIdx] = cbmgmtx.Role{
+			Name:           role.Name,
+			BucketName:     role.GetBucketName(),
+			ScopeName:      role.GetScopeName(),
+			CollectionName: role.GetCollectionName(),
+		}
+	}
+
+	return cbRoles
+}
+
+func userFromCbmgmtx(user *cbmgmtx.UserDef) (*admin_user_v1.User, *status.Status) {
+	domain, errSt := authDomainFromCbmgmtx(user.Domain)
+	if errSt != nil {
+		return nil, errSt
+	}
+
+	return &admin_user_v1.User{
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Domain:      domain,
+		Groups:      user.Groups,
+		Roles:       rolesFromCbmgmtx(user.Roles),
+	}, nil
+}
+
+func groupFromCbmgmtx(group *cbmgmtx.GroupDef) *admin_user_v1.Group {
+	psGroup := &admin_user_v1.Group{
+		Name:        group.Name,
+		Description: group.Description,
+		Roles:       rolesFromCbmgmtx(group.Roles),
+	}
+	if group.LDAPGroupReference != "" {
+		psGroup.LdapGroupReference = &group.LDAPGroupReference
+	}
+
+	return psGroup
+}
+
+func (s *UserAdminServer) GetUser(
+	ctx context.Context,
+	in *admin_user_v1.GetUserRequest,
+) (*admin_user_v1.GetUserResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	domain, errSt := authDomainToCbmgmtx(in.Domain)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	user, err := agent.GetUser(ctx, &cbmgmtx.GetUserOptions{
+		OnBehalfOf: oboInfo,
+		Username:   in.Username,
+		Domain:     domain,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrUserNotFound) {
+			return nil, s.errorHandler.NewUserMissingStatus(err, in.Username).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	psUser, errSt := userFromCbmgmtx(user)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	return &admin_user_v1.GetUserResponse{
+		User: psUser,
+	}, nil
+}
+
+func (s *UserAdminServer) ListUsers(
+	ctx context.Context,
+	in *admin_user_v1.ListUsersRequest,
+) (*admin_user_v1.ListUsersResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	domain, errSt := authDomainToCbmgmtx(in.Domain)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	users, err := agent.GetAllUsers(ctx, &cbmgmtx.GetAllUsersOptions{
+		OnBehalfOf: oboInfo,
+		Domain:     domain,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	psUsers := make([]*admin_user_v1.User, len(users))
+	for userIdx, user := range users {
+		psUser, errSt := userFromCbmgmtx(user)
+		if errSt != nil {
+			return nil, errSt.Err()
+		}
+
+		psUsers[userIdx] = psUser
+	}
+
+	return &admin_user_v1.ListUsersResponse{
+		Users: psUsers,
+	}, nil
+}
+
+func (s *UserAdminServer) UpsertUser(
+	ctx context.Context,
+	in *admin_user_v1.UpsertUserRequest,
+) (*admin_user_v1.UpsertUserResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if in.User == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "a user must be specified")
+	}
+
+	domain := in.User.Domain
+	cbDomain, errSt := authDomainToCbmgmtx(&domain)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if cbDomain == cbmgmtx.AuthDomainExternal && in.Password != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "passwords cannot be specified for external users")
+	}
+
+	err := agent.UpsertUser(ctx, &cbmgmtx.UpsertUserOptions{
+		OnBehalfOf:  oboInfo,
+		Username:    in.User.Username,
+		Domain:      cbDomain,
+		DisplayName: in.User.DisplayName,
+		Password:    in.GetPassword(),
+		Groups:      in.User.Groups,
+		Roles:       rolesToCbmgmtx(in.User.Roles),
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrInvalidArgument) {
+			return nil, s.errorHandler.NewInvalidUserStatus(err, in.User.Username).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_user_v1.UpsertUserResponse{}, nil
+}
+
+func (s *UserAdminServer) DeleteUser(
+	ctx context.Context,
+	in *admin_user_v1.DeleteUserRequest,
+) (*admin_user_v1.DeleteUserResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	domain, errSt := authDomainToCbmgmtx(in.Domain)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.DeleteUser(ctx, &cbmgmtx.DeleteUserOptions{
+		OnBehalfOf: oboInfo,
+		Username:   in.Username,
+		Domain:     domain,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrUserNotFound) {
+			return nil, s.errorHandler.NewUserMissingStatus(err, in.Username).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_user_v1.DeleteUserResponse{}, nil
+}
+
+func (s *UserAdminServer) GetGroup(
+	ctx context.Context,
+	in *admin_user_v1.GetGroupRequest,
+) (*admin_user_v1.GetGroupResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	group, err := agent.GetGroup(ctx, &cbmgmtx.GetGroupOptions{
+		OnBehalfOf: oboInfo,
+		GroupName:  in.Name,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrGroupNotFound) {
+			return nil, s.errorHandler.NewGroupMissingStatus(err, in.Name).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_user_v1.GetGroupResponse{
+		Group: groupFromCbmgmtx(group),
+	}, nil
+}
+
+func (s *UserAdminServer) ListGroups(
+	ctx context.Context,
+	in *admin_user_v1.ListGroupsRequest,
+) (*admin_user_v1.ListGroupsResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	groups, err := agent.GetAllGroups(ctx, &cbmgmtx.GetAllGroupsOptions{
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	psGroups := make([]*admin_user_v1.Group, len(groups))
+	for groupIdx, group := range groups {
+		psGroups[groupIdx] = groupFromCbmgmtx(group)
+	}
+
+	return &admin_user_v1.ListGroupsResponse{
+		Groups: psGroups,
+	}, nil
+}
+
+func (s *UserAdminServer) UpsertGroup(
+	ctx context.Context,
+	in *admin_user_v1.UpsertGroupRequest,
+) (*admin_user_v1.UpsertGroupResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if in.Group == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "a group must be specified")
+	}
+
+	err := agent.UpsertGroup(ctx, &cbmgmtx.UpsertGroupOptions{
+		OnBehalfOf:         oboInfo,
+		GroupName:          in.Group.Name,
+		Description:        in.Group.Description,
+		Roles:              rolesToCbmgmtx(in.Group.Roles),
+		LDAPGroupReference: in.Group.GetLdapGroupReference(),
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrInvalidArgument) {
+			return nil, s.errorHandler.NewInvalidGroupStatus(err, in.Group.Name).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_user_v1.UpsertGroupResponse{}, nil
+}
+
+func (s *UserAdminServer) DeleteGroup(
+	ctx context.Context,
+	in *admin_user_v1.DeleteGroupRequest,
+) (*admin_user_v1.DeleteGroupResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.DeleteGroup(ctx, &cbmgmtx.DeleteGroupOptions{
+		OnBehalfOf: oboInfo,
+		GroupName:  in.Name,
+	})
+	if err != nil {
+		if errors.Is(err, cbmgmtx.ErrGroupNotFound) {
+			return nil, s.errorHandler.NewGroupMissingStatus(err, in.Name).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_user_v1.DeleteGroupResponse{}, nil
+}
+
+func (s *UserAdminServer) ListRoles(
+	ctx context.Context,
+	in *admin_user_v1.ListRolesRequest,
+) (*admin_user_v1.ListRolesResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	roles, err := agent.GetRoles(ctx, &cbmgmtx.GetRolesOptions{
+		OnBehalfOf: oboInfo,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	psRoles := make([]*admin_user_v1.ListRolesResponse_Role, len(roles))
+	for roleIdx, role := range roles {
+		psRoles[roleIdx] = &admin_user_v1.ListRolesResponse_Role{
+			Role:        rolesFromCbmgmtx([]cbmgmtx.Role{role.Role})[0],
+			DisplayName: role.DisplayName,
+			Description: role.Description,
+		}
+	}
+
+	return &admin_user_v1.ListRolesResponse{
+		Roles: psRoles,
+	}, nil
+}
+
+func (s *UserAdminServer) ChangePassword(
+	ctx context.Context,
+	in *admin_user_v1.ChangePasswordRequest,
+) (*admin_user_v1.ChangePasswordResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, nil)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if in.NewPassword == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "a new password must be specified")
+	}
+
+	err := agent.ChangePassword(ctx, &cbmgmtx.ChangePasswordOptions{
+		OnBehalfOf:  oboInfo,
+		NewPassword: in.NewPassword,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_user_v1.ChangePasswordResponse{}, nil
+}
@@ -0,0 +1,234 @@
+package server_v1
+
+import (
+	"context"
+	"errors"
+
+	"github.com/couchbase/gocbcorex/cbviewsx"
+	"github.com/couchbase/goprotostellar/genproto/admin_view_v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ViewAdminServer struct {
+	admin_view_v1.UnimplementedViewAdminServiceServer
+
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+	authHandler  *AuthHandler
+}
+
+func NewViewAdminServer(
+	logger *zap.Logger,
+	errorHandler *ErrorHandler,
+	authHandler *AuthHandler,
+) *ViewAdminServer {
+	return &ViewAdminServer{
+		logger:       logger,
+		errorHandler: errorHandler,
+		authHandler:  authHandler,
+	}
+}
+
+func designDocumentFromCbviewsx(
+	ddoc *cbviewsx.DesignDocument,
+	ns admin_view_v1.DesignDocumentNamespace,
+) *admin_view_v1.DesignDocument {
+	views := make(map[string]*admin_view_v1.DesignDocument_View, len(ddoc.Views))
+	for viewName, view := range ddoc.Views {
+		psView := &admin_view_v1.DesignDocument_View{
+			Map: view.Map,
+		}
+		if view.Reduce != "" {
+			reduce := view.Reduce
+			psView.Reduce = &reduce
+		}
+
+		views[viewName] = psView
+	}
+
+	return &admin_view_v1.DesignDocument{
+		Name:      ddoc.Name,
+		Namespace: ns,
+		Views:     views,
+	}
+}
+
+func designDocumentToCbviewsx(ddoc *admin_view_v1.DesignDocument) cbviewsx.DesignDocument {
+	views := make(map[string]cbviewsx.DesignDocumentView, len(ddoc.Views))
+	for viewName, view := range ddoc.Views {
+		views[viewName] = cbviewsx.DesignDocumentView{
+			Map:    view.Map,
+			Reduce: view.GetReduce(),
+		}
+	}
+
+	return cbviewsx.DesignDocument{
+		Name:  ddoc.Name,
+		Views: views,
+	}
+}
+
+func (s *ViewAdminServer) GetDesignDocument(
+	ctx context.Context,
+	in *admin_view_v1.GetDesignDocumentRequest,
+) (*admin_view_v1.GetDesignDocumentResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, &in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	namespace, errSt := designDocumentNamespaceToCbviewsx(in.Namespace)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	ddoc, err := agent.GetDesignDocument(ctx, &cbviewsx.GetDesignDocumentOptions{
+		OnBehalfOf:         oboInfo,
+		BucketName:         in.BucketName,
+		DesignDocumentName: in.Name,
+		Namespace:          namespace,
+	})
+	if err != nil {
+		if errors.Is(err, cbviewsx.ErrDesignDocumentNotFound) {
+			return nil, s.errorHandler.NewDesignDocumentMissingStatus(err, in.BucketName, in.Name).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_view_v1.GetDesignDocumentResponse{
+		DesignDocument: designDocumentFromCbviewsx(ddoc, in.Namespace),
+	}, nil
+}
+
+func (s *ViewAdminServer) ListDesignDocuments(
+	ctx context.Context,
+	in *admin_view_v1.ListDesignDocumentsRequest,
+) (*admin_view_v1.ListDesignDocumentsResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, &in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	namespace, errSt := designDocumentNamespaceToCbviewsx(in.Namespace)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	ddocs, err := agent.GetAllDesignDocuments(ctx, &cbviewsx.GetAllDesignDocumentsOptions{
+		OnBehalfOf: oboInfo,
+		BucketName: in.BucketName,
+		Namespace:  namespace,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	psDdocs := make([]*admin_view_v1.DesignDocument, len(ddocs))
+	for ddocIdx, ddoc := range ddocs {
+		psDdocs[ddocIdx] = designDocumentFromCbviewsx(ddoc, in.Namespace)
+	}
+
+	return &admin_view_v1.ListDesignDocumentsResponse{
+		DesignDocuments: psDdocs,
+	}, nil
+}
+
+func (s *ViewAdminServer) UpsertDesignDocument(
+	ctx context.Context,
+	in *admin_view_v1.UpsertDesignDocumentRequest,
+) (*admin_view_v1.UpsertDesignDocumentResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, &in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	if in.DesignDocument == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "a design document must be specified")
+	}
+
+	namespace, errSt := designDocumentNamespaceToCbviewsx(in.DesignDocument.Namespace)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.UpsertDesignDocument(ctx, &cbviewsx.UpsertDesignDocumentOptions{
+		OnBehalfOf:     oboInfo,
+		BucketName:     in.BucketName,
+		Namespace:      namespace,
+		DesignDocument: designDocumentToCbviewsx(in.DesignDocument),
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_view_v1.UpsertDesignDocumentResponse{}, nil
+}
+
+func (s *ViewAdminServer) DeleteDesignDocument(
+	ctx context.Context,
+	in *admin_view_v1.DeleteDesignDocumentRequest,
+) (*admin_view_v1.DeleteDesignDocumentResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, &in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	namespace, errSt := designDocumentNamespaceToCbviewsx(in.Namespace)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	err := agent.DeleteDesignDocument(ctx, &cbviewsx.DeleteDesignDocumentOptions{
+		OnBehalfOf:         oboInfo,
+		BucketName:         in.BucketName,
+		DesignDocumentName: in.Name,
+		Namespace:          namespace,
+	})
+	if err != nil {
+		if errors.Is(err, cbviewsx.ErrDesignDocumentNotFound) {
+			return nil, s.errorHandler.NewDesignDocumentMissingStatus(err, in.BucketName, in.Name).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_view_v1.DeleteDesignDocumentResponse{}, nil
+}
+
+func (s *ViewAdminServer) PublishDesignDocument(
+	ctx context.Context,
+	in *admin_view_v1.PublishDesignDocumentRequest,
+) (*admin_view_v1.PublishDesignDocumentResponse, error) {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(ctx, &in.BucketName)
+	if errSt != nil {
+		return nil, errSt.Err()
+	}
+
+	// publishing is simply copying the development version of the design
+	// document into the production namespace.
+	ddoc, err := agent.GetDesignDocument(ctx, &cbviewsx.GetDesignDocumentOptions{
+		OnBehalfOf:         oboInfo,
+		BucketName:         in.BucketName,
+		DesignDocumentName: in.Name,
+		Namespace:          cbviewsx.DesignDocumentNamespaceDevelopment,
+	})
+	if err != nil {
+		if errors.Is(err, cbviewsx.ErrDesignDocumentNotFound) {
+			return nil, s.errorHandler.NewDesignDocumentMissingStatus(err, in.BucketName, in.Name).Err()
+		}
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	err = agent.UpsertDesignDocument(ctx, &cbviewsx.UpsertDesignDocumentOptions{
+		OnBehalfOf:     oboInfo,
+		BucketName:     in.BucketName,
+		Namespace:      cbviewsx.DesignDocumentNamespaceProduction,
+		DesignDocument: *ddoc,
+	})
+	if err != nil {
+		return nil, s.errorHandler.NewGenericStatus(err).Err()
+	}
+
+	return &admin_view_v1.PublishDesignDocumentResponse{}, nil
+}
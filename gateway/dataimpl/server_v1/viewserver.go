@@ -0,0 +1,220 @@
+package server_v1
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/couchbase/gocbcorex"
+	"github.com/couchbase/gocbcorex/cbviewsx"
+	"github.com/couchbase/goprotostellar/genproto/view_v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ViewServer struct {
+	view_v1.UnimplementedViewServiceServer
+
+	logger         *zap.Logger
+	errorHandler   *ErrorHandler
+	authHandler    *AuthHandler
+	rowBatcherOpts *RowBatcherOptions
+}
+
+func NewViewServer(
+	logger *zap.Logger,
+	errorHandler *ErrorHandler,
+	authHandler *AuthHandler,
+	rowBatcherOpts *RowBatcherOptions,
+) *ViewServer {
+	return &ViewServer{
+		logger:         logger,
+		errorHandler:   errorHandler,
+		authHandler:    authHandler,
+		rowBatcherOpts: rowBatcherOpts,
+	}
+}
+
+func (s *ViewServer) translateError(err error, bucketName, designDocName, viewName string) *status.Status {
+	if errors.Is(err, cbviewsx.ErrDesignDocumentNotFound) {
+		return s.errorHandler.NewDesignDocumentMissingStatus(err, bucketName, designDocName)
+	} else if errors.Is(err, cbviewsx.ErrViewNotFound) {
+		return s.errorHandler.NewViewMissingStatus(err, bucketName, designDocName, viewName)
+	} else if errors.Is(err, cbviewsx.ErrAuthenticationFailure) {
+		return s.errorHandler.NewViewNoAccessStatus(err, bucketName)
+	}
+
+	return s.errorHandler.NewGenericStatus(err)
+}
+
+func (s *ViewServer) ViewQuery(in *view_v1.ViewQueryRequest, out view_v1.ViewService_ViewQueryServer) error {
+	agent, oboInfo, errSt := s.authHandler.GetHttpOboAgent(out.Context(), &in.BucketName)
+	if errSt != nil {
+		return errSt.Err()
+	}
+
+	var opts gocbcorex.ViewQueryOptions
+	opts.OnBehalfOf = oboInfo
+
+	opts.BucketName = in.BucketName
+	opts.DesignDocumentName = in.DesignDocumentName
+	opts.ViewName = in.ViewName
+
+	if in.Namespace != nil {
+		switch *in.Namespace {
+		case view_v1.ViewQueryRequest_NAMESPACE_PRODUCTION:
+			opts.Namespace = cbviewsx.DesignDocumentNamespaceProduction
+		case view_v1.ViewQueryRequest_NAMESPACE_DEVELOPMENT:
+			opts.Namespace = cbviewsx.DesignDocumentNamespaceDevelopment
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid namespace option specified")
+		}
+	}
+
+	if in.ScanConsistency != nil {
+		switch *in.ScanConsistency {
+		case view_v1.ViewQueryRequest_SCAN_CONSISTENCY_NOT_BOUNDED:
+			opts.ScanConsistency = cbviewsx.ViewScanConsistencyNotBounded
+		case view_v1.ViewQueryRequest_SCAN_CONSISTENCY_REQUEST_PLUS:
+			opts.ScanConsistency = cbviewsx.ViewScanConsistencyRequestPlus
+		case view_v1.ViewQueryRequest_SCAN_CONSISTENCY_UPDATE_AFTER:
+			opts.ScanConsistency = cbviewsx.ViewScanConsistencyUpdateAfter
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid scan consistency option specified")
+		}
+	}
+
+	if in.Skip != nil {
+		opts.Skip = *in.Skip
+	}
+
+	if in.Limit != nil {
+		opts.Limit = *in.Limit
+	}
+
+	if in.Order != nil {
+		switch *in.Order {
+		case view_v1.ViewQueryRequest_ORDER_ASCENDING:
+			opts.Order = cbviewsx.ViewOrderingAscending
+		case view_v1.ViewQueryRequest_ORDER_DESCENDING:
+			opts.Order = cbviewsx.ViewOrderingDescending
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid order option specified")
+		}
+	}
+
+	if in.Reduce != nil {
+		opts.Reduce = in.Reduce
+	}
+
+	if in.Group != nil {
+		opts.Group = *in.Group
+	}
+
+	if in.GroupLevel != nil {
+		opts.GroupLevel = *in.GroupLevel
+	}
+
+	if len(in.Key) > 0 {
+		opts.Key = in.Key
+	}
+
+	if len(in.Keys) > 0 {
+		keys := make([]json.RawMessage, len(in.Keys))
+		for i, key := range in.Keys {
+			keys[i] = key
+		}
+		opts.Keys = keys
+	}
+
+	if len(in.StartKey) > 0 {
+		opts.StartKey = in.StartKey
+	}
+
+	if len(in.EndKey) > 0 {
+		opts.EndKey = in.EndKey
+	}
+
+	if in.InclusiveEnd != nil {
+		opts.InclusiveEnd = *in.InclusiveEnd
+	}
+
+	if in.StartKeyDocId != nil {
+		opts.StartKeyDocID = *in.StartKeyDocId
+	}
+
+	if in.EndKeyDocId != nil {
+		opts.EndKeyDocID = *in.EndKeyDocId
+	}
+
+	if in.OnError != nil {
+		switch *in.OnError {
+		case view_v1.ViewQueryRequest_ERROR_MODE_CONTINUE:
+			opts.OnError = cbviewsx.ViewErrorModeContinue
+		case view_v1.ViewQueryRequest_ERROR_MODE_STOP:
+			opts.OnError = cbviewsx.ViewErrorModeStop
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid error mode option specified")
+		}
+	}
+
+	if in.Debug != nil {
+		opts.Debug = *in.Debug
+	}
+
+	result, err := agent.ViewQuery(out.Context(), &opts)
+	if err != nil {
+		return s.translateError(err, in.BucketName, in.DesignDocumentName, in.ViewName).Err()
+	}
+
+	batcher := newRowBatcher(s.rowBatcherOpts, func(rows []*view_v1.ViewQueryResponse_Row) error {
+		return out.Send(&view_v1.ViewQueryResponse{
+			Rows:     rows,
+			MetaData: nil,
+		})
+	})
+	defer batcher.Close()
+
+	for result.HasMoreRows() {
+		row, err := result.ReadRow()
+		if err != nil {
+			return s.translateError(err, in.BucketName, in.DesignDocumentName, in.ViewName).Err()
+		}
+
+		rowNumBytes := len(row.ID) + len(row.Key) + len(row.Value)
+
+		err = batcher.Add(&view_v1.ViewQueryResponse_Row{
+			Id:    row.ID,
+			Key:   row.Key,
+			Value: row.Value,
+		}, rowNumBytes)
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
+		}
+	}
+
+	var psMetaData *view_v1.ViewQueryResponse_MetaData
+
+	metaData, err := result.MetaData()
+	if err == nil {
+		psMetaData = &view_v1.ViewQueryResponse_MetaData{
+			TotalRows: metaData.TotalRows,
+			Debug:     metaData.Debug,
+		}
+	}
+
+	// if we have any rows or meta-data left to stream, we send that first
+	// before we process any errors that occurred.
+	rowCache := batcher.Take()
+	if rowCache != nil || psMetaData != nil {
+		err := out.Send(&view_v1.ViewQueryResponse{
+			Rows:     rowCache,
+			MetaData: psMetaData,
+		})
+		if err != nil {
+			return s.errorHandler.NewGenericStatus(err).Err()
+		}
+	}
+
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/couchbase/stellar-gateway/contrib/cbtopology"
 	"github.com/couchbase/stellar-gateway/contrib/goclustering"
 	"github.com/couchbase/stellar-gateway/gateway/auth"
+	"github.com/couchbase/stellar-gateway/gateway/capture"
 	"github.com/couchbase/stellar-gateway/gateway/clustering"
 	"github.com/couchbase/stellar-gateway/gateway/dataimpl"
 	"github.com/couchbase/stellar-gateway/gateway/sdimpl"
@@ -60,6 +61,13 @@ type Config struct {
 
 	TlsCertificate tls.Certificate
 
+	StreamMaxMessageBytes int
+	StreamTargetLatency   time.Duration
+
+	// CapturePath, when set, is a file which every RPC is recorded to for
+	// later replay.
+	CapturePath string
+
 	NumInstances    uint
 	StartupCallback func(*StartupInfo)
 }
@@ -255,13 +263,35 @@ func (g *Gateway) Run(ctx context.Context) error {
 		return err
 	}
 
+	var captureRecorder *capture.Recorder
+	if config.CapturePath != "" {
+		captureRecorder, err = capture.NewRecorder(config.CapturePath)
+		if err != nil {
+			config.Logger.Error("failed to open capture file", zap.Error(err))
+			return err
+		}
+		defer captureRecorder.Close()
+
+		config.Logger.Info("capturing all requests", zap.String("path", config.CapturePath))
+	}
+
 	startInstance := func(ctx context.Context, instanceIdx int) error {
+		// the data servers are stopped whenever this instance stops, including
+		// when it fails to start.
+		dataCtx, dataCancel := context.WithCancel(ctx)
+		defer dataCancel()
+
 		dataImpl := dataimpl.New(&dataimpl.NewOptions{
+			Ctx:              dataCtx,
 			Logger:           config.Logger.Named("data-impl"),
 			Debug:            config.Debug,
 			TopologyProvider: psTopologyManager,
 			CbClient:         agentMgr,
 			Authenticator:    auth.CbAuthAuthenticator{},
+			Metrics:          metrics.GetSnMetrics(),
+
+			StreamMaxMessageBytes: config.StreamMaxMessageBytes,
+			StreamTargetLatency:   config.StreamTargetLatency,
 		})
 
 		sdImpl := sdimpl.New(&sdimpl.NewOptions{
@@ -280,6 +310,7 @@ func (g *Gateway) Run(ctx context.Context) error {
 					return g.atomicTlsCert.Load(), nil
 				},
 			},
+			CaptureRecorder: captureRecorder,
 		})
 		if err != nil {
 			config.Logger.Error("error creating legacy proxy")
@@ -74,6 +74,18 @@ func (c *Barrier) Wait(ctx context.Context, waiterID string, metaData []byte) []
 	return respData
 }
 
+func (c *Barrier) WaiterIDs() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	waiterIDs := make([]string, len(c.waiters))
+	for waiterIdx, waiter := range c.waiters {
+		waiterIDs[waiterIdx] = waiter.ID
+	}
+
+	return waiterIDs
+}
+
 func (c *Barrier) trySignal(waiterID *string, metaData []byte) bool {
 	c.lock.Lock()
 	waiterIdx := 0
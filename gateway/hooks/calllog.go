@@ -0,0 +1,42 @@
+package hooks
+
+import (
+	"time"
+
+	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
+)
+
+const hooksContextMaxCallLogEntries = 1000
+
+// hooksRunMaxActionLogEntries caps the actions recorded for a single call, since
+// the send actions of a streaming call run again for every message sent.
+const hooksRunMaxActionLogEntries = 1000
+
+// HookCall records a single call which was handled by a hook, along with the
+// actions which the hook took while handling it.
+type HookCall struct {
+	RunID     string
+	Method    string
+	Request   interface{}
+	Actions   []string
+	StartTime time.Time
+	EndTime   time.Time
+	Err       error
+}
+
+// hookActionName returns the name of the action which is set on a HookAction,
+// this is the name of the field set within its action oneof.
+func hookActionName(action *internal_hooks_v1.HookAction) string {
+	msg := action.ProtoReflect()
+	oneofs := msg.Descriptor().Oneofs()
+	if oneofs.Len() == 0 {
+		return "unknown"
+	}
+
+	fd := msg.WhichOneof(oneofs.Get(0))
+	if fd == nil {
+		return "unknown"
+	}
+
+	return string(fd.Name())
+}
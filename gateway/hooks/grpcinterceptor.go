@@ -8,28 +8,45 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// findHooksContext locates the hooks context referenced by the X-Hooks-ID
+// header of an incoming call, if there is one.
+func findHooksContext(manager *HooksManager, ctx context.Context) (string, *HooksContext) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if md == nil {
+		return "", nil
+	}
+
+	hooksIDs := md.Get("X-Hooks-ID")
+	if hooksIDs == nil {
+		return "", nil
+	}
+
+	hooksID := hooksIDs[len(hooksIDs)-1]
+	return hooksID, manager.GetHooksContext(hooksID)
+}
+
 func makeGrpcUnaryInterceptor(manager *HooksManager, log *zap.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		md, _ := metadata.FromIncomingContext(ctx)
-		if md == nil {
+		hooksID, hooksContext := findHooksContext(manager, ctx)
+		if hooksContext == nil {
 			// forward the underlying call
 			return handler(ctx, req)
 		}
 
-		hooksIDs := md.Get("X-Hooks-ID")
-		if hooksIDs == nil {
-			// forward the underlying call
-			return handler(ctx, req)
-		}
+		log.Info("calling registered hooks context", zap.String("hooks-id", hooksID), zap.Any("info", info), zap.Any("req", req))
+		return hooksContext.HandleUnaryCall(ctx, req, info, handler)
+	}
+}
 
-		hooksID := hooksIDs[len(hooksIDs)-1]
-		hooksContext := manager.GetHooksContext(hooksID)
+func makeGrpcStreamInterceptor(manager *HooksManager, log *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		hooksID, hooksContext := findHooksContext(manager, ss.Context())
 		if hooksContext == nil {
 			// forward the underlying call
-			return handler(ctx, req)
+			return handler(srv, ss)
 		}
 
-		log.Info("calling registered hooks context", zap.String("hooks-id", hooksID), zap.Any("info", info), zap.Any("req", req))
-		return hooksContext.HandleUnaryCall(ctx, req, info, handler)
+		log.Info("calling registered hooks context for stream", zap.String("hooks-id", hooksID), zap.Any("info", info))
+		return hooksContext.HandleStreamCall(srv, ss, info, handler)
 	}
 }
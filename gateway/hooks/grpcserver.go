@@ -2,11 +2,15 @@ package hooks
 
 import (
 	"context"
+	"sort"
 
 	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type grpcHooksServer struct {
@@ -19,7 +23,14 @@ func (s *grpcHooksServer) CreateHooksContext(
 	ctx context.Context,
 	req *internal_hooks_v1.CreateHooksContextRequest,
 ) (*internal_hooks_v1.CreateHooksContextResponse, error) {
-	err := s.manager.CreateHooksContext(req.Id)
+	opts := &HooksContextOptions{
+		RecordCalls: req.RecordCalls,
+	}
+	if req.Ttl != nil {
+		opts.TTL = req.Ttl.AsDuration()
+	}
+
+	err := s.manager.CreateHooksContext(req.Id, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +50,67 @@ func (s *grpcHooksServer) DestroyHooksContext(
 	return &internal_hooks_v1.DestroyHooksContextResponse{}, nil
 }
 
+func (s *grpcHooksServer) GetHooksContext(
+	ctx context.Context,
+	req *internal_hooks_v1.GetHooksContextRequest,
+) (*internal_hooks_v1.GetHooksContextResponse, error) {
+	hooksContext := s.manager.GetHooksContext(req.Id)
+	if hooksContext == nil {
+		return nil, status.Errorf(codes.NotFound, "invalid hooks context id")
+	}
+
+	var counters []*internal_hooks_v1.GetHooksContextResponse_Counter
+	for counterID, value := range hooksContext.GetCounterValues() {
+		counters = append(counters, &internal_hooks_v1.GetHooksContextResponse_Counter{
+			Id:    counterID,
+			Value: value,
+		})
+	}
+
+	sort.Slice(counters, func(i, j int) bool { return counters[i].Id < counters[j].Id })
+
+	var barriers []*internal_hooks_v1.GetHooksContextResponse_Barrier
+	for barrierID, waitIDs := range hooksContext.GetBarrierWaiters() {
+		barriers = append(barriers, &internal_hooks_v1.GetHooksContextResponse_Barrier{
+			Id:      barrierID,
+			WaitIds: waitIDs,
+		})
+	}
+
+	sort.Slice(barriers, func(i, j int) bool { return barriers[i].Id < barriers[j].Id })
+
+	var calls []*internal_hooks_v1.GetHooksContextResponse_Call
+	for _, call := range hooksContext.GetCallLog() {
+		psCall := &internal_hooks_v1.GetHooksContextResponse_Call{
+			RunId:     call.RunID,
+			Method:    call.Method,
+			Actions:   call.Actions,
+			StartTime: timestamppb.New(call.StartTime),
+			EndTime:   timestamppb.New(call.EndTime),
+		}
+
+		if reqMsg, ok := call.Request.(proto.Message); ok {
+			reqAny, err := anypb.New(reqMsg)
+			if err == nil {
+				psCall.Request = reqAny
+			}
+		}
+
+		if call.Err != nil {
+			psCall.Status = status.Convert(call.Err).Proto()
+		}
+
+		calls = append(calls, psCall)
+	}
+
+	return &internal_hooks_v1.GetHooksContextResponse{
+		Hooks:    hooksContext.GetHooks(),
+		Counters: counters,
+		Barriers: barriers,
+		Calls:    calls,
+	}, nil
+}
+
 func (s *grpcHooksServer) AddHooks(
 	ctx context.Context,
 	req *internal_hooks_v1.AddHooksRequest,
@@ -2,7 +2,10 @@ package hooks
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
 	"go.uber.org/zap"
@@ -14,15 +17,30 @@ type HooksContext struct {
 	counters map[string]*Counter
 	barriers map[string]*Barrier
 	hooks    map[string]*internal_hooks_v1.Hook
+	rands    map[int64]*rand.Rand
 	logger   *zap.Logger
+
+	defaultRand *rand.Rand
+
+	recordCalls bool
+	callLog     []*HookCall
+
+	// ttl and expiryTime are protected by the HooksManager lock rather
+	// than the context lock, since they are only used by the manager.
+	ttl        time.Duration
+	expiryTime time.Time
 }
 
-func newHooksContext(logger *zap.Logger) *HooksContext {
+func newHooksContext(logger *zap.Logger, ttl time.Duration, recordCalls bool) *HooksContext {
 	return &HooksContext{
-		counters: make(map[string]*Counter),
-		barriers: make(map[string]*Barrier),
-		hooks:    make(map[string]*internal_hooks_v1.Hook),
-		logger:   logger,
+		counters:    make(map[string]*Counter),
+		barriers:    make(map[string]*Barrier),
+		hooks:       make(map[string]*internal_hooks_v1.Hook),
+		rands:       make(map[int64]*rand.Rand),
+		logger:      logger,
+		recordCalls: recordCalls,
+		ttl:         ttl,
+		expiryTime:  time.Now().Add(ttl),
 	}
 }
 
@@ -62,6 +80,27 @@ func (i *HooksContext) GetBarrier(name string) *Barrier {
 	return i.getBarrierLocked(name)
 }
 
+// Gets the random number generator for a seed, creating it if it does not
+// exist.  Actions sharing a seed share a generator, so that a sequence of calls
+// produces the same sequence of values on every run.  A nil seed returns a
+// generator seeded from the current time.
+func (i *HooksContext) getRandLocked(seed *int64) *rand.Rand {
+	if seed == nil {
+		if i.defaultRand == nil {
+			i.defaultRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		return i.defaultRand
+	}
+
+	rng := i.rands[*seed]
+	if rng == nil {
+		rng = rand.New(rand.NewSource(*seed))
+		i.rands[*seed] = rng
+	}
+
+	return rng
+}
+
 // TODO(brett19): This is called "AddHook" but technically is more like "SetHook"
 func (i *HooksContext) AddHook(hook *internal_hooks_v1.Hook) {
 	i.lock.Lock()
@@ -84,7 +123,110 @@ func (i *HooksContext) HandleUnaryCall(
 
 	i.logger.Info("calling registered hook: %+v", zap.Any("hook", hook))
 	rs := newRunState(i, handler, hook, i.logger.Named("run-state"))
-	return rs.Run(ctx, req)
+	startTime := time.Now()
+	resp, err = rs.Run(ctx, req)
+	i.recordCall(rs, req, startTime, err)
+	return resp, err
+}
+
+func (i *HooksContext) HandleStreamCall(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	hook := i.findHook(info.FullMethod)
+	if hook == nil {
+		// if there is no hook, we just run the default handler
+		return handler(srv, ss)
+	}
+
+	i.logger.Info("calling registered stream hook: %+v", zap.Any("hook", hook))
+	rs := newRunState(i, nil, hook, i.logger.Named("run-state"))
+	hs := newHooksServerStream(ss, rs)
+	startTime := time.Now()
+	err := handler(srv, hs)
+	if errors.Is(err, errStreamReplaced) {
+		// the hooks replaced the stream with their own responses, which have
+		// already been sent, so the stream completes successfully.
+		err = nil
+	}
+
+	i.recordCall(rs, hs.req, startTime, err)
+	return err
+}
+
+func (i *HooksContext) recordCall(rs *runState, req interface{}, startTime time.Time, err error) {
+	if !i.recordCalls {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.callLog = append(i.callLog, &HookCall{
+		RunID:     rs.ID,
+		Method:    rs.Hook.TargetMethod,
+		Request:   req,
+		Actions:   rs.ActionLog,
+		StartTime: startTime,
+		EndTime:   time.Now(),
+		Err:       err,
+	})
+
+	if len(i.callLog) > hooksContextMaxCallLogEntries {
+		i.callLog = i.callLog[len(i.callLog)-hooksContextMaxCallLogEntries:]
+	}
+}
+
+// Gets a copy of all the registered hooks.
+func (i *HooksContext) GetHooks() []*internal_hooks_v1.Hook {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	hooks := make([]*internal_hooks_v1.Hook, 0, len(i.hooks))
+	for _, hook := range i.hooks {
+		hooks = append(hooks, hook)
+	}
+
+	return hooks
+}
+
+// Gets a snapshot of the values of all counters.
+func (i *HooksContext) GetCounterValues() map[string]int64 {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	values := make(map[string]int64, len(i.counters))
+	for name, counter := range i.counters {
+		values[name] = counter.Get()
+	}
+
+	return values
+}
+
+// Gets the IDs of the waiters currently blocked on each barrier.
+func (i *HooksContext) GetBarrierWaiters() map[string][]string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	waiters := make(map[string][]string, len(i.barriers))
+	for name, barrier := range i.barriers {
+		waiters[name] = barrier.WaiterIDs()
+	}
+
+	return waiters
+}
+
+// Gets a copy of the call log, this is always empty unless call recording
+// was enabled when the context was created.
+func (i *HooksContext) GetCallLog() []*HookCall {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	callLog := make([]*HookCall, len(i.callLog))
+	copy(callLog, i.callLog)
+	return callLog
 }
 
 func (i *HooksContext) findHook(methodName string) *internal_hooks_v1.Hook {
@@ -1,14 +1,33 @@
 package hooks
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
+// hooksContextDefaultTTL is how long a hooks context is kept after it was last
+// used when no TTL is specified during its creation.
+const hooksContextDefaultTTL = 1 * time.Hour
+
+// hooksContextReapInterval is how often the reaper checks for expired hooks
+// contexts.
+const hooksContextReapInterval = 1 * time.Minute
+
+type HooksContextOptions struct {
+	// TTL is how long the context is kept after it was last used before it is
+	// reaped.  Zero uses the default TTL.
+	TTL time.Duration
+
+	// RecordCalls enables recording of every call handled by the context.
+	RecordCalls bool
+}
+
 type HooksManager struct {
 	lock          sync.Mutex
 	hooksContexts map[string]*HooksContext
@@ -22,16 +41,72 @@ func NewHooksManager(logger *zap.Logger) *HooksManager {
 	}
 }
 
-func (m *HooksManager) CreateHooksContext(hooksContextID string) error {
+// reap removes any hooks contexts which have not been used within their
+// TTL, these are typically left behind by test runs which crashed.
+func (m *HooksManager) reap() {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	_, ok := m.hooksContexts[hooksContextID]
-	if ok {
+	now := time.Now()
+	for hooksContextID, hooksContext := range m.hooksContexts {
+		if now.After(hooksContext.expiryTime) {
+			m.logger.Info("reaping expired hooks context", zap.String("hooks-id", hooksContextID))
+			delete(m.hooksContexts, hooksContextID)
+		}
+	}
+}
+
+// RunReaper periodically reaps expired hooks contexts until the context is
+// cancelled.
+func (m *HooksManager) RunReaper(ctx context.Context) {
+	m.runReaper(ctx, hooksContextReapInterval)
+}
+
+func (m *HooksManager) runReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reap()
+		}
+	}
+}
+
+// getUnexpiredLocked returns the hooks context with the given id, removing it
+// instead if it has expired but has not yet been reaped.
+func (m *HooksManager) getUnexpiredLocked(hooksContextID string) *HooksContext {
+	hooksContext := m.hooksContexts[hooksContextID]
+	if hooksContext != nil && time.Now().After(hooksContext.expiryTime) {
+		m.logger.Info("reaping expired hooks context", zap.String("hooks-id", hooksContextID))
+		delete(m.hooksContexts, hooksContextID)
+		return nil
+	}
+
+	return hooksContext
+}
+
+func (m *HooksManager) CreateHooksContext(hooksContextID string, opts *HooksContextOptions) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.getUnexpiredLocked(hooksContextID) != nil {
 		return errors.New("existing hooks context already exists")
 	}
 
-	hooksContext := newHooksContext(m.logger.Named("hook-context"))
+	ttl := hooksContextDefaultTTL
+	recordCalls := false
+	if opts != nil {
+		if opts.TTL > 0 {
+			ttl = opts.TTL
+		}
+		recordCalls = opts.RecordCalls
+	}
+
+	hooksContext := newHooksContext(m.logger.Named("hook-context"), ttl, recordCalls)
 	m.hooksContexts[hooksContextID] = hooksContext
 
 	return nil
@@ -41,7 +116,12 @@ func (m *HooksManager) GetHooksContext(hooksContextID string) *HooksContext {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	return m.hooksContexts[hooksContextID]
+	hooksContext := m.getUnexpiredLocked(hooksContextID)
+	if hooksContext != nil {
+		hooksContext.expiryTime = time.Now().Add(hooksContext.ttl)
+	}
+
+	return hooksContext
 }
 
 func (m *HooksManager) DestroyHooksContext(hooksContextID string) error {
@@ -67,3 +147,7 @@ func (m *HooksManager) Server() internal_hooks_v1.HooksServiceServer {
 func (m *HooksManager) UnaryInterceptor() grpc.UnaryServerInterceptor {
 	return makeGrpcUnaryInterceptor(m, m.logger)
 }
+
+func (m *HooksManager) StreamInterceptor() grpc.StreamServerInterceptor {
+	return makeGrpcStreamInterceptor(m, m.logger)
+}
@@ -0,0 +1,90 @@
+package hooks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestHooksManagerReapsExpiredContexts(t *testing.T) {
+	manager := NewHooksManager(zap.NewNop())
+
+	err := manager.CreateHooksContext("short", &HooksContextOptions{
+		TTL: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create hooks context: %s", err)
+	}
+
+	err = manager.CreateHooksContext("long", nil)
+	if err != nil {
+		t.Fatalf("failed to create hooks context: %s", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if manager.GetHooksContext("short") != nil {
+		t.Fatalf("expired hooks context was not reaped")
+	}
+	if manager.GetHooksContext("long") == nil {
+		t.Fatalf("unexpired hooks context was reaped")
+	}
+
+	// an expired context id can be reused once it has been reaped
+	err = manager.CreateHooksContext("short", nil)
+	if err != nil {
+		t.Fatalf("failed to recreate reaped hooks context: %s", err)
+	}
+}
+
+func TestHooksManagerUseExtendsContextTTL(t *testing.T) {
+	manager := NewHooksManager(zap.NewNop())
+
+	err := manager.CreateHooksContext("ctx", &HooksContextOptions{
+		TTL: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create hooks context: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(40 * time.Millisecond)
+
+		if manager.GetHooksContext("ctx") == nil {
+			t.Fatalf("hooks context in use was reaped")
+		}
+	}
+}
+
+func TestHooksManagerReaperRemovesUnusedContexts(t *testing.T) {
+	manager := NewHooksManager(zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go manager.runReaper(ctx, 5*time.Millisecond)
+
+	err := manager.CreateHooksContext("short", &HooksContextOptions{
+		TTL: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create hooks context: %s", err)
+	}
+
+	// the context is never looked up again, so only the reaper can remove it
+	deadline := time.Now().Add(time.Second)
+	for {
+		manager.lock.Lock()
+		numContexts := len(manager.hooksContexts)
+		manager.lock.Unlock()
+
+		if numContexts == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired hooks context was not reaped")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,98 @@
+package hooks
+
+import (
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// errStreamReplaced is returned to the stream handler once hooks have sent
+// their own responses in place of the real ones, it causes the handler to
+// stop and is translated back into a successful stream completion.
+var errStreamReplaced = errors.New("stream was replaced by hook responses")
+
+// hooksServerStream wraps a server stream to run hook actions for streaming
+// calls.  The hook's Actions are run once the request has been received,
+// before any responses are sent, and its SendActions are run before each
+// response message is sent.
+type hooksServerStream struct {
+	grpc.ServerStream
+
+	runState *runState
+	req      interface{}
+}
+
+func newHooksServerStream(ss grpc.ServerStream, rs *runState) *hooksServerStream {
+	return &hooksServerStream{
+		ServerStream: ss,
+		runState:     rs,
+	}
+}
+
+func (s *hooksServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	if s.req != nil {
+		return nil
+	}
+	s.req = m
+
+	resps, err := s.runState.RunStreamActions(s.Context(), m, s.runState.Hook.Actions)
+	if err != nil {
+		return err
+	}
+
+	if len(resps) > 0 {
+		err := s.sendResponses(resps)
+		if err != nil {
+			return err
+		}
+
+		return errStreamReplaced
+	}
+
+	return nil
+}
+
+func (s *hooksServerStream) SendMsg(m interface{}) error {
+	if len(s.runState.Hook.SendActions) > 0 {
+		// the message being sent acts as the executed result, so that send
+		// actions are able to patch it before it goes out.
+		s.runState.ExecResult, s.runState.ExecError = m, nil
+
+		resps, err := s.runState.RunStreamActions(s.Context(), s.req, s.runState.Hook.SendActions)
+		if err != nil {
+			return err
+		}
+
+		// responses produced by send actions are sent in place of the message
+		if len(resps) > 0 {
+			return s.sendResponses(resps)
+		}
+	}
+
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *hooksServerStream) sendResponses(resps []interface{}) error {
+	for _, resp := range resps {
+		if anyResp, ok := resp.(*anypb.Any); ok {
+			msg, err := anyResp.UnmarshalNew()
+			if err != nil {
+				return err
+			}
+			resp = msg
+		}
+
+		err := s.ServerStream.SendMsg(resp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
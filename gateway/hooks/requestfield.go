@@ -0,0 +1,272 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type requestFieldPathPart struct {
+	Name string
+
+	// Index is either a list index or a map key, when HasIndex is set.
+	Index    string
+	HasIndex bool
+}
+
+// parseRequestFieldPath parses a path such as `items[2].key` or
+// `labels[name]` into its parts.  Names may refer to a field or a oneof.
+func parseRequestFieldPath(path string) ([]requestFieldPathPart, error) {
+	if path == "" {
+		return nil, errors.New("empty request field path")
+	}
+
+	var parts []requestFieldPathPart
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		var indexes []string
+
+		if bracketIdx := strings.IndexByte(segment, '['); bracketIdx >= 0 {
+			name = segment[:bracketIdx]
+			rest := segment[bracketIdx:]
+			for rest != "" {
+				if rest[0] != '[' {
+					return nil, fmt.Errorf("invalid request field path segment `%s`", segment)
+				}
+
+				closeIdx := strings.IndexByte(rest, ']')
+				if closeIdx < 0 {
+					return nil, fmt.Errorf("unterminated index in request field path segment `%s`", segment)
+				}
+
+				indexes = append(indexes, rest[1:closeIdx])
+				rest = rest[closeIdx+1:]
+			}
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("missing field name in request field path segment `%s`", segment)
+		}
+
+		parts = append(parts, requestFieldPathPart{Name: name})
+		for _, index := range indexes {
+			parts = append(parts, requestFieldPathPart{Index: index, HasIndex: true})
+		}
+	}
+
+	return parts, nil
+}
+
+// resolveRequestField walks a request message using protoreflect and returns
+// the value at path in a form which govalcmp is able to compare.
+func resolveRequestField(req interface{}, path string) (interface{}, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, errors.New("request is not a protobuf message")
+	}
+
+	parts, err := parseRequestFieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fd protoreflect.FieldDescriptor
+	val := protoreflect.ValueOfMessage(msg.ProtoReflect())
+	isList, isMap := false, false
+
+	for _, part := range parts {
+		if part.HasIndex {
+			switch {
+			case isList:
+				list := val.List()
+				idx, err := strconv.Atoi(part.Index)
+				if err != nil {
+					return nil, fmt.Errorf("invalid list index `%s` for field `%s`", part.Index, fd.Name())
+				}
+				if idx < 0 || idx >= list.Len() {
+					return nil, nil
+				}
+				val = list.Get(idx)
+			case isMap:
+				mapKey, err := requestFieldMapKey(fd.MapKey(), part.Index)
+				if err != nil {
+					return nil, err
+				}
+				mapVal := val.Map()
+				if !mapVal.Has(mapKey) {
+					return nil, nil
+				}
+				val = mapVal.Get(mapKey)
+				fd = fd.MapValue()
+			default:
+				return nil, fmt.Errorf("cannot index into non-repeated field `%s`", fd.Name())
+			}
+
+			isList, isMap = false, false
+			continue
+		}
+
+		if isList || isMap || (fd != nil && fd.Kind() != protoreflect.MessageKind) {
+			return nil, fmt.Errorf("cannot access `%s` of a non-message value", part.Name)
+		}
+
+		msgVal := val.Message()
+		desc := msgVal.Descriptor()
+
+		fd = desc.Fields().ByName(protoreflect.Name(part.Name))
+		if fd == nil {
+			oneof := desc.Oneofs().ByName(protoreflect.Name(part.Name))
+			if oneof == nil {
+				return nil, fmt.Errorf("unknown field `%s` in %s", part.Name, desc.FullName())
+			}
+
+			// a oneof resolves to whichever of its fields is set
+			fd = msgVal.WhichOneof(oneof)
+			if fd == nil {
+				return nil, nil
+			}
+		}
+
+		if fd.HasPresence() && !msgVal.Has(fd) {
+			return nil, nil
+		}
+
+		val = msgVal.Get(fd)
+		isList, isMap = fd.IsList(), fd.IsMap()
+	}
+
+	if fd == nil {
+		return requestFieldMessageToJson(msg)
+	}
+
+	return requestFieldValueToGo(fd, val, isList, isMap)
+}
+
+func requestFieldMapKey(fd protoreflect.FieldDescriptor, key string) (protoreflect.MapKey, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(key).MapKey(), nil
+	case protoreflect.BoolKind:
+		val, err := strconv.ParseBool(key)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		return protoreflect.ValueOfBool(val).MapKey(), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		val, err := strconv.ParseInt(key, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		return protoreflect.ValueOfInt32(int32(val)).MapKey(), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		val, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		return protoreflect.ValueOfInt64(val).MapKey(), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		val, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		return protoreflect.ValueOfUint32(uint32(val)).MapKey(), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		val, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, err
+		}
+		return protoreflect.ValueOfUint64(val).MapKey(), nil
+	}
+
+	return protoreflect.MapKey{}, fmt.Errorf("unsupported map key kind %s", fd.Kind())
+}
+
+// requestFieldValueToGo converts a protoreflect value into a plain Go value.
+// Enums resolve to their value name, and messages, lists and maps resolve to
+// their JSON representation.
+func requestFieldValueToGo(
+	fd protoreflect.FieldDescriptor,
+	val protoreflect.Value,
+	isList, isMap bool,
+) (interface{}, error) {
+	if isList {
+		list := val.List()
+		items := make([]json.RawMessage, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			item, err := requestFieldValueToGo(fd, list.Get(i), false, false)
+			if err != nil {
+				return nil, err
+			}
+
+			itemJson, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = itemJson
+		}
+
+		itemsJson, err := json.Marshal(items)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(itemsJson), nil
+	}
+
+	if isMap {
+		entries := make(map[string]json.RawMessage)
+		var rangeErr error
+		val.Map().Range(func(key protoreflect.MapKey, mapVal protoreflect.Value) bool {
+			item, err := requestFieldValueToGo(fd.MapValue(), mapVal, false, false)
+			if err != nil {
+				rangeErr = err
+				return false
+			}
+
+			itemJson, err := json.Marshal(item)
+			if err != nil {
+				rangeErr = err
+				return false
+			}
+
+			entries[key.String()] = itemJson
+			return true
+		})
+		if rangeErr != nil {
+			return nil, rangeErr
+		}
+
+		entriesJson, err := json.Marshal(entries)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(entriesJson), nil
+	}
+
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		enumVal := fd.Enum().Values().ByNumber(val.Enum())
+		if enumVal == nil {
+			return int64(val.Enum()), nil
+		}
+		return string(enumVal.Name()), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return requestFieldMessageToJson(val.Message().Interface())
+	}
+
+	return val.Interface(), nil
+}
+
+func requestFieldMessageToJson(msg proto.Message) (interface{}, error) {
+	msgJson, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(msgJson), nil
+}
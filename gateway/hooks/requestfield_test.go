@@ -0,0 +1,85 @@
+package hooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestResolveRequestField(t *testing.T) {
+	req, err := structpb.NewStruct(map[string]interface{}{
+		"key":   "foo",
+		"count": 3,
+		"items": []interface{}{"a", "b"},
+		"nested": map[string]interface{}{
+			"flag": true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build request: %s", err)
+	}
+
+	testResolve := func(path string, expected interface{}) {
+		val, err := resolveRequestField(req, path)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %s", path, err)
+		}
+
+		ok, err := (&runState{}).compare(val, internal_hooks_v1.ComparisonOperator_COMPARISON_OPERATOR_EQUAL, expected)
+		if err != nil {
+			t.Fatalf("failed to compare %s: %s", path, err)
+		}
+		if !ok {
+			t.Fatalf("unexpected value for %s: %v", path, val)
+		}
+	}
+
+	// map indexing, then a oneof by name and by field
+	testResolve("fields[key].kind", "foo")
+	testResolve("fields[key].string_value", "foo")
+	testResolve("fields[count].number_value", 3)
+
+	// repeated indexes and nested messages
+	testResolve("fields[items].list_value.values[1].string_value", "b")
+	testResolve("fields[nested].struct_value.fields[flag].bool_value", true)
+
+	// unset values resolve to nil
+	val, err := resolveRequestField(req, "fields[missing]")
+	if err != nil || val != nil {
+		t.Fatalf("expected missing map key to resolve to nil, got %v, %v", val, err)
+	}
+
+	val, err = resolveRequestField(req, "fields[items].list_value.values[5]")
+	if err != nil || val != nil {
+		t.Fatalf("expected out of range index to resolve to nil, got %v, %v", val, err)
+	}
+
+	// messages resolve to their JSON form
+	val, err = resolveRequestField(req, "fields[nested].struct_value")
+	if err != nil {
+		t.Fatalf("failed to resolve message: %s", err)
+	}
+	if _, ok := val.(json.RawMessage); !ok {
+		t.Fatalf("expected message to resolve to json, got %T", val)
+	}
+}
+
+func TestResolveRequestFieldErrors(t *testing.T) {
+	req := structpb.NewStringValue("foo")
+
+	badPaths := []string{
+		"",
+		"unknown_field",
+		"string_value[0]",
+		"string_value.child",
+		"kind[",
+	}
+	for _, path := range badPaths {
+		_, err := resolveRequestField(req, path)
+		if err == nil {
+			t.Fatalf("expected an error resolving %s", path)
+		}
+	}
+}
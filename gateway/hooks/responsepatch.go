@@ -0,0 +1,270 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// patchMessageField sets the field at path within msg to a JSON value, using
+// the same path syntax as request field references.  The JSON value follows the
+// protojson mapping, so enums may be given by name and messages as objects,
+// and a null value clears the field.
+func patchMessageField(msg proto.Message, path string, jsonValue []byte) error {
+	parts, err := parseRequestFieldPath(path)
+	if err != nil {
+		return err
+	}
+
+	curMsg := msg.ProtoReflect()
+	for partIdx := 0; partIdx < len(parts); partIdx++ {
+		part := parts[partIdx]
+		if part.HasIndex {
+			return fmt.Errorf("cannot index into non-repeated field in path `%s`", path)
+		}
+
+		fd := curMsg.Descriptor().Fields().ByName(protoreflect.Name(part.Name))
+		if fd == nil {
+			return fmt.Errorf("unknown field `%s` in %s", part.Name, curMsg.Descriptor().FullName())
+		}
+
+		if partIdx == len(parts)-1 {
+			return patchField(curMsg, fd, jsonValue)
+		}
+
+		nextPart := parts[partIdx+1]
+		if nextPart.HasIndex {
+			partIdx++
+			isLeaf := partIdx == len(parts)-1
+
+			switch {
+			case fd.IsList():
+				list := curMsg.Mutable(fd).List()
+				idx, err := strconv.Atoi(nextPart.Index)
+				if err != nil || idx < 0 || idx >= list.Len() {
+					return fmt.Errorf("invalid list index `%s` for field `%s`", nextPart.Index, fd.Name())
+				}
+
+				if isLeaf {
+					val, err := decodeFieldValue(fd, list.NewElement, jsonValue)
+					if err != nil {
+						return err
+					}
+					list.Set(idx, val)
+					return nil
+				}
+
+				if fd.Kind() != protoreflect.MessageKind {
+					return fmt.Errorf("cannot access fields of non-message list `%s`", fd.Name())
+				}
+				curMsg = list.Get(idx).Message()
+			case fd.IsMap():
+				mapVal := curMsg.Mutable(fd).Map()
+				mapKey, err := requestFieldMapKey(fd.MapKey(), nextPart.Index)
+				if err != nil {
+					return err
+				}
+
+				if isLeaf {
+					val, err := decodeFieldValue(fd.MapValue(), mapVal.NewValue, jsonValue)
+					if err != nil {
+						return err
+					}
+					mapVal.Set(mapKey, val)
+					return nil
+				}
+
+				if fd.MapValue().Kind() != protoreflect.MessageKind {
+					return fmt.Errorf("cannot access fields of non-message map `%s`", fd.Name())
+				}
+				curMsg = mapVal.Mutable(mapKey).Message()
+			default:
+				return fmt.Errorf("cannot index into non-repeated field `%s`", fd.Name())
+			}
+
+			continue
+		}
+
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("cannot access `%s` of a non-message field", nextPart.Name)
+		}
+
+		curMsg = curMsg.Mutable(fd).Message()
+	}
+
+	return errors.New("empty patch path")
+}
+
+func patchField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, jsonValue []byte) error {
+	if string(jsonValue) == "null" {
+		msg.Clear(fd)
+		return nil
+	}
+
+	switch {
+	case fd.IsList():
+		var items []json.RawMessage
+		err := json.Unmarshal(jsonValue, &items)
+		if err != nil {
+			return fmt.Errorf("invalid value for list field `%s`: %w", fd.Name(), err)
+		}
+
+		listVal := msg.NewField(fd)
+		list := listVal.List()
+		for _, item := range items {
+			val, err := decodeFieldValue(fd, list.NewElement, item)
+			if err != nil {
+				return err
+			}
+			list.Append(val)
+		}
+
+		msg.Set(fd, listVal)
+	case fd.IsMap():
+		var entries map[string]json.RawMessage
+		err := json.Unmarshal(jsonValue, &entries)
+		if err != nil {
+			return fmt.Errorf("invalid value for map field `%s`: %w", fd.Name(), err)
+		}
+
+		mapFieldVal := msg.NewField(fd)
+		mapVal := mapFieldVal.Map()
+		for key, entry := range entries {
+			mapKey, err := requestFieldMapKey(fd.MapKey(), key)
+			if err != nil {
+				return err
+			}
+
+			val, err := decodeFieldValue(fd.MapValue(), mapVal.NewValue, entry)
+			if err != nil {
+				return err
+			}
+			mapVal.Set(mapKey, val)
+		}
+
+		msg.Set(fd, mapFieldVal)
+	default:
+		val, err := decodeFieldValue(fd, func() protoreflect.Value { return msg.NewField(fd) }, jsonValue)
+		if err != nil {
+			return err
+		}
+
+		msg.Set(fd, val)
+	}
+
+	return nil
+}
+
+// decodeFieldValue decodes the JSON form of a single (non-repeated) value of a
+// field.  Messages are decoded with protojson, which also gives well-known
+// types such as Struct and Value their natural JSON forms.
+func decodeFieldValue(
+	fd protoreflect.FieldDescriptor,
+	newMessage func() protoreflect.Value,
+	jsonValue []byte,
+) (protoreflect.Value, error) {
+	invalidValueErr := func(err error) error {
+		return fmt.Errorf("invalid value for field `%s`: %w", fd.Name(), err)
+	}
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		val := newMessage()
+		err := protojson.Unmarshal(jsonValue, val.Message().Interface())
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return val, nil
+	case protoreflect.BoolKind:
+		var val bool
+		err := json.Unmarshal(jsonValue, &val)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfBool(val), nil
+	case protoreflect.StringKind:
+		var val string
+		err := json.Unmarshal(jsonValue, &val)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfString(val), nil
+	case protoreflect.BytesKind:
+		// like protojson, bytes are expected to be base64 encoded
+		var val []byte
+		err := json.Unmarshal(jsonValue, &val)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfBytes(val), nil
+	case protoreflect.EnumKind:
+		var name string
+		if json.Unmarshal(jsonValue, &name) == nil {
+			enumVal := fd.Enum().Values().ByName(protoreflect.Name(name))
+			if enumVal == nil {
+				return protoreflect.Value{}, fmt.Errorf("unknown enum value `%s` for field `%s`", name, fd.Name())
+			}
+			return protoreflect.ValueOfEnum(enumVal.Number()), nil
+		}
+
+		num, err := strconv.ParseInt(string(jsonValue), 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(num)), nil
+	}
+
+	// the remaining kinds are all numeric, which protojson also permits to be
+	// given as strings (for 64-bit values especially).
+	numText := string(jsonValue)
+	var quotedNum string
+	if json.Unmarshal(jsonValue, &quotedNum) == nil {
+		numText = quotedNum
+	}
+
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		val, err := strconv.ParseInt(numText, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfInt32(int32(val)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		val, err := strconv.ParseInt(numText, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfInt64(val), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		val, err := strconv.ParseUint(numText, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfUint32(uint32(val)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		val, err := strconv.ParseUint(numText, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfUint64(val), nil
+	case protoreflect.FloatKind:
+		val, err := strconv.ParseFloat(numText, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfFloat32(float32(val)), nil
+	case protoreflect.DoubleKind:
+		val, err := strconv.ParseFloat(numText, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalidValueErr(err)
+		}
+		return protoreflect.ValueOfFloat64(val), nil
+	}
+
+	return protoreflect.Value{}, fmt.Errorf("unsupported kind %s for field `%s`", fd.Kind(), fd.Name())
+}
@@ -0,0 +1,68 @@
+package hooks
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPatchMessageField(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{
+		"key":   "foo",
+		"items": []interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build message: %s", err)
+	}
+
+	err = patchMessageField(msg, "fields[key].string_value", []byte(`"bar"`))
+	if err != nil {
+		t.Fatalf("failed to patch scalar field: %s", err)
+	}
+	if msg.Fields["key"].GetStringValue() != "bar" {
+		t.Fatalf("scalar field was not patched")
+	}
+
+	err = patchMessageField(msg, "fields[items].list_value.values[1]", []byte(`"c"`))
+	if err != nil {
+		t.Fatalf("failed to patch list element: %s", err)
+	}
+	if msg.Fields["items"].GetListValue().Values[1].GetStringValue() != "c" {
+		t.Fatalf("list element was not patched")
+	}
+
+	err = patchMessageField(msg, "fields[added]", []byte(`12`))
+	if err != nil {
+		t.Fatalf("failed to patch map value: %s", err)
+	}
+	if msg.Fields["added"].GetNumberValue() != 12 {
+		t.Fatalf("map value was not patched")
+	}
+
+	err = patchMessageField(msg, "fields[key].string_value", []byte(`null`))
+	if err != nil {
+		t.Fatalf("failed to clear field: %s", err)
+	}
+	if msg.Fields["key"].GetKind() != nil {
+		t.Fatalf("field was not cleared")
+	}
+}
+
+func TestPatchMessageFieldErrors(t *testing.T) {
+	msg := structpb.NewStringValue("foo")
+
+	err := patchMessageField(msg, "unknown_field", []byte(`1`))
+	if err == nil {
+		t.Fatalf("expected an error patching an unknown field")
+	}
+
+	err = patchMessageField(msg, "string_value", []byte(`{}`))
+	if err == nil {
+		t.Fatalf("expected an error patching with a mistyped value")
+	}
+
+	err = patchMessageField(msg, "string_value.child", []byte(`1`))
+	if err == nil {
+		t.Fatalf("expected an error patching beneath a scalar")
+	}
+}
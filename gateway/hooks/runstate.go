@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
 	"github.com/couchbase/stellar-gateway/contrib/govalcmp"
@@ -12,6 +13,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 // We encapsulate all the execution of actions into a runState to allow us to
@@ -25,6 +28,13 @@ type runState struct {
 	Logger       *zap.Logger
 	ExecResult   interface{}
 	ExecError    error
+
+	// Responses holds every response produced by the actions of a streaming
+	// call, since a stream can be replaced by more than one message.
+	Responses []interface{}
+
+	// ActionLog records the name of each action taken, for the call log.
+	ActionLog []string
 }
 
 func newRunState(
@@ -70,11 +80,40 @@ func (s *runState) Run(ctx context.Context, req interface{}) (interface{}, error
 	return s.Handler(ctx, req)
 }
 
+// RunStreamActions runs a set of actions on behalf of a streaming call and
+// returns any responses which they produced.  The stream itself is always
+// executed by the gRPC handler, so unlike Run there is no implicit execution.
+func (s *runState) RunStreamActions(
+	ctx context.Context,
+	req interface{},
+	actions []*internal_hooks_v1.HookAction,
+) ([]interface{}, error) {
+	err := s.HooksContext.acquireRunLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Responses = nil
+	_, err = s.runActions(ctx, req, actions)
+
+	s.HooksContext.releaseRunLock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Responses, nil
+}
+
 func (s *runState) compare(
 	left interface{},
 	op internal_hooks_v1.ComparisonOperator,
 	right interface{},
 ) (bool, error) {
+	if op == internal_hooks_v1.ComparisonOperator_COMPARISON_OPERATOR_CONTAINS {
+		return govalcmp.Contains(left, right)
+	}
+
 	delta, err := govalcmp.Compare(left, right)
 	if err != nil {
 		return false, err
@@ -118,7 +157,8 @@ func (s *runState) resolveValueRef_CounterValue(
 	req interface{},
 	ref *internal_hooks_v1.ValueRef_CounterValue,
 ) (interface{}, error) {
-	counter := s.HooksContext.GetCounter(ref.CounterValue)
+	// conditions are only checked by running actions, which hold the run lock
+	counter := s.HooksContext.getCounterLocked(ref.CounterValue)
 	return counter.Get(), nil
 }
 
@@ -127,7 +167,7 @@ func (s *runState) resolveValueRef_RequestField(
 	req interface{},
 	ref *internal_hooks_v1.ValueRef_RequestField,
 ) (interface{}, error) {
-	return nil, errors.New("unimplemented request field query")
+	return resolveRequestField(req, ref.RequestField)
 }
 
 func (s *runState) resolveValueRef_JsonValue(
@@ -208,11 +248,25 @@ func (s *runState) runActions(
 	return respOut, nil
 }
 
+// logAction records an action in the action log, keeping only the most recent
+// actions once the log is full.
+func (s *runState) logAction(name string) {
+	s.ActionLog = append(s.ActionLog, name)
+	if len(s.ActionLog) > hooksRunMaxActionLogEntries {
+		s.ActionLog = s.ActionLog[len(s.ActionLog)-hooksRunMaxActionLogEntries:]
+	}
+}
+
 func (s *runState) runAction(
 	ctx context.Context,
 	req interface{},
 	actions *internal_hooks_v1.HookAction,
 ) (interface{}, error) {
+	if _, isIf := actions.Action.(*internal_hooks_v1.HookAction_If_); !isIf {
+		// if actions record which of their branches was taken instead
+		s.logAction(hookActionName(actions))
+	}
+
 	switch action := actions.Action.(type) {
 	case *internal_hooks_v1.HookAction_If_:
 		return s.runAction_If(ctx, req, action.If)
@@ -228,7 +282,14 @@ func (s *runState) runAction(
 		return s.runAction_ReturnError(ctx, req, action.ReturnError)
 	case *internal_hooks_v1.HookAction_Execute_:
 		return s.runAction_Execute(ctx, req, action.Execute)
-
+	case *internal_hooks_v1.HookAction_Sleep_:
+		return s.runAction_Sleep(ctx, req, action.Sleep)
+	case *internal_hooks_v1.HookAction_FailWithProbability_:
+		return s.runAction_FailWithProbability(ctx, req, action.FailWithProbability)
+	case *internal_hooks_v1.HookAction_Hang_:
+		return s.runAction_Hang(ctx, req, action.Hang)
+	case *internal_hooks_v1.HookAction_PatchResponse_:
+		return s.runAction_PatchResponse(ctx, req, action.PatchResponse)
 	}
 
 	return nil, errors.New("invalid hook action type")
@@ -245,8 +306,10 @@ func (s *runState) runAction_If(
 	}
 
 	if ok {
+		s.logAction("if_match")
 		return s.runActions(ctx, req, action.Match)
 	} else {
+		s.logAction("if_no_match")
 		return s.runActions(ctx, req, action.NoMatch)
 	}
 }
@@ -298,7 +361,7 @@ func (s *runState) runAction_SignalBarrier(
 ) (interface{}, error) {
 	s.Logger.Info("hook signalling barrier", zap.Any("action", action))
 
-	barrier := s.HooksContext.GetBarrier(action.BarrierId)
+	barrier := s.HooksContext.getBarrierLocked(action.BarrierId)
 	if action.SignalAll {
 		barrier.SignalAll(nil)
 	} else {
@@ -315,6 +378,7 @@ func (s *runState) runAction_ReturnResponse(
 	req interface{},
 	action *internal_hooks_v1.HookAction_ReturnResponse,
 ) (interface{}, error) {
+	s.Responses = append(s.Responses, action.Value)
 	return action.Value, nil
 }
 
@@ -323,12 +387,7 @@ func (s *runState) runAction_ReturnError(
 	req interface{},
 	action *internal_hooks_v1.HookAction_ReturnError,
 ) (interface{}, error) {
-	st := status.New(codes.Code(action.Code), action.Message)
-	for _, detail := range action.Details {
-		st, _ = st.WithDetails(detail)
-	}
-
-	return nil, st.Err()
+	return nil, makeHookError(action.Code, action.Message, action.Details)
 }
 
 func (s *runState) runAction_Execute(
@@ -336,7 +395,127 @@ func (s *runState) runAction_Execute(
 	req interface{},
 	action *internal_hooks_v1.HookAction_Execute,
 ) (interface{}, error) {
+	if s.Handler == nil {
+		// streaming calls always continue into their handler
+		return nil, nil
+	}
+
 	s.ExecResult, s.ExecError = s.Handler(ctx, req)
 
 	return nil, nil
 }
+
+func (s *runState) runAction_Sleep(
+	ctx context.Context,
+	req interface{},
+	action *internal_hooks_v1.HookAction_Sleep,
+) (interface{}, error) {
+	sleepTime := action.Duration.AsDuration()
+	if action.Jitter != nil {
+		jitter := action.Jitter.AsDuration()
+		if jitter > 0 {
+			rng := s.HooksContext.getRandLocked(action.Seed)
+			sleepTime += time.Duration(rng.Int63n(int64(jitter)))
+		}
+	}
+
+	s.Logger.Info("hook sleeping", zap.Any("action", action), zap.Duration("sleepTime", sleepTime))
+
+	// we need to release the HooksContext runlock while we sleep to allow
+	// other calls to run while we are blocked.
+	s.HooksContext.releaseRunLock()
+
+	var ctxErr error
+	timer := time.NewTimer(sleepTime)
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+		ctxErr = ctx.Err()
+	}
+
+	err := s.HooksContext.acquireRunLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctxErr != nil {
+		return nil, status.FromContextError(ctxErr).Err()
+	}
+
+	s.Logger.Info("hook slept", zap.Any("action", action))
+
+	return nil, nil
+}
+
+func (s *runState) runAction_FailWithProbability(
+	ctx context.Context,
+	req interface{},
+	action *internal_hooks_v1.HookAction_FailWithProbability,
+) (interface{}, error) {
+	rng := s.HooksContext.getRandLocked(action.Seed)
+	if rng.Float64() >= action.Probability {
+		return nil, nil
+	}
+
+	s.Logger.Info("hook failing call", zap.Any("action", action))
+
+	return nil, makeHookError(action.Code, action.Message, action.Details)
+}
+
+func (s *runState) runAction_Hang(
+	ctx context.Context,
+	req interface{},
+	action *internal_hooks_v1.HookAction_Hang,
+) (interface{}, error) {
+	s.Logger.Info("hook hanging call until its deadline", zap.Any("action", action))
+
+	// we need to release the HooksContext runlock while we hang to allow
+	// other calls to run while we are blocked.
+	s.HooksContext.releaseRunLock()
+
+	<-ctx.Done()
+
+	err := s.HooksContext.acquireRunLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, status.FromContextError(ctx.Err()).Err()
+}
+
+func (s *runState) runAction_PatchResponse(
+	ctx context.Context,
+	req interface{},
+	action *internal_hooks_v1.HookAction_PatchResponse,
+) (interface{}, error) {
+	// a failed execution has no response to patch, its error is returned as-is
+	if s.ExecError != nil {
+		return nil, nil
+	}
+
+	resp, ok := s.ExecResult.(proto.Message)
+	if !ok {
+		return nil, errors.New("patching a response requires the call to be executed first")
+	}
+
+	for _, patch := range action.Patches {
+		err := patchMessageField(resp, patch.Path, patch.JsonValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.Logger.Info("hook patched response", zap.Any("action", action))
+
+	return nil, nil
+}
+
+func makeHookError(code int32, message string, details []*anypb.Any) error {
+	st := status.New(codes.Code(code), message)
+	for _, detail := range details {
+		st, _ = st.WithDetails(detail)
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunStateCounterCondition(t *testing.T) {
+	hooksContext := newHooksContext(zap.NewNop(), 0, false)
+
+	hook := &internal_hooks_v1.Hook{
+		Name:         "counter-condition",
+		TargetMethod: "/test/Method",
+		Actions: []*internal_hooks_v1.HookAction{
+			{
+				Action: &internal_hooks_v1.HookAction_Counter_{
+					Counter: &internal_hooks_v1.HookAction_Counter{
+						CounterId: "calls",
+						Delta:     1,
+					},
+				},
+			},
+			{
+				Action: &internal_hooks_v1.HookAction_If_{
+					If: &internal_hooks_v1.HookAction_If{
+						Cond: []*internal_hooks_v1.HookCondition{
+							{
+								Left: &internal_hooks_v1.ValueRef{
+									Value: &internal_hooks_v1.ValueRef_CounterValue{
+										CounterValue: "calls",
+									},
+								},
+								Op: internal_hooks_v1.ComparisonOperator_COMPARISON_OPERATOR_GREATER_THAN,
+								Right: &internal_hooks_v1.ValueRef{
+									Value: &internal_hooks_v1.ValueRef_JsonValue{
+										JsonValue: []byte("1"),
+									},
+								},
+							},
+						},
+						Match: []*internal_hooks_v1.HookAction{
+							{
+								Action: &internal_hooks_v1.HookAction_ReturnError_{
+									ReturnError: &internal_hooks_v1.HookAction_ReturnError{
+										Code:    int32(codes.Unavailable),
+										Message: "injected",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "handled", nil
+	}
+
+	run := func() error {
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := newRunState(hooksContext, handler, hook, zap.NewNop()).Run(context.Background(), nil)
+			errCh <- err
+		}()
+
+		select {
+		case err := <-errCh:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("hook run deadlocked checking a counter condition")
+			return nil
+		}
+	}
+
+	if err := run(); err != nil {
+		t.Fatalf("expected the first call to succeed, got %s", err)
+	}
+
+	if err := run(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected the second call to fail, got %v", err)
+	}
+}
+
+func TestRunStateActionLogIsCapped(t *testing.T) {
+	rs := &runState{}
+	for i := 0; i < hooksRunMaxActionLogEntries+10; i++ {
+		rs.logAction("counter")
+	}
+	rs.logAction("sleep")
+
+	if len(rs.ActionLog) != hooksRunMaxActionLogEntries {
+		t.Fatalf("expected %d actions, got %d", hooksRunMaxActionLogEntries, len(rs.ActionLog))
+	}
+	if rs.ActionLog[len(rs.ActionLog)-1] != "sleep" {
+		t.Fatalf("expected the most recent action to be kept")
+	}
+}
@@ -16,13 +16,18 @@ import (
 	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
 	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
 	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_user_v1"
+	"github.com/couchbase/goprotostellar/genproto/admin_view_v1"
 	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
+	"github.com/couchbase/goprotostellar/genproto/changefeed_v1"
 	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
 	"github.com/couchbase/goprotostellar/genproto/routing_v1"
 	"github.com/couchbase/goprotostellar/genproto/search_v1"
 	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	"github.com/couchbase/goprotostellar/genproto/view_v1"
+	"github.com/couchbase/stellar-gateway/gateway/capture"
 	"github.com/couchbase/stellar-gateway/gateway/dataimpl"
 	"github.com/couchbase/stellar-gateway/gateway/hooks"
 	"github.com/couchbase/stellar-gateway/gateway/sdimpl"
@@ -41,13 +46,17 @@ type SystemOptions struct {
 	Metrics  *metrics.SnMetrics
 
 	TlsConfig *tls.Config
+
+	// CaptureRecorder, when set, receives a record of every RPC handled.
+	CaptureRecorder *capture.Recorder
 }
 
 type System struct {
 	logger *zap.Logger
 
-	dataServer *grpc.Server
-	sdServer   *grpc.Server
+	hooksManager *hooks.HooksManager
+	dataServer   *grpc.Server
+	sdServer     *grpc.Server
 }
 
 func NewSystem(opts *SystemOptions) (*System, error) {
@@ -66,25 +75,51 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 		recovery.WithRecoveryHandler(customPanicHandlerFunc),
 	}
 
+	var unaryInterceptors []grpc.UnaryServerInterceptor
+	var streamInterceptors []grpc.StreamServerInterceptor
+
+	// capturing goes first so that the record reflects exactly what the
+	// client sent and received, including the effects of any hooks.
+	if opts.CaptureRecorder != nil {
+		captureInterceptor := capture.NewInterceptor(opts.Logger.Named("capture"), opts.CaptureRecorder)
+		unaryInterceptors = append(unaryInterceptors, captureInterceptor.UnaryInterceptor())
+		streamInterceptors = append(streamInterceptors, captureInterceptor.StreamInterceptor())
+	}
+
+	// metrics sit outside of the hooks so that requests which are failed or
+	// delayed by a hook are measured as the client observed them.
+	unaryInterceptors = append(unaryInterceptors,
+		metricsInterceptor.UnaryInterceptor,
+		hooksManager.UnaryInterceptor(),
+		recovery.UnaryServerInterceptor(panicRecoveryOpts...))
+	streamInterceptors = append(streamInterceptors,
+		metricsInterceptor.StreamInterceptor,
+		hooksManager.StreamInterceptor(),
+		recovery.StreamServerInterceptor(panicRecoveryOpts...))
+
 	// TODO(abose): Same serverOpts passed; need to break into two, if needed.
 	serverOpts := []grpc.ServerOption{
-		grpc.ChainUnaryInterceptor(hooksManager.UnaryInterceptor(),
-			metricsInterceptor.UnaryConnectionCounterInterceptor,
-			recovery.UnaryServerInterceptor(panicRecoveryOpts...)),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
 		grpc.Creds(credentials.NewTLS(opts.TlsConfig)),
+		grpc.StatsHandler(metricsInterceptor.StatsHandler()),
 	}
 
 	dataSrv := grpc.NewServer(serverOpts...)
 
 	internal_hooks_v1.RegisterHooksServiceServer(dataSrv, hooksManager.Server())
 	kv_v1.RegisterKvServiceServer(dataSrv, dataImpl.KvV1Server)
+	changefeed_v1.RegisterChangeFeedServiceServer(dataSrv, dataImpl.ChangeFeedV1Server)
 	query_v1.RegisterQueryServiceServer(dataSrv, dataImpl.QueryV1Server)
 	search_v1.RegisterSearchServiceServer(dataSrv, dataImpl.SearchV1Server)
 	analytics_v1.RegisterAnalyticsServiceServer(dataSrv, dataImpl.AnalyticsV1Server)
+	view_v1.RegisterViewServiceServer(dataSrv, dataImpl.ViewV1Server)
 	admin_bucket_v1.RegisterBucketAdminServiceServer(dataSrv, dataImpl.AdminBucketV1Server)
 	admin_collection_v1.RegisterCollectionAdminServiceServer(dataSrv, dataImpl.AdminCollectionV1Server)
 	admin_search_v1.RegisterSearchAdminServiceServer(dataSrv, dataImpl.AdminSearchIndexV1Server)
 	admin_query_v1.RegisterQueryAdminServiceServer(dataSrv, dataImpl.AdminQueryIndexV1Server)
+	admin_view_v1.RegisterViewAdminServiceServer(dataSrv, dataImpl.AdminViewV1Server)
+	admin_user_v1.RegisterUserAdminServiceServer(dataSrv, dataImpl.AdminUserV1Server)
 	transactions_v1.RegisterTransactionsServiceServer(dataSrv, dataImpl.TransactionsV1Server)
 
 	// health check
@@ -96,9 +131,10 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 	routing_v1.RegisterRoutingServiceServer(sdSrv, sdImpl.RoutingV1Server)
 
 	s := &System{
-		logger:     opts.Logger,
-		dataServer: dataSrv,
-		sdServer:   sdSrv,
+		logger:       opts.Logger,
+		hooksManager: hooksManager,
+		dataServer:   dataSrv,
+		sdServer:     sdSrv,
 	}
 
 	return s, nil
@@ -107,6 +143,8 @@ func NewSystem(opts *SystemOptions) (*System, error) {
 func (s *System) Serve(ctx context.Context, l *Listeners) error {
 	var wg sync.WaitGroup
 
+	go s.hooksManager.RunReaper(ctx)
+
 	go func() {
 		<-ctx.Done()
 		s.dataServer.Stop()
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/couchbase/goprotostellar/genproto/analytics_v1"
+	"github.com/stretchr/testify/assert"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestAnalytics() {
+	analyticsClient := analytics_v1.NewAnalyticsServiceClient(s.gatewayConn)
+
+	readAnalyticsStream := func(client analytics_v1.AnalyticsService_AnalyticsQueryClient) ([][]byte, *analytics_v1.AnalyticsQueryResponse_MetaData, error) {
+		var rows [][]byte
+		var md *analytics_v1.AnalyticsQueryResponse_MetaData
+
+		for {
+			resp, err := client.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return rows, md, err
+			}
+
+			rows = append(rows, resp.Rows...)
+			if resp.MetaData != nil {
+				md = resp.MetaData
+			}
+		}
+
+		return rows, md, nil
+	}
+
+	s.Run("Basic", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT VALUE v FROM [1, 2, 3] AS v ORDER BY v",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, md, err := readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+		assert.Equal(s.T(), [][]byte{[]byte("1"), []byte("2"), []byte("3")}, rows)
+
+		if assert.NotNil(s.T(), md) {
+			assert.NotEmpty(s.T(), md.RequestId)
+			if assert.NotNil(s.T(), md.Metrics) {
+				assert.Equal(s.T(), uint64(3), md.Metrics.ResultCount)
+			}
+		}
+	})
+
+	s.Run("BadCredentials", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT 1=1",
+		}, grpc.PerRPCCredentials(s.badRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.PermissionDenied)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "user")
+		})
+	})
+
+	s.Run("Unauthenticated", func() {
+		client, err := analyticsClient.AnalyticsQuery(context.Background(), &analytics_v1.AnalyticsQueryRequest{
+			Statement: "SELECT 1=1",
+		})
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readAnalyticsStream(client)
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+}
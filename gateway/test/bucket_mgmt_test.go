@@ -0,0 +1,45 @@
+package test
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
+	"github.com/stretchr/testify/assert"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestBucketManagement() {
+	bucketAdminClient := admin_bucket_v1.NewBucketAdminServiceClient(s.gatewayConn)
+
+	s.Run("GetBucket", func() {
+		resp, err := bucketAdminClient.GetBucket(context.Background(), &admin_bucket_v1.GetBucketRequest{
+			BucketName: s.bucketName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		assert.Equal(s.T(), s.bucketName, resp.Bucket.BucketName)
+		assert.True(s.T(), resp.Bucket.FlushEnabled)
+		assert.NotZero(s.T(), resp.Bucket.RamQuotaBytes)
+	})
+
+	s.Run("GetBucketMissing", func() {
+		_, err := bucketAdminClient.GetBucket(context.Background(), &admin_bucket_v1.GetBucketRequest{
+			BucketName: "invalid-bucket",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "bucket")
+		})
+	})
+
+	s.Run("FlushBucketMissing", func() {
+		_, err := bucketAdminClient.FlushBucket(context.Background(), &admin_bucket_v1.FlushBucketRequest{
+			BucketName: "invalid-bucket",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "bucket")
+		})
+	})
+}
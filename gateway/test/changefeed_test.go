@@ -0,0 +1,141 @@
+package test
+
+import (
+	"context"
+	"time"
+
+	"github.com/couchbase/goprotostellar/genproto/changefeed_v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestChangeFeed() {
+	changeFeedClient := changefeed_v1.NewChangeFeedServiceClient(s.gatewayConn)
+
+	s.Run("Basic", func() {
+		docId := s.testDocId()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := changeFeedClient.StreamChanges(ctx, &changefeed_v1.StreamChangesRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      &s.scopeName,
+			CollectionName: &s.collectionName,
+			IncludeContent: true,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		for {
+			event, err := client.Recv()
+			require.NoError(s.T(), err)
+
+			if event.Key != docId {
+				continue
+			}
+
+			assert.Equal(s.T(), changefeed_v1.StreamChangesResponse_EVENT_TYPE_MUTATION, event.Type)
+			assertValidCas(s.T(), event.Cas)
+			assert.Equal(s.T(), TEST_CONTENT, event.Content)
+			assert.Equal(s.T(), TEST_CONTENT_FLAGS, event.ContentFlags)
+			require.NotNil(s.T(), event.ResumeToken)
+			assert.NotZero(s.T(), event.ResumeToken.VbucketUuid)
+			assert.NotZero(s.T(), event.ResumeToken.SeqNo)
+			break
+		}
+	})
+
+	s.Run("VbucketSubset", func() {
+		docId := s.testDocId()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		readDocEvent := func(vbucketIds []uint32) *changefeed_v1.StreamChangesResponse {
+			client, err := changeFeedClient.StreamChanges(ctx, &changefeed_v1.StreamChangesRequest{
+				BucketName:     s.bucketName,
+				ScopeName:      &s.scopeName,
+				CollectionName: &s.collectionName,
+				VbucketIds:     vbucketIds,
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			requireRpcSuccess(s.T(), client, err)
+
+			for {
+				event, err := client.Recv()
+				require.NoError(s.T(), err)
+				require.NotNil(s.T(), event.ResumeToken)
+
+				if len(vbucketIds) > 0 {
+					assert.Contains(s.T(), vbucketIds, event.ResumeToken.VbucketId)
+				}
+
+				if event.Key == docId {
+					return event
+				}
+			}
+		}
+
+		// we first find which vbucket our document lives in, and then check that
+		// streaming only that vbucket still delivers the document.
+		event := readDocEvent(nil)
+		subsetEvent := readDocEvent([]uint32{event.ResumeToken.VbucketId})
+		assert.Equal(s.T(), event.ResumeToken.VbucketId, subsetEvent.ResumeToken.VbucketId)
+	})
+
+	s.Run("TokenOutsideSubset", func() {
+		client, err := changeFeedClient.StreamChanges(context.Background(), &changefeed_v1.StreamChangesRequest{
+			BucketName: s.bucketName,
+			VbucketIds: []uint32{0},
+			ResumeTokens: []*changefeed_v1.ResumeToken{
+				{VbucketId: 1},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = client.Recv()
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("CollectionWithoutScope", func() {
+		client, err := changeFeedClient.StreamChanges(context.Background(), &changefeed_v1.StreamChangesRequest{
+			BucketName:     s.bucketName,
+			CollectionName: &s.collectionName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = client.Recv()
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("InvalidVbucket", func() {
+		client, err := changeFeedClient.StreamChanges(context.Background(), &changefeed_v1.StreamChangesRequest{
+			BucketName: s.bucketName,
+			ResumeTokens: []*changefeed_v1.ResumeToken{
+				{VbucketId: 99999},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = client.Recv()
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("CollectionMissing", func() {
+		missingCollectionName := "invalid-collection"
+		client, err := changeFeedClient.StreamChanges(context.Background(), &changefeed_v1.StreamChangesRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      &s.scopeName,
+			CollectionName: &missingCollectionName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = client.Recv()
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "collection")
+		})
+	})
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestCollectionManagement() {
+	collectionAdminClient := admin_collection_v1.NewCollectionAdminServiceClient(s.gatewayConn)
+
+	findCollection := func(scopeName, collectionName string) *admin_collection_v1.ListCollectionsResponse_Collection {
+		resp, err := collectionAdminClient.ListCollections(context.Background(), &admin_collection_v1.ListCollectionsRequest{
+			BucketName: s.bucketName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+
+		for _, scope := range resp.Scopes {
+			if scope.Name != scopeName {
+				continue
+			}
+
+			for _, collection := range scope.Collections {
+				if collection.Name == collectionName {
+					return collection
+				}
+			}
+		}
+		return nil
+	}
+
+	s.Run("CreateUpdateDelete", func() {
+		collectionName := uuid.NewString()[:6]
+		maxExpiry := uint32(3600)
+
+		createResp, err := collectionAdminClient.CreateCollection(context.Background(), &admin_collection_v1.CreateCollectionRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: collectionName,
+			MaxExpirySecs:  &maxExpiry,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), createResp, err)
+
+		collection := findCollection(s.scopeName, collectionName)
+		require.NotNil(s.T(), collection)
+		require.NotNil(s.T(), collection.MaxExpirySecs)
+		assert.Equal(s.T(), maxExpiry, *collection.MaxExpirySecs)
+
+		newMaxExpiry := uint32(7200)
+		updateResp, err := collectionAdminClient.UpdateCollection(context.Background(), &admin_collection_v1.UpdateCollectionRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: collectionName,
+			MaxExpirySecs:  &newMaxExpiry,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), updateResp, err)
+
+		collection = findCollection(s.scopeName, collectionName)
+		require.NotNil(s.T(), collection)
+		require.NotNil(s.T(), collection.MaxExpirySecs)
+		assert.Equal(s.T(), newMaxExpiry, *collection.MaxExpirySecs)
+
+		deleteResp, err := collectionAdminClient.DeleteCollection(context.Background(), &admin_collection_v1.DeleteCollectionRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: collectionName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), deleteResp, err)
+
+		assert.Nil(s.T(), findCollection(s.scopeName, collectionName))
+	})
+
+	s.Run("UpdateCollectionMissing", func() {
+		maxExpiry := uint32(3600)
+		_, err := collectionAdminClient.UpdateCollection(context.Background(), &admin_collection_v1.UpdateCollectionRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: "invalid-collection",
+			MaxExpirySecs:  &maxExpiry,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "collection")
+		})
+	})
+
+	s.Run("UpdateNoSettings", func() {
+		_, err := collectionAdminClient.UpdateCollection(context.Background(), &admin_collection_v1.UpdateCollectionRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("UpdateScopeMissing", func() {
+		maxExpiry := uint32(3600)
+		_, err := collectionAdminClient.UpdateCollection(context.Background(), &admin_collection_v1.UpdateCollectionRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      "invalid-scope",
+			CollectionName: s.collectionName,
+			MaxExpirySecs:  &maxExpiry,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "scope")
+		})
+	})
+}
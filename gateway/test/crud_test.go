@@ -2,16 +2,23 @@ package test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 )
@@ -114,6 +121,84 @@ func (s *GatewayOpsTestSuite) TestGet() {
 		assert.Nil(s.T(), resp.Expiry)
 	})
 
+	s.Run("Projection", func() {
+		docId := s.randomDocId()
+		s.createDocument(createDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        []byte(`{"name":"frank","address":{"city":"london","zip":"e1"},"tags":["a","b"],"age":32}`),
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		})
+
+		resp, err := kvClient.Get(context.Background(), &kv_v1.GetRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Project:        []string{"name", "address.city", "tags[1]", "missing"},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		assertValidCas(s.T(), resp.Cas)
+		assert.JSONEq(s.T(), `{"name":"frank","address":{"city":"london"},"tags":["b"]}`, string(resp.Content))
+		assert.Equal(s.T(), resp.ContentFlags, TEST_CONTENT_FLAGS)
+	})
+
+	s.Run("ProjectionFallback", func() {
+		docContent := make(map[string]int)
+		var projectPaths []string
+		for i := 0; i < 20; i++ {
+			fieldName := fmt.Sprintf("field%d", i)
+			docContent[fieldName] = i
+			if i%2 == 0 {
+				projectPaths = append(projectPaths, fieldName)
+			}
+		}
+		docBytes, _ := json.Marshal(docContent)
+
+		docId := s.randomDocId()
+		s.createDocument(createDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        docBytes,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		})
+
+		// 15 paths will not fit into a single subdocument request
+		projectPaths = append(projectPaths, "field1", "field3", "field5", "field7", "field9")
+
+		resp, err := kvClient.Get(context.Background(), &kv_v1.GetRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Project:        projectPaths,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+
+		var respContent map[string]int
+		err = json.Unmarshal(resp.Content, &respContent)
+		require.NoError(s.T(), err)
+		assert.Len(s.T(), respContent, len(projectPaths))
+		for _, path := range projectPaths {
+			assert.Equal(s.T(), docContent[path], respContent[path])
+		}
+	})
+
+	s.Run("ProjectionInvalidPath", func() {
+		_, err := kvClient.Get(context.Background(), &kv_v1.GetRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            s.testDocId(),
+			Project:        []string{"foo[bar"},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
 	s.Run("DocLocked", func() {
 		_, err := kvClient.Get(context.Background(), &kv_v1.GetRequest{
 			BucketName:     s.bucketName,
@@ -151,6 +236,367 @@ func (s *GatewayOpsTestSuite) TestGet() {
 	})
 }
 
+func (s *GatewayOpsTestSuite) TestGetAllReplicas() {
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	readAllReplicas := func(client kv_v1.KvService_GetAllReplicasClient) ([]*kv_v1.GetAllReplicasResponse, error) {
+		var results []*kv_v1.GetAllReplicasResponse
+		for {
+			resp, err := client.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return results, err
+			}
+
+			results = append(results, resp)
+		}
+
+		return results, nil
+	}
+
+	s.Run("Basic", func() {
+		client, err := kvClient.GetAllReplicas(context.Background(), &kv_v1.GetAllReplicasRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            s.testDocId(),
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		results, err := readAllReplicas(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		var foundActive bool
+		for _, result := range results {
+			assertValidCas(s.T(), result.Cas)
+			assert.Equal(s.T(), TEST_CONTENT, result.Content)
+			assert.Equal(s.T(), TEST_CONTENT_FLAGS, result.ContentFlags)
+			if !result.IsReplica {
+				foundActive = true
+			}
+		}
+		assert.True(s.T(), foundActive)
+	})
+
+	s.Run("DocMissing", func() {
+		client, err := kvClient.GetAllReplicas(context.Background(), &kv_v1.GetAllReplicasRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            s.missingDocId(),
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = readAllReplicas(client)
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "document")
+		})
+	})
+}
+
+func (s *GatewayOpsTestSuite) TestScan() {
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	readAllItems := func(client kv_v1.KvService_ScanClient) ([]*kv_v1.ScanResponse_Item, error) {
+		var items []*kv_v1.ScanResponse_Item
+		for {
+			resp, err := client.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return items, err
+			}
+
+			items = append(items, resp.Items...)
+		}
+
+		return items, nil
+	}
+
+	prefix := "scan-doc_" + uuid.NewString() + "_"
+	var docIds []string
+	for i := 0; i < 5; i++ {
+		docId := prefix + strconv.Itoa(i)
+		s.createDocument(createDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		})
+		docIds = append(docIds, docId)
+	}
+
+	s.Run("Prefix", func() {
+		client, err := kvClient.Scan(context.Background(), &kv_v1.ScanRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Scan: &kv_v1.ScanRequest_Prefix{
+				Prefix: &kv_v1.ScanRequest_PrefixScan{
+					Prefix: prefix,
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		items, err := readAllItems(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		var foundIds []string
+		for _, item := range items {
+			assertValidCas(s.T(), item.Cas)
+			assert.Equal(s.T(), TEST_CONTENT, item.Content)
+			assert.Equal(s.T(), TEST_CONTENT_FLAGS, item.ContentFlags)
+			foundIds = append(foundIds, item.Key)
+		}
+		assert.ElementsMatch(s.T(), docIds, foundIds)
+	})
+
+	s.Run("IdsOnly", func() {
+		client, err := kvClient.Scan(context.Background(), &kv_v1.ScanRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			IdsOnly:        true,
+			Scan: &kv_v1.ScanRequest_Prefix{
+				Prefix: &kv_v1.ScanRequest_PrefixScan{
+					Prefix: prefix,
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		items, err := readAllItems(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		var foundIds []string
+		for _, item := range items {
+			assert.Empty(s.T(), item.Content)
+			foundIds = append(foundIds, item.Key)
+		}
+		assert.ElementsMatch(s.T(), docIds, foundIds)
+	})
+
+	s.Run("UnboundedRange", func() {
+		// a range without start or end keys covers the whole collection
+		client, err := kvClient.Scan(context.Background(), &kv_v1.ScanRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			IdsOnly:        true,
+			Scan: &kv_v1.ScanRequest_Range{
+				Range: &kv_v1.ScanRequest_RangeScan{},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		items, err := readAllItems(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+
+		var foundIds []string
+		for _, item := range items {
+			foundIds = append(foundIds, item.Key)
+		}
+		assert.Subset(s.T(), foundIds, docIds)
+	})
+
+	s.Run("Sampling", func() {
+		client, err := kvClient.Scan(context.Background(), &kv_v1.ScanRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Scan: &kv_v1.ScanRequest_Sampling{
+				Sampling: &kv_v1.ScanRequest_SamplingScan{
+					Limit: 2,
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		items, err := readAllItems(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+		assert.Len(s.T(), items, 2)
+	})
+
+	s.Run("ZeroSamplingLimit", func() {
+		client, err := kvClient.Scan(context.Background(), &kv_v1.ScanRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Scan: &kv_v1.ScanRequest_Sampling{
+				Sampling: &kv_v1.ScanRequest_SamplingScan{
+					Limit: 0,
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = readAllItems(client)
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("CollectionMissing", func() {
+		client, err := kvClient.Scan(context.Background(), &kv_v1.ScanRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: "invalid-collection",
+			Scan: &kv_v1.ScanRequest_Prefix{
+				Prefix: &kv_v1.ScanRequest_PrefixScan{
+					Prefix: prefix,
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, err = readAllItems(client)
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "collection")
+		})
+	})
+}
+
+func (s *GatewayOpsTestSuite) TestGetMulti() {
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	s.Run("Basic", func() {
+		docId1 := s.testDocId()
+		docId2 := s.testDocId()
+		missingDocId := s.missingDocId()
+
+		resp, err := kvClient.GetMulti(context.Background(), &kv_v1.GetMultiRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Keys:           []string{docId1, missingDocId, docId2},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		require.Len(s.T(), resp.Items, 3)
+
+		for _, itemIdx := range []int{0, 2} {
+			item := resp.Items[itemIdx]
+			assert.Nil(s.T(), item.Status)
+			assertValidCas(s.T(), item.Cas)
+			assert.Equal(s.T(), TEST_CONTENT, item.Content)
+			assert.Equal(s.T(), TEST_CONTENT_FLAGS, item.ContentFlags)
+		}
+		assert.Equal(s.T(), docId1, resp.Items[0].Key)
+		assert.Equal(s.T(), docId2, resp.Items[2].Key)
+
+		missingItem := resp.Items[1]
+		assert.Equal(s.T(), missingDocId, missingItem.Key)
+		require.NotNil(s.T(), missingItem.Status)
+		itemErr := status.ErrorProto(missingItem.Status)
+		assertRpcStatus(s.T(), itemErr, codes.NotFound)
+		assertRpcErrorDetails(s.T(), itemErr, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "document")
+		})
+	})
+
+	s.Run("BucketMissing", func() {
+		resp, err := kvClient.GetMulti(context.Background(), &kv_v1.GetMultiRequest{
+			BucketName:     "invalid-bucket",
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Keys:           []string{s.randomDocId()},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assert.Nil(s.T(), resp)
+	})
+
+	s.Run("TooManyKeys", func() {
+		keys := make([]string, 1001)
+		for keyIdx := range keys {
+			keys[keyIdx] = s.randomDocId()
+		}
+
+		_, err := kvClient.GetMulti(context.Background(), &kv_v1.GetMultiRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Keys:           keys,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+}
+
+func (s *GatewayOpsTestSuite) TestUpsertMulti() {
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	s.Run("Basic", func() {
+		docId1 := s.randomDocId()
+		docId2 := s.randomDocId()
+
+		resp, err := kvClient.UpsertMulti(context.Background(), &kv_v1.UpsertMultiRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Items: []*kv_v1.UpsertMultiRequest_Item{
+				{Key: docId1, Content: TEST_CONTENT, ContentFlags: TEST_CONTENT_FLAGS},
+				{Key: docId2, Content: TEST_CONTENT, ContentFlags: TEST_CONTENT_FLAGS},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		require.Len(s.T(), resp.Items, 2)
+
+		for _, item := range resp.Items {
+			assert.Nil(s.T(), item.Status)
+			assertValidCas(s.T(), item.Cas)
+			assertValidMutationToken(s.T(), item.MutationToken, s.bucketName)
+
+			s.checkDocument(s.T(), checkDocumentOptions{
+				BucketName:     s.bucketName,
+				ScopeName:      s.scopeName,
+				CollectionName: s.collectionName,
+				DocId:          item.Key,
+				Content:        TEST_CONTENT,
+				ContentFlags:   TEST_CONTENT_FLAGS,
+			})
+		}
+	})
+
+	s.Run("NoWriteAccess", func() {
+		resp, err := kvClient.UpsertMulti(context.Background(), &kv_v1.UpsertMultiRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Items: []*kv_v1.UpsertMultiRequest_Item{
+				{Key: s.randomDocId(), Content: TEST_CONTENT, ContentFlags: TEST_CONTENT_FLAGS},
+			},
+		}, grpc.PerRPCCredentials(s.readRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		require.Len(s.T(), resp.Items, 1)
+
+		require.NotNil(s.T(), resp.Items[0].Status)
+		assertRpcStatus(s.T(), status.ErrorProto(resp.Items[0].Status), codes.PermissionDenied)
+	})
+
+	s.Run("TooManyItems", func() {
+		items := make([]*kv_v1.UpsertMultiRequest_Item, 1001)
+		for itemIdx := range items {
+			items[itemIdx] = &kv_v1.UpsertMultiRequest_Item{
+				Key:          s.randomDocId(),
+				Content:      TEST_CONTENT,
+				ContentFlags: TEST_CONTENT_FLAGS,
+			}
+		}
+
+		_, err := kvClient.UpsertMulti(context.Background(), &kv_v1.UpsertMultiRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Items:          items,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+}
+
 func (s *GatewayOpsTestSuite) TestInsert() {
 	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
 
@@ -0,0 +1,449 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/couchbase/goprotostellar/genproto/query_v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const queryMethodName = "/couchbase.query.v1.QueryService/Query"
+
+func (s *GatewayOpsTestSuite) createHooksContext(hooks ...*internal_hooks_v1.Hook) context.Context {
+	hooksClient := internal_hooks_v1.NewHooksServiceClient(s.gatewayConn)
+	hooksContextID := uuid.NewString()
+
+	_, err := hooksClient.CreateHooksContext(context.Background(), &internal_hooks_v1.CreateHooksContextRequest{
+		Id: hooksContextID,
+	})
+	require.NoError(s.T(), err)
+
+	s.T().Cleanup(func() {
+		_, _ = hooksClient.DestroyHooksContext(context.Background(), &internal_hooks_v1.DestroyHooksContextRequest{
+			Id: hooksContextID,
+		})
+	})
+
+	_, err = hooksClient.AddHooks(context.Background(), &internal_hooks_v1.AddHooksRequest{
+		HooksContextId: hooksContextID,
+		Hooks:          hooks,
+	})
+	require.NoError(s.T(), err)
+
+	return metadata.AppendToOutgoingContext(context.Background(), "X-Hooks-ID", hooksContextID)
+}
+
+func (s *GatewayOpsTestSuite) TestHooksStreaming() {
+	queryClient := query_v1.NewQueryServiceClient(s.gatewayConn)
+
+	readQueryRows := func(client query_v1.QueryService_QueryClient) ([][]byte, error) {
+		var rows [][]byte
+		for {
+			resp, err := client.Recv()
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			if err != nil {
+				return rows, err
+			}
+
+			rows = append(rows, resp.Rows...)
+		}
+	}
+
+	returnErrorAction := &internal_hooks_v1.HookAction{
+		Action: &internal_hooks_v1.HookAction_ReturnError_{
+			ReturnError: &internal_hooks_v1.HookAction_ReturnError{
+				Code:    int32(codes.Unavailable),
+				Message: "injected by hooks",
+			},
+		},
+	}
+
+	s.Run("ErrorBeforeFirstMessage", func() {
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "error-before-first",
+			TargetMethod: queryMethodName,
+			Actions:      []*internal_hooks_v1.HookAction{returnErrorAction},
+		})
+
+		client, err := queryClient.Query(ctx, &query_v1.QueryRequest{
+			Statement: "SELECT RAW v FROM ARRAY_RANGE(0, 10) AS v",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, err := readQueryRows(client)
+		assertRpcStatus(s.T(), err, codes.Unavailable)
+		assert.Empty(s.T(), rows)
+	})
+
+	s.Run("ErrorAfterFirstMessage", func() {
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "error-after-first",
+			TargetMethod: queryMethodName,
+			SendActions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_Counter_{
+						Counter: &internal_hooks_v1.HookAction_Counter{
+							CounterId: "sent",
+							Delta:     1,
+						},
+					},
+				},
+				{
+					Action: &internal_hooks_v1.HookAction_If_{
+						If: &internal_hooks_v1.HookAction_If{
+							Cond: []*internal_hooks_v1.HookCondition{
+								{
+									Left: &internal_hooks_v1.ValueRef{
+										Value: &internal_hooks_v1.ValueRef_CounterValue{
+											CounterValue: "sent",
+										},
+									},
+									Op: internal_hooks_v1.ComparisonOperator_COMPARISON_OPERATOR_GREATER_THAN,
+									Right: &internal_hooks_v1.ValueRef{
+										Value: &internal_hooks_v1.ValueRef_JsonValue{
+											JsonValue: []byte("1"),
+										},
+									},
+								},
+							},
+							Match: []*internal_hooks_v1.HookAction{returnErrorAction},
+						},
+					},
+				},
+			},
+		})
+
+		client, err := queryClient.Query(ctx, &query_v1.QueryRequest{
+			Statement: "SELECT RAW v FROM ARRAY_RANGE(0, 10) AS v",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		// the first row is always flushed on its own, so only it gets through
+		rows, err := readQueryRows(client)
+		assertRpcStatus(s.T(), err, codes.Unavailable)
+		assert.Len(s.T(), rows, 1)
+	})
+
+	s.Run("CannedResponses", func() {
+		cannedResp, err := anypb.New(&query_v1.QueryResponse{
+			Rows: [][]byte{[]byte(`"canned"`)},
+		})
+		require.NoError(s.T(), err)
+
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "canned-responses",
+			TargetMethod: queryMethodName,
+			Actions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_ReturnResponse_{
+						ReturnResponse: &internal_hooks_v1.HookAction_ReturnResponse{
+							Value: cannedResp,
+						},
+					},
+				},
+			},
+		})
+
+		client, err := queryClient.Query(ctx, &query_v1.QueryRequest{
+			Statement: "SELECT RAW v FROM ARRAY_RANGE(0, 10) AS v",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, err := readQueryRows(client)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), [][]byte{[]byte(`"canned"`)}, rows)
+	})
+}
+
+func (s *GatewayOpsTestSuite) TestHooksRequestField() {
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	failedDocId := s.testDocId()
+	okDocId := s.testDocId()
+
+	ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+		Name:         "fail-matching-key",
+		TargetMethod: "/couchbase.kv.v1.KvService/Upsert",
+		Actions: []*internal_hooks_v1.HookAction{
+			{
+				Action: &internal_hooks_v1.HookAction_If_{
+					If: &internal_hooks_v1.HookAction_If{
+						Cond: []*internal_hooks_v1.HookCondition{
+							{
+								Left: &internal_hooks_v1.ValueRef{
+									Value: &internal_hooks_v1.ValueRef_RequestField{
+										RequestField: "key",
+									},
+								},
+								Op: internal_hooks_v1.ComparisonOperator_COMPARISON_OPERATOR_EQUAL,
+								Right: &internal_hooks_v1.ValueRef{
+									Value: &internal_hooks_v1.ValueRef_JsonValue{
+										JsonValue: []byte(`"` + failedDocId + `"`),
+									},
+								},
+							},
+						},
+						Match: []*internal_hooks_v1.HookAction{
+							{
+								Action: &internal_hooks_v1.HookAction_ReturnError_{
+									ReturnError: &internal_hooks_v1.HookAction_ReturnError{
+										Code:    int32(codes.Unavailable),
+										Message: "injected by hooks",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	_, err := kvClient.Upsert(ctx, &kv_v1.UpsertRequest{
+		BucketName:     s.bucketName,
+		ScopeName:      s.scopeName,
+		CollectionName: s.collectionName,
+		Key:            failedDocId,
+		Content:        TEST_CONTENT,
+		ContentFlags:   TEST_CONTENT_FLAGS,
+	}, grpc.PerRPCCredentials(s.basicRpcCreds))
+	assertRpcStatus(s.T(), err, codes.Unavailable)
+
+	resp, err := kvClient.Upsert(ctx, &kv_v1.UpsertRequest{
+		BucketName:     s.bucketName,
+		ScopeName:      s.scopeName,
+		CollectionName: s.collectionName,
+		Key:            okDocId,
+		Content:        TEST_CONTENT,
+		ContentFlags:   TEST_CONTENT_FLAGS,
+	}, grpc.PerRPCCredentials(s.basicRpcCreds))
+	requireRpcSuccess(s.T(), resp, err)
+	assertValidCas(s.T(), resp.Cas)
+}
+
+func (s *GatewayOpsTestSuite) TestHooksChaosActions() {
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+	const upsertMethodName = "/couchbase.kv.v1.KvService/Upsert"
+
+	doUpsert := func(ctx context.Context) (*kv_v1.UpsertResponse, error) {
+		return kvClient.Upsert(ctx, &kv_v1.UpsertRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            s.testDocId(),
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+	}
+
+	s.Run("Sleep", func() {
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "sleep",
+			TargetMethod: upsertMethodName,
+			Actions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_Sleep_{
+						Sleep: &internal_hooks_v1.HookAction_Sleep{
+							Duration: durationpb.New(500 * time.Millisecond),
+						},
+					},
+				},
+			},
+		})
+
+		startTime := time.Now()
+		resp, err := doUpsert(ctx)
+		requireRpcSuccess(s.T(), resp, err)
+		assert.GreaterOrEqual(s.T(), time.Since(startTime), 500*time.Millisecond)
+	})
+
+	s.Run("FailWithProbability", func() {
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "always-fail",
+			TargetMethod: upsertMethodName,
+			Actions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_FailWithProbability_{
+						FailWithProbability: &internal_hooks_v1.HookAction_FailWithProbability{
+							Probability: 1,
+							Code:        int32(codes.Unavailable),
+							Message:     "injected by hooks",
+						},
+					},
+				},
+			},
+		})
+
+		_, err := doUpsert(ctx)
+		assertRpcStatus(s.T(), err, codes.Unavailable)
+	})
+
+	s.Run("NeverFailWithProbability", func() {
+		seed := int64(1)
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "never-fail",
+			TargetMethod: upsertMethodName,
+			Actions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_FailWithProbability_{
+						FailWithProbability: &internal_hooks_v1.HookAction_FailWithProbability{
+							Probability: 0,
+							Seed:        &seed,
+							Code:        int32(codes.Unavailable),
+							Message:     "injected by hooks",
+						},
+					},
+				},
+			},
+		})
+
+		resp, err := doUpsert(ctx)
+		requireRpcSuccess(s.T(), resp, err)
+	})
+
+	s.Run("Hang", func() {
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "hang",
+			TargetMethod: upsertMethodName,
+			Actions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_Hang_{
+						Hang: &internal_hooks_v1.HookAction_Hang{},
+					},
+				},
+			},
+		})
+
+		ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+
+		_, err := doUpsert(ctx)
+		assertRpcStatus(s.T(), err, codes.DeadlineExceeded)
+	})
+
+	s.Run("PatchResponse", func() {
+		ctx := s.createHooksContext(&internal_hooks_v1.Hook{
+			Name:         "patch-cas",
+			TargetMethod: upsertMethodName,
+			Actions: []*internal_hooks_v1.HookAction{
+				{
+					Action: &internal_hooks_v1.HookAction_Execute_{
+						Execute: &internal_hooks_v1.HookAction_Execute{},
+					},
+				},
+				{
+					Action: &internal_hooks_v1.HookAction_PatchResponse_{
+						PatchResponse: &internal_hooks_v1.HookAction_PatchResponse{
+							Patches: []*internal_hooks_v1.HookAction_PatchResponse_Patch{
+								{
+									Path:      "cas",
+									JsonValue: []byte(`"12345"`),
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		resp, err := doUpsert(ctx)
+		requireRpcSuccess(s.T(), resp, err)
+		assert.Equal(s.T(), uint64(12345), resp.Cas)
+	})
+}
+
+func (s *GatewayOpsTestSuite) TestHooksContextIntrospection() {
+	hooksClient := internal_hooks_v1.NewHooksServiceClient(s.gatewayConn)
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+	hooksContextID := uuid.NewString()
+
+	_, err := hooksClient.CreateHooksContext(context.Background(), &internal_hooks_v1.CreateHooksContextRequest{
+		Id:          hooksContextID,
+		Ttl:         durationpb.New(5 * time.Minute),
+		RecordCalls: true,
+	})
+	require.NoError(s.T(), err)
+	defer func() {
+		_, _ = hooksClient.DestroyHooksContext(context.Background(), &internal_hooks_v1.DestroyHooksContextRequest{
+			Id: hooksContextID,
+		})
+	}()
+
+	_, err = hooksClient.AddHooks(context.Background(), &internal_hooks_v1.AddHooksRequest{
+		HooksContextId: hooksContextID,
+		Hooks: []*internal_hooks_v1.Hook{
+			{
+				Name:         "count-upserts",
+				TargetMethod: "/couchbase.kv.v1.KvService/Upsert",
+				Actions: []*internal_hooks_v1.HookAction{
+					{
+						Action: &internal_hooks_v1.HookAction_Counter_{
+							Counter: &internal_hooks_v1.HookAction_Counter{
+								CounterId: "upserts",
+								Delta:     1,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(s.T(), err)
+
+	docId := s.testDocId()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "X-Hooks-ID", hooksContextID)
+	upsertResp, err := kvClient.Upsert(ctx, &kv_v1.UpsertRequest{
+		BucketName:     s.bucketName,
+		ScopeName:      s.scopeName,
+		CollectionName: s.collectionName,
+		Key:            docId,
+		Content:        TEST_CONTENT,
+		ContentFlags:   TEST_CONTENT_FLAGS,
+	}, grpc.PerRPCCredentials(s.basicRpcCreds))
+	requireRpcSuccess(s.T(), upsertResp, err)
+
+	s.Run("GetHooksContext", func() {
+		resp, err := hooksClient.GetHooksContext(context.Background(), &internal_hooks_v1.GetHooksContextRequest{
+			Id: hooksContextID,
+		})
+		requireRpcSuccess(s.T(), resp, err)
+
+		require.Len(s.T(), resp.Hooks, 1)
+		assert.Equal(s.T(), "count-upserts", resp.Hooks[0].Name)
+
+		require.Len(s.T(), resp.Counters, 1)
+		assert.Equal(s.T(), "upserts", resp.Counters[0].Id)
+		assert.Equal(s.T(), int64(1), resp.Counters[0].Value)
+
+		require.Len(s.T(), resp.Calls, 1)
+		call := resp.Calls[0]
+		assert.Equal(s.T(), "/couchbase.kv.v1.KvService/Upsert", call.Method)
+		assert.Equal(s.T(), []string{"counter"}, call.Actions)
+		assert.Nil(s.T(), call.Status)
+
+		var req kv_v1.UpsertRequest
+		require.NoError(s.T(), call.Request.UnmarshalTo(&req))
+		assert.Equal(s.T(), docId, req.Key)
+	})
+
+	s.Run("GetHooksContextMissing", func() {
+		_, err := hooksClient.GetHooksContext(context.Background(), &internal_hooks_v1.GetHooksContextRequest{
+			Id: "invalid-hooks-context",
+		})
+		assertRpcStatus(s.T(), err, codes.NotFound)
+	})
+}
@@ -2,11 +2,16 @@ package test
 
 import (
 	"context"
+	"time"
 
 	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func (s *GatewayOpsTestSuite) TestQueryManagement() {
@@ -240,6 +245,38 @@ func (s *GatewayOpsTestSuite) TestQueryManagement() {
 			require.NotEqual(s.T(), admin_query_v1.IndexState_INDEX_STATE_DEFERRED, foundIdx.State)
 		})
 
+		s.Run("WaitOnline", func() {
+			resp, err := queryAdminClient.WaitForIndexesOnline(context.Background(), &admin_query_v1.WaitForIndexesOnlineRequest{
+				BucketName:     s.bucketName,
+				ScopeName:      &s.scopeName,
+				CollectionName: &s.collectionName,
+				IndexNames:     []string{indexName},
+				Timeout:        durationpb.New(30 * time.Second),
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			requireRpcSuccess(s.T(), resp, err)
+		})
+
+		s.Run("Watch", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client, err := queryAdminClient.WatchIndexes(ctx, &admin_query_v1.WatchIndexesRequest{
+				BucketName:     s.bucketName,
+				ScopeName:      &s.scopeName,
+				CollectionName: &s.collectionName,
+				IndexNames:     []string{indexName},
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			requireRpcSuccess(s.T(), client, err)
+
+			resp, err := client.Recv()
+			requireRpcSuccess(s.T(), resp, err)
+			require.Len(s.T(), resp.Changes, 1)
+			assert.Equal(s.T(), indexName, resp.Changes[0].Name)
+			assert.Equal(s.T(), s.scopeName, resp.Changes[0].ScopeName)
+			assert.Equal(s.T(), s.collectionName, resp.Changes[0].CollectionName)
+			assert.Equal(s.T(), admin_query_v1.IndexState_INDEX_STATE_ONLINE, resp.Changes[0].State)
+		})
+
 		s.Run("Drop", func() {
 			resp, err := queryAdminClient.DropIndex(context.Background(), &admin_query_v1.DropIndexRequest{
 				Name:           indexName,
@@ -250,4 +287,16 @@ func (s *GatewayOpsTestSuite) TestQueryManagement() {
 			requireRpcSuccess(s.T(), resp, err)
 		})
 	})
+	s.Run("WaitOnlineIndexMissing", func() {
+		_, err := queryAdminClient.WaitForIndexesOnline(context.Background(), &admin_query_v1.WaitForIndexesOnlineRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      &s.scopeName,
+			CollectionName: &s.collectionName,
+			IndexNames:     []string{"missing-index"},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "queryindex")
+		})
+	})
 }
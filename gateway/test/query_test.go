@@ -2,12 +2,17 @@ package test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"time"
 
+	"github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/query_v1"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -65,6 +70,108 @@ func (s *GatewayOpsTestSuite) TestQuery() {
 		}, 30*time.Second, 1*time.Second)
 	})
 
+	s.Run("Prepared", func() {
+		docId := s.testDocId()
+		bucketName := "default"
+		prepared := true
+
+		// the second iteration reuses the statement prepared by the first
+		for i := 0; i < 2; i++ {
+			assert.Eventually(s.T(), func() bool {
+				client, err := queryClient.Query(context.Background(), &query_v1.QueryRequest{
+					BucketName: &bucketName,
+					Statement:  "SELECT * FROM default._default._default WHERE META().id=$1",
+					PositionalParameters: [][]byte{
+						[]byte("\"" + docId + "\""),
+					},
+					Prepared: &prepared,
+				}, grpc.PerRPCCredentials(s.basicRpcCreds))
+				requireRpcSuccess(s.T(), client, err)
+
+				rows, md, err := readQueryStream(client)
+				assertRpcStatus(s.T(), err, codes.OK)
+
+				if len(rows) != 1 {
+					return false
+				}
+
+				assert.NotNil(s.T(), md)
+				return true
+			}, 30*time.Second, 1*time.Second)
+		}
+	})
+
+	s.Run("ConsistentWith", func() {
+		kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+		docId := s.randomDocId()
+
+		upsertResp, err := kvClient.Upsert(context.Background(), &kv_v1.UpsertRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		client, err := queryClient.Query(context.Background(), &query_v1.QueryRequest{
+			BucketName:     &s.bucketName,
+			Statement:      "SELECT * FROM default._default._default WHERE META().id='" + docId + "'",
+			ConsistentWith: []*kv_v1.MutationToken{upsertResp.MutationToken},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, md, err := readQueryStream(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+		assert.Len(s.T(), rows, 1)
+		assert.NotNil(s.T(), md)
+	})
+
+	s.Run("ConsistentWithNoBucket", func() {
+		kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+		docId := s.randomDocId()
+
+		upsertResp, err := kvClient.Upsert(context.Background(), &kv_v1.UpsertRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		// cluster-level queries take the bucket from the mutation tokens themselves
+		client, err := queryClient.Query(context.Background(), &query_v1.QueryRequest{
+			Statement:      "SELECT * FROM default._default._default WHERE META().id='" + docId + "'",
+			ConsistentWith: []*kv_v1.MutationToken{upsertResp.MutationToken},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		rows, md, err := readQueryStream(client)
+		assertRpcStatus(s.T(), err, codes.OK)
+		assert.Len(s.T(), rows, 1)
+		assert.NotNil(s.T(), md)
+	})
+
+	s.Run("ConsistentWithWrongBucket", func() {
+		client, err := queryClient.Query(context.Background(), &query_v1.QueryRequest{
+			BucketName: &s.bucketName,
+			Statement:  "SELECT * FROM default._default._default",
+			ConsistentWith: []*kv_v1.MutationToken{{
+				BucketName:  "some-other-bucket",
+				VbucketId:   1,
+				VbucketUuid: 1,
+				SeqNo:       1,
+			}},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readQueryStream(client)
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
 	s.Run("InvalidQueryStatement", func() {
 		client, err := queryClient.Query(context.Background(), &query_v1.QueryRequest{
 			Statement: "FINAGLE * FROM default._default._default",
@@ -97,4 +204,99 @@ func (s *GatewayOpsTestSuite) TestQuery() {
 		_, _, err = readQueryStream(client)
 		assertRpcStatus(s.T(), err, codes.Unauthenticated)
 	})
+
+	s.Run("Explain", func() {
+		resp, err := queryClient.Explain(context.Background(), &query_v1.ExplainRequest{
+			Statement: "SELECT * FROM default._default._default WHERE META().id='" + s.testDocId() + "'",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+
+		var plan map[string]interface{}
+		require.NoError(s.T(), json.Unmarshal(resp.Plan, &plan))
+		assert.Contains(s.T(), plan, "#operator")
+	})
+
+	s.Run("ExplainScopeWithoutBucket", func() {
+		scopeName := "_default"
+		_, err := queryClient.Explain(context.Background(), &query_v1.ExplainRequest{
+			ScopeName: &scopeName,
+			Statement: "SELECT * FROM _default",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("ExplainInvalidStatement", func() {
+		_, err := queryClient.Explain(context.Background(), &query_v1.ExplainRequest{
+			Statement: "FINAGLE * FROM default._default._default",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("AdviseIndexes", func() {
+		bucketName := s.bucketName
+		scopeName := s.scopeName
+		resp, err := queryClient.AdviseIndexes(context.Background(), &query_v1.AdviseIndexesRequest{
+			BucketName: &bucketName,
+			ScopeName:  &scopeName,
+			Statement:  "SELECT * FROM `" + s.collectionName + "` WHERE advisedField = 1",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		assert.NotEmpty(s.T(), resp.Advice)
+
+		require.NotEmpty(s.T(), resp.Indexes)
+		idx := resp.Indexes[0]
+		assert.NotEmpty(s.T(), idx.IndexStatement)
+		assert.NotEmpty(s.T(), idx.Name)
+		assert.Equal(s.T(), s.bucketName, idx.BucketName)
+		assert.Equal(s.T(), s.scopeName, idx.GetScopeName())
+		assert.Equal(s.T(), s.collectionName, idx.GetCollectionName())
+		assert.Contains(s.T(), idx.Fields, "`advisedField`")
+	})
+
+	s.Run("AdviseIndexesCreate", func() {
+		queryAdminClient := admin_query_v1.NewQueryAdminServiceClient(s.gatewayConn)
+
+		bucketName := s.bucketName
+		scopeName := s.scopeName
+		resp, err := queryClient.AdviseIndexes(context.Background(), &query_v1.AdviseIndexesRequest{
+			BucketName: &bucketName,
+			ScopeName:  &scopeName,
+			Statement:  "SELECT * FROM `" + s.collectionName + "` WHERE advisedCond = 1 AND advisedObj.child > 2",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		require.NotEmpty(s.T(), resp.Indexes)
+
+		// advised indexes should be usable with CreateIndex as-is.
+		idx := resp.Indexes[0]
+		indexName := "advised-" + uuid.NewString()[:6]
+		trueBool := true
+		createResp, err := queryAdminClient.CreateIndex(context.Background(), &admin_query_v1.CreateIndexRequest{
+			Name:           indexName,
+			BucketName:     idx.BucketName,
+			ScopeName:      idx.ScopeName,
+			CollectionName: idx.CollectionName,
+			Fields:         idx.Fields,
+			RawFields:      &trueBool,
+			Condition:      idx.Condition,
+			Deferred:       &trueBool,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), createResp, err)
+
+		dropResp, err := queryAdminClient.DropIndex(context.Background(), &admin_query_v1.DropIndexRequest{
+			Name:           indexName,
+			BucketName:     idx.BucketName,
+			ScopeName:      idx.ScopeName,
+			CollectionName: idx.CollectionName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), dropResp, err)
+	})
+
+	s.Run("AdviseIndexesScopeWithoutBucket", func() {
+		scopeName := "_default"
+		_, err := queryClient.AdviseIndexes(context.Background(), &query_v1.AdviseIndexesRequest{
+			ScopeName: &scopeName,
+			Statement: "SELECT * FROM _default",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
 }
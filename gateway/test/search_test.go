@@ -9,10 +9,13 @@ import (
 	"time"
 
 	"github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/goprotostellar/genproto/search_v1"
 
 	"github.com/google/uuid"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 func (s *GatewayOpsTestSuite) TestSearchBasic() {
@@ -24,6 +27,12 @@ func (s *GatewayOpsTestSuite) TestSearchBasic() {
 
 		s.Run("Test", helper.testSearchBasic)
 
+		s.Run("ConsistentWith", helper.testSearchConsistentWith)
+
+		s.Run("Knn", helper.testSearchKnn)
+
+		s.Run("IndexAdmin", helper.testSearchIndexAdmin)
+
 		s.Run("Cleanup", helper.testCleanupSearch)
 	}
 }
@@ -192,6 +201,290 @@ func (s *testSearchServiceHelper) testSearchBasic() {
 	}
 }
 
+func (s *testSearchServiceHelper) testSearchConsistentWith() {
+	client := search_v1.NewSearchServiceClient(s.gatewayConn)
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	s.Run("AtPlus", func() {
+		term := "atplus" + uuid.NewString()[:8]
+		upsertResp, err := kvClient.Upsert(context.Background(), &kv_v1.UpsertRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            s.randomDocId(),
+			Content:        []byte(`{"service":"` + term + `"}`),
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		// with at_plus consistency the document must be visible on the first attempt
+		field := "service"
+		queryResult, err := client.SearchQuery(context.Background(), &search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Query: &search_v1.Query{
+				Query: &search_v1.Query_TermQuery{
+					TermQuery: &search_v1.TermQuery{
+						Term:  term,
+						Field: &field,
+					},
+				},
+			},
+			ConsistentWith: []*kv_v1.MutationToken{upsertResp.MutationToken},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), queryResult, err)
+
+		result, err := queryResult.Recv()
+		requireRpcSuccess(s.T(), result, err)
+		s.Len(result.Hits, 1)
+	})
+
+	s.Run("MixedBuckets", func() {
+		queryResult, err := client.SearchQuery(context.Background(), &search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Query: &search_v1.Query{
+				Query: &search_v1.Query_MatchAllQuery{
+					MatchAllQuery: &search_v1.MatchAllQuery{},
+				},
+			},
+			ConsistentWith: []*kv_v1.MutationToken{{
+				BucketName:  s.bucketName,
+				VbucketId:   1,
+				VbucketUuid: 1,
+				SeqNo:       1,
+			}, {
+				BucketName:  "some-other-bucket",
+				VbucketId:   1,
+				VbucketUuid: 1,
+				SeqNo:       1,
+			}},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), queryResult, err)
+
+		_, err = queryResult.Recv()
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+}
+
+func (s *testSearchServiceHelper) testSearchIndexAdmin() {
+	s.Run("GetIndex", func() {
+		resp, err := s.IndexClient.GetIndex(context.Background(), &admin_search_v1.GetIndexRequest{
+			Name: s.IndexName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		s.Equal(s.IndexName, resp.Index.Name)
+		s.Equal("fulltext-index", resp.Index.Type)
+	})
+
+	s.Run("GetIndexMissing", func() {
+		_, err := s.IndexClient.GetIndex(context.Background(), &admin_search_v1.GetIndexRequest{
+			Name: "missing-index",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			s.Equal("searchindex", d.ResourceType)
+		})
+	})
+
+	s.Run("ListIndexes", func() {
+		resp, err := s.IndexClient.ListIndexes(context.Background(), &admin_search_v1.ListIndexesRequest{},
+			grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+
+		var found bool
+		for _, index := range resp.Indexes {
+			if index.Name == s.IndexName {
+				found = true
+			}
+		}
+		s.True(found, "created index was not listed")
+	})
+
+	s.Run("GetIndexedDocumentsCount", func() {
+		resp, err := s.IndexClient.GetIndexedDocumentsCount(context.Background(), &admin_search_v1.GetIndexedDocumentsCountRequest{
+			Name: s.IndexName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+	})
+
+	s.Run("PauseResumeIngest", func() {
+		pauseResp, err := s.IndexClient.PauseIndexIngest(context.Background(), &admin_search_v1.PauseIndexIngestRequest{
+			Name: s.IndexName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), pauseResp, err)
+
+		resumeResp, err := s.IndexClient.ResumeIndexIngest(context.Background(), &admin_search_v1.ResumeIndexIngestRequest{
+			Name: s.IndexName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resumeResp, err)
+	})
+
+	s.Run("ScopeWithoutBucket", func() {
+		scopeName := s.scopeName
+		_, err := s.IndexClient.GetIndex(context.Background(), &admin_search_v1.GetIndexRequest{
+			Name:      s.IndexName,
+			ScopeName: &scopeName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+}
+
+func (s *testSearchServiceHelper) testSearchKnn() {
+	client := search_v1.NewSearchServiceClient(s.gatewayConn)
+
+	readKnnError := func(req *search_v1.SearchQueryRequest) error {
+		queryResult, err := client.SearchQuery(context.Background(), req, grpc.PerRPCCredentials(s.basicRpcCreds))
+		if err != nil {
+			return err
+		}
+
+		_, err = queryResult.Recv()
+		return err
+	}
+
+	s.Run("Basic", func() {
+		indexName := "vector" + uuid.NewString()[:6]
+		sourceType := "couchbase"
+		upsertResp, err := s.IndexClient.UpsertIndex(context.Background(), &admin_search_v1.UpsertIndexRequest{
+			Name:       indexName,
+			Type:       "fulltext-index",
+			SourceType: &sourceType,
+			SourceName: &s.bucketName,
+			Params: map[string][]byte{
+				"mapping": []byte(`{"default_mapping":{"enabled":true,"dynamic":false,"properties":{"embedding":{"enabled":true,"fields":[{"name":"embedding","type":"vector","dims":3,"index":true}]}}}}`),
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		defer func() {
+			_, _ = s.IndexClient.DeleteIndex(context.Background(), &admin_search_v1.DeleteIndexRequest{
+				Name: indexName,
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		}()
+
+		embeddings := [][]float32{
+			{1, 0, 0},
+			{0, 1, 0},
+			{0, 0, 1},
+		}
+		docIds := make([]string, len(embeddings))
+		for embeddingIdx, embedding := range embeddings {
+			docIds[embeddingIdx] = s.randomDocId()
+
+			content, err := json.Marshal(map[string]interface{}{
+				"embedding": embedding,
+			})
+			s.Require().NoError(err)
+
+			s.createDocument(createDocumentOptions{
+				BucketName:     s.bucketName,
+				ScopeName:      s.scopeName,
+				CollectionName: s.collectionName,
+				DocId:          docIds[embeddingIdx],
+				Content:        content,
+				ContentFlags:   TEST_CONTENT_FLAGS,
+			})
+		}
+
+		s.Require().Eventually(func() bool {
+			queryResult, err := client.SearchQuery(context.Background(), &search_v1.SearchQueryRequest{
+				IndexName: indexName,
+				Knn: []*search_v1.KnnQuery{
+					{Field: "embedding", Vector: []float32{0, 0.9, 0.1}, K: 1},
+				},
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			if err != nil {
+				s.T().Logf("Failed to query index: %s", err)
+				return false
+			}
+
+			result, err := queryResult.Recv()
+			if err != nil {
+				s.T().Logf("Failed to read row: %s", err)
+				return false
+			}
+
+			if len(result.Hits) != 1 {
+				s.T().Logf("Incorrect number of rows, expected: 1, was %d", len(result.Hits))
+				return false
+			}
+
+			s.Equal(docIds[1], result.Hits[0].Id)
+			return true
+		}, 60*time.Second, 500*time.Millisecond)
+	})
+
+	s.Run("AndWithoutQuery", func() {
+		knnOperator := search_v1.SearchQueryRequest_KNN_OPERATOR_AND
+		err := readKnnError(&search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Knn: []*search_v1.KnnQuery{
+				{Field: "embedding", Vector: []float32{0.1, 0.2, 0.3}, K: 3},
+			},
+			KnnOperator: &knnOperator,
+		})
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("ZeroK", func() {
+		err := readKnnError(&search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Knn: []*search_v1.KnnQuery{
+				{Field: "embedding", Vector: []float32{0.1, 0.2, 0.3}, K: 0},
+			},
+		})
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("MissingVector", func() {
+		err := readKnnError(&search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Knn: []*search_v1.KnnQuery{
+				{Field: "embedding", K: 3},
+			},
+		})
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("OperatorWithoutKnn", func() {
+		knnOperator := search_v1.SearchQueryRequest_KNN_OPERATOR_AND
+		err := readKnnError(&search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Query: &search_v1.Query{
+				Query: &search_v1.Query_MatchAllQuery{
+					MatchAllQuery: &search_v1.MatchAllQuery{},
+				},
+			},
+			KnnOperator: &knnOperator,
+		})
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("ScoringDisabled", func() {
+		err := readKnnError(&search_v1.SearchQueryRequest{
+			IndexName: s.IndexName,
+			Knn: []*search_v1.KnnQuery{
+				{Field: "embedding", Vector: []float32{0.1, 0.2, 0.3}, K: 3},
+			},
+			DisableScoring: true,
+		})
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+
+	s.Run("InvalidVectorFieldMapping", func() {
+		sourceType := "couchbase"
+		_, err := s.IndexClient.UpsertIndex(context.Background(), &admin_search_v1.UpsertIndexRequest{
+			Name:       "vector" + uuid.NewString()[:6],
+			Type:       "fulltext-index",
+			SourceType: &sourceType,
+			SourceName: &s.bucketName,
+			Params: map[string][]byte{
+				"mapping": []byte(`{"default_mapping":{"enabled":true,"properties":{"embedding":{"enabled":true,"fields":[{"name":"embedding","type":"vector","dims":0,"similarity":"l2_norm"}]}}}}`),
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+	})
+}
+
 func (s *testSearchServiceHelper) testCleanupSearch() {
 	if s.IndexName != "" {
 		_, err := s.IndexClient.DeleteIndex(context.Background(), &admin_search_v1.DeleteIndexRequest{
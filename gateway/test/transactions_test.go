@@ -0,0 +1,212 @@
+package test
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	"github.com/stretchr/testify/assert"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestTransactions() {
+	txnClient := transactions_v1.NewTransactionsServiceClient(s.gatewayConn)
+	kvClient := kv_v1.NewKvServiceClient(s.gatewayConn)
+
+	beginAttempt := func() *transactions_v1.TransactionBeginAttemptResponse {
+		resp, err := txnClient.TransactionBeginAttempt(context.Background(), &transactions_v1.TransactionBeginAttemptRequest{
+			BucketName: s.bucketName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		assert.NotEmpty(s.T(), resp.TransactionId)
+		assert.NotEmpty(s.T(), resp.AttemptId)
+		return resp
+	}
+
+	s.Run("ReplaceCommit", func() {
+		docId := s.testDocId()
+		txn := beginAttempt()
+
+		getResp, err := txnClient.TransactionGet(context.Background(), &transactions_v1.TransactionGetRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txn.TransactionId,
+			AttemptId:      txn.AttemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+		assert.Equal(s.T(), TEST_CONTENT, getResp.Value)
+
+		newContent := []byte(`{"boo": "baz"}`)
+		repResp, err := txnClient.TransactionReplace(context.Background(), &transactions_v1.TransactionReplaceRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txn.TransactionId,
+			AttemptId:      txn.AttemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Value:          newContent,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), repResp, err)
+
+		// the replace should not be visible outside the transaction until commit
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		})
+
+		commitResp, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txn.TransactionId,
+			AttemptId:     txn.AttemptId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), commitResp, err)
+
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        newContent,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		})
+	})
+
+	s.Run("InsertCommit", func() {
+		docId := s.randomDocId()
+		txn := beginAttempt()
+
+		insResp, err := txnClient.TransactionInsert(context.Background(), &transactions_v1.TransactionInsertRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txn.TransactionId,
+			AttemptId:      txn.AttemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Value:          TEST_CONTENT,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), insResp, err)
+
+		commitResp, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txn.TransactionId,
+			AttemptId:     txn.AttemptId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), commitResp, err)
+
+		// the tombstone holding the staged insert should have been revived
+		getResp, err := kvClient.Get(context.Background(), &kv_v1.GetRequest{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+		assert.Equal(s.T(), TEST_CONTENT, getResp.Content)
+	})
+
+	s.Run("InsertRollback", func() {
+		docId := s.randomDocId()
+		txn := beginAttempt()
+
+		insResp, err := txnClient.TransactionInsert(context.Background(), &transactions_v1.TransactionInsertRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txn.TransactionId,
+			AttemptId:      txn.AttemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+			Value:          TEST_CONTENT,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), insResp, err)
+
+		rbResp, err := txnClient.TransactionRollback(context.Background(), &transactions_v1.TransactionRollbackRequest{
+			BucketName:    s.bucketName,
+			TransactionId: txn.TransactionId,
+			AttemptId:     txn.AttemptId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), rbResp, err)
+
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        nil,
+		})
+	})
+
+	s.Run("WriteWriteConflict", func() {
+		docId := s.testDocId()
+		txn1 := beginAttempt()
+		txn2 := beginAttempt()
+
+		rem1Resp, err := txnClient.TransactionRemove(context.Background(), &transactions_v1.TransactionRemoveRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txn1.TransactionId,
+			AttemptId:      txn1.AttemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), rem1Resp, err)
+
+		_, err = txnClient.TransactionRemove(context.Background(), &transactions_v1.TransactionRemoveRequest{
+			BucketName:     s.bucketName,
+			TransactionId:  txn2.TransactionId,
+			AttemptId:      txn2.AttemptId,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			Key:            docId,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.Aborted)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "document")
+		})
+
+		// release the staged remove so the document is usable by later tests
+		for _, txn := range []*transactions_v1.TransactionBeginAttemptResponse{txn1, txn2} {
+			rbResp, err := txnClient.TransactionRollback(context.Background(), &transactions_v1.TransactionRollbackRequest{
+				BucketName:    s.bucketName,
+				TransactionId: txn.TransactionId,
+				AttemptId:     txn.AttemptId,
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			requireRpcSuccess(s.T(), rbResp, err)
+		}
+
+		s.checkDocument(s.T(), checkDocumentOptions{
+			BucketName:     s.bucketName,
+			ScopeName:      s.scopeName,
+			CollectionName: s.collectionName,
+			DocId:          docId,
+			Content:        TEST_CONTENT,
+			ContentFlags:   TEST_CONTENT_FLAGS,
+		})
+	})
+
+	s.Run("AttemptMissing", func() {
+		_, err := txnClient.TransactionCommit(context.Background(), &transactions_v1.TransactionCommitRequest{
+			BucketName:    s.bucketName,
+			TransactionId: "missing-txn",
+			AttemptId:     "missing-attempt",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "transaction_attempt")
+		})
+	})
+
+	s.Run("Unauthenticated", func() {
+		_, err := txnClient.TransactionBeginAttempt(context.Background(), &transactions_v1.TransactionBeginAttemptRequest{
+			BucketName: s.bucketName,
+		})
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"context"
+
+	"github.com/couchbase/goprotostellar/genproto/admin_user_v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestUserManagement() {
+	userAdminClient := admin_user_v1.NewUserAdminServiceClient(s.gatewayConn)
+
+	s.Run("User", func() {
+		username := "test-user-" + uuid.NewString()[:6]
+		password := "password"
+
+		upsertResp, err := userAdminClient.UpsertUser(context.Background(), &admin_user_v1.UpsertUserRequest{
+			User: &admin_user_v1.User{
+				Username:    username,
+				DisplayName: "Test User",
+				Domain:      admin_user_v1.AuthDomain_AUTH_DOMAIN_LOCAL,
+				Roles: []*admin_user_v1.Role{
+					{Name: "data_reader", BucketName: &s.bucketName},
+				},
+			},
+			Password: &password,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		getResp, err := userAdminClient.GetUser(context.Background(), &admin_user_v1.GetUserRequest{
+			Username: username,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+		assert.Equal(s.T(), username, getResp.User.Username)
+		assert.Equal(s.T(), "Test User", getResp.User.DisplayName)
+		if assert.Len(s.T(), getResp.User.Roles, 1) {
+			assert.Equal(s.T(), "data_reader", getResp.User.Roles[0].Name)
+			assert.Equal(s.T(), s.bucketName, getResp.User.Roles[0].GetBucketName())
+		}
+
+		listResp, err := userAdminClient.ListUsers(context.Background(), &admin_user_v1.ListUsersRequest{},
+			grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), listResp, err)
+		var found bool
+		for _, user := range listResp.Users {
+			if user.Username == username {
+				found = true
+			}
+		}
+		assert.True(s.T(), found)
+
+		deleteResp, err := userAdminClient.DeleteUser(context.Background(), &admin_user_v1.DeleteUserRequest{
+			Username: username,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), deleteResp, err)
+	})
+
+	s.Run("UserMissing", func() {
+		_, err := userAdminClient.GetUser(context.Background(), &admin_user_v1.GetUserRequest{
+			Username: "missing-user-" + uuid.NewString()[:6],
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "user")
+		})
+	})
+
+	s.Run("InvalidUser", func() {
+		username := "test-user-" + uuid.NewString()[:6]
+		password := "password"
+
+		_, err := userAdminClient.UpsertUser(context.Background(), &admin_user_v1.UpsertUserRequest{
+			User: &admin_user_v1.User{
+				Username: username,
+				Domain:   admin_user_v1.AuthDomain_AUTH_DOMAIN_LOCAL,
+				Roles: []*admin_user_v1.Role{
+					{Name: "not_a_real_role"},
+				},
+			},
+			Password: &password,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.InvalidArgument)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "user")
+			assert.Equal(s.T(), d.ResourceName, username)
+		})
+	})
+
+	s.Run("Group", func() {
+		groupName := "test-group-" + uuid.NewString()[:6]
+
+		upsertResp, err := userAdminClient.UpsertGroup(context.Background(), &admin_user_v1.UpsertGroupRequest{
+			Group: &admin_user_v1.Group{
+				Name:        groupName,
+				Description: "Test Group",
+				Roles: []*admin_user_v1.Role{
+					{Name: "data_reader", BucketName: &s.bucketName},
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		getResp, err := userAdminClient.GetGroup(context.Background(), &admin_user_v1.GetGroupRequest{
+			Name: groupName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+		assert.Equal(s.T(), groupName, getResp.Group.Name)
+		assert.Equal(s.T(), "Test Group", getResp.Group.Description)
+
+		deleteResp, err := userAdminClient.DeleteGroup(context.Background(), &admin_user_v1.DeleteGroupRequest{
+			Name: groupName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), deleteResp, err)
+
+		_, err = userAdminClient.GetGroup(context.Background(), &admin_user_v1.GetGroupRequest{
+			Name: groupName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "group")
+		})
+	})
+
+	s.Run("ListRoles", func() {
+		resp, err := userAdminClient.ListRoles(context.Background(), &admin_user_v1.ListRolesRequest{},
+			grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+		assert.NotEmpty(s.T(), resp.Roles)
+	})
+}
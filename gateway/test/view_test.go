@@ -0,0 +1,266 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/couchbase/goprotostellar/genproto/admin_view_v1"
+	"github.com/couchbase/goprotostellar/genproto/view_v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	epb "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *GatewayOpsTestSuite) TestViewManagement() {
+	viewAdminClient := admin_view_v1.NewViewAdminServiceClient(s.gatewayConn)
+
+	devNamespace := admin_view_v1.DesignDocumentNamespace_DESIGN_DOCUMENT_NAMESPACE_DEVELOPMENT
+	prodNamespace := admin_view_v1.DesignDocumentNamespace_DESIGN_DOCUMENT_NAMESPACE_PRODUCTION
+
+	findDesignDocument := func(ns admin_view_v1.DesignDocumentNamespace, ddocName string) *admin_view_v1.DesignDocument {
+		resp, err := viewAdminClient.ListDesignDocuments(context.Background(), &admin_view_v1.ListDesignDocumentsRequest{
+			BucketName: s.bucketName,
+			Namespace:  ns,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), resp, err)
+
+		for _, ddoc := range resp.DesignDocuments {
+			if ddoc.Name == ddocName {
+				return ddoc
+			}
+		}
+		return nil
+	}
+
+	s.Run("UpsertGetPublishDelete", func() {
+		ddocName := "test-ddoc-" + uuid.NewString()[:6]
+		mapFn := "function (doc, meta) { emit(meta.id, null); }"
+
+		upsertResp, err := viewAdminClient.UpsertDesignDocument(context.Background(), &admin_view_v1.UpsertDesignDocumentRequest{
+			BucketName: s.bucketName,
+			DesignDocument: &admin_view_v1.DesignDocument{
+				Name:      ddocName,
+				Namespace: devNamespace,
+				Views: map[string]*admin_view_v1.DesignDocument_View{
+					"test": {Map: mapFn},
+				},
+			},
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), upsertResp, err)
+
+		getResp, err := viewAdminClient.GetDesignDocument(context.Background(), &admin_view_v1.GetDesignDocumentRequest{
+			BucketName: s.bucketName,
+			Name:       ddocName,
+			Namespace:  devNamespace,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), getResp, err)
+		require.NotNil(s.T(), getResp.DesignDocument)
+		assert.Equal(s.T(), ddocName, getResp.DesignDocument.Name)
+		require.Contains(s.T(), getResp.DesignDocument.Views, "test")
+		assert.Equal(s.T(), mapFn, getResp.DesignDocument.Views["test"].Map)
+		assert.Nil(s.T(), getResp.DesignDocument.Views["test"].Reduce)
+
+		assert.NotNil(s.T(), findDesignDocument(devNamespace, ddocName))
+		assert.Nil(s.T(), findDesignDocument(prodNamespace, ddocName))
+
+		publishResp, err := viewAdminClient.PublishDesignDocument(context.Background(), &admin_view_v1.PublishDesignDocumentRequest{
+			BucketName: s.bucketName,
+			Name:       ddocName,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), publishResp, err)
+
+		prodDdoc := findDesignDocument(prodNamespace, ddocName)
+		require.NotNil(s.T(), prodDdoc)
+		require.Contains(s.T(), prodDdoc.Views, "test")
+		assert.Equal(s.T(), mapFn, prodDdoc.Views["test"].Map)
+
+		for _, ns := range []admin_view_v1.DesignDocumentNamespace{devNamespace, prodNamespace} {
+			deleteResp, err := viewAdminClient.DeleteDesignDocument(context.Background(), &admin_view_v1.DeleteDesignDocumentRequest{
+				BucketName: s.bucketName,
+				Name:       ddocName,
+				Namespace:  ns,
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			requireRpcSuccess(s.T(), deleteResp, err)
+
+			assert.Nil(s.T(), findDesignDocument(ns, ddocName))
+		}
+	})
+
+	s.Run("GetMissing", func() {
+		_, err := viewAdminClient.GetDesignDocument(context.Background(), &admin_view_v1.GetDesignDocumentRequest{
+			BucketName: s.bucketName,
+			Name:       "missing-ddoc",
+			Namespace:  devNamespace,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "designdocument")
+		})
+	})
+
+	s.Run("DeleteMissing", func() {
+		_, err := viewAdminClient.DeleteDesignDocument(context.Background(), &admin_view_v1.DeleteDesignDocumentRequest{
+			BucketName: s.bucketName,
+			Name:       "missing-ddoc",
+			Namespace:  devNamespace,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "designdocument")
+		})
+	})
+
+	s.Run("PublishMissing", func() {
+		_, err := viewAdminClient.PublishDesignDocument(context.Background(), &admin_view_v1.PublishDesignDocumentRequest{
+			BucketName: s.bucketName,
+			Name:       "missing-ddoc",
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "designdocument")
+		})
+	})
+
+	s.Run("Unauthenticated", func() {
+		_, err := viewAdminClient.ListDesignDocuments(context.Background(), &admin_view_v1.ListDesignDocumentsRequest{
+			BucketName: s.bucketName,
+			Namespace:  devNamespace,
+		})
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+}
+
+func (s *GatewayOpsTestSuite) TestViewQuery() {
+	viewAdminClient := admin_view_v1.NewViewAdminServiceClient(s.gatewayConn)
+	viewClient := view_v1.NewViewServiceClient(s.gatewayConn)
+
+	readViewStream := func(client view_v1.ViewService_ViewQueryClient) ([]*view_v1.ViewQueryResponse_Row, *view_v1.ViewQueryResponse_MetaData, error) {
+		var rows []*view_v1.ViewQueryResponse_Row
+		var md *view_v1.ViewQueryResponse_MetaData
+
+		for {
+			resp, err := client.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return rows, md, err
+			}
+
+			rows = append(rows, resp.Rows...)
+			if resp.MetaData != nil {
+				md = resp.MetaData
+			}
+		}
+
+		return rows, md, nil
+	}
+
+	// views only index the default collection, so we write our document there.
+	docId := s.randomDocId()
+	s.createDocument(createDocumentOptions{
+		BucketName:     s.bucketName,
+		ScopeName:      "_default",
+		CollectionName: "_default",
+		DocId:          docId,
+		Content:        TEST_CONTENT,
+		ContentFlags:   TEST_CONTENT_FLAGS,
+	})
+
+	ddocName := "test-ddoc-" + uuid.NewString()[:6]
+	devNamespace := admin_view_v1.DesignDocumentNamespace_DESIGN_DOCUMENT_NAMESPACE_DEVELOPMENT
+	upsertResp, err := viewAdminClient.UpsertDesignDocument(context.Background(), &admin_view_v1.UpsertDesignDocumentRequest{
+		BucketName: s.bucketName,
+		DesignDocument: &admin_view_v1.DesignDocument{
+			Name:      ddocName,
+			Namespace: devNamespace,
+			Views: map[string]*admin_view_v1.DesignDocument_View{
+				"test": {
+					Map: fmt.Sprintf("function (doc, meta) { if (meta.id == %q) { emit(meta.id, 1); } }", docId),
+				},
+			},
+		},
+	}, grpc.PerRPCCredentials(s.basicRpcCreds))
+	requireRpcSuccess(s.T(), upsertResp, err)
+
+	defer func() {
+		_, _ = viewAdminClient.DeleteDesignDocument(context.Background(), &admin_view_v1.DeleteDesignDocumentRequest{
+			BucketName: s.bucketName,
+			Name:       ddocName,
+			Namespace:  devNamespace,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+	}()
+
+	docIdKey, _ := json.Marshal(docId)
+
+	s.Run("Basic", func() {
+		assert.Eventually(s.T(), func() bool {
+			namespace := view_v1.ViewQueryRequest_NAMESPACE_DEVELOPMENT
+			consistency := view_v1.ViewQueryRequest_SCAN_CONSISTENCY_REQUEST_PLUS
+			client, err := viewClient.ViewQuery(context.Background(), &view_v1.ViewQueryRequest{
+				BucketName:         s.bucketName,
+				DesignDocumentName: ddocName,
+				ViewName:           "test",
+				Namespace:          &namespace,
+				ScanConsistency:    &consistency,
+				Key:                docIdKey,
+			}, grpc.PerRPCCredentials(s.basicRpcCreds))
+			requireRpcSuccess(s.T(), client, err)
+
+			rows, md, err := readViewStream(client)
+			if err != nil {
+				// the view may still be building, in which case we retry
+				return false
+			}
+
+			if len(rows) != 1 {
+				return false
+			}
+
+			assert.Equal(s.T(), docId, rows[0].Id)
+			assert.Equal(s.T(), docIdKey, rows[0].Key)
+			assert.Equal(s.T(), []byte("1"), rows[0].Value)
+			if assert.NotNil(s.T(), md) {
+				assert.Equal(s.T(), uint64(1), md.TotalRows)
+			}
+			return true
+		}, 30*time.Second, 1*time.Second)
+	})
+
+	s.Run("DesignDocumentMissing", func() {
+		namespace := view_v1.ViewQueryRequest_NAMESPACE_DEVELOPMENT
+		client, err := viewClient.ViewQuery(context.Background(), &view_v1.ViewQueryRequest{
+			BucketName:         s.bucketName,
+			DesignDocumentName: "missing-ddoc",
+			ViewName:           "test",
+			Namespace:          &namespace,
+		}, grpc.PerRPCCredentials(s.basicRpcCreds))
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readViewStream(client)
+		assertRpcStatus(s.T(), err, codes.NotFound)
+		assertRpcErrorDetails(s.T(), err, func(d *epb.ResourceInfo) {
+			assert.Equal(s.T(), d.ResourceType, "designdocument")
+		})
+	})
+
+	s.Run("Unauthenticated", func() {
+		client, err := viewClient.ViewQuery(context.Background(), &view_v1.ViewQueryRequest{
+			BucketName:         s.bucketName,
+			DesignDocumentName: ddocName,
+			ViewName:           "test",
+		})
+		requireRpcSuccess(s.T(), client, err)
+
+		_, _, err = readViewStream(client)
+		assertRpcStatus(s.T(), err, codes.Unauthenticated)
+	})
+}
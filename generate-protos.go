@@ -6,12 +6,15 @@
 //go:generate protostellar couchbase/search/v1/search.proto
 //go:generate protostellar couchbase/analytics/v1/analytics.proto
 //go:generate protostellar couchbase/view/v1/view.proto
+//go:generate protostellar couchbase/changefeed/v1/changefeed.proto
 //go:generate protostellar couchbase/transactions/v1/transactions.proto
 //go:generate protostellar couchbase/routing/v1/routing.proto
 //go:generate protostellar couchbase/admin/bucket/v1/bucket.proto
 //go:generate protostellar couchbase/admin/collection/v1/collection.proto
 //go:generate protostellar couchbase/admin/query/v1/query.proto
 //go:generate protostellar couchbase/admin/search/v1/search.proto
+//go:generate protostellar couchbase/admin/view/v1/view.proto
+//go:generate protostellar couchbase/admin/user/v1/user.proto
 //go:generate protostellar couchbase/internal/hooks/v1/hooks.proto
 
 package main
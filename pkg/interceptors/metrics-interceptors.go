@@ -2,28 +2,213 @@ package interceptors
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
 	"github.com/couchbase/stellar-gateway/pkg/metrics"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	rpcTypeUnary        = "unary"
+	rpcTypeClientStream = "client_stream"
+	rpcTypeServerStream = "server_stream"
+	rpcTypeBidiStream   = "bidi_stream"
+
+	// kvUnknownBucketLabel is used in place of bucket names which have not yet
+	// been seen in a successful request.  Bucket names are supplied by the
+	// client before it is authenticated, so labelling with them directly would
+	// allow any client to create an unbounded number of metric series.
+	kvUnknownBucketLabel = "_unknown"
 )
 
 type MetricsInterceptor struct {
 	metrics *metrics.SnMetrics
+
+	knownBucketsLock sync.Mutex
+	knownBuckets     map[string]struct{}
 }
 
 func NewMetricsInterceptor(metrics *metrics.SnMetrics) *MetricsInterceptor {
 	return &MetricsInterceptor{
-		metrics: metrics,
+		metrics:      metrics,
+		knownBuckets: make(map[string]struct{}),
+	}
+}
+
+// splitMethodName splits a full method name of the form /service/method into
+// its service and method names.
+func splitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if slashIdx := strings.LastIndexByte(fullMethod, '/'); slashIdx >= 0 {
+		return fullMethod[:slashIdx], fullMethod[slashIdx+1:]
+	}
+	return "unknown", fullMethod
+}
+
+func streamRpcType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return rpcTypeBidiStream
+	case info.IsClientStream:
+		return rpcTypeClientStream
+	default:
+		return rpcTypeServerStream
+	}
+}
+
+// beginRequest records the start of a request, returning a function which
+// must be called with the result of the request once it completes.
+func (mi *MetricsInterceptor) beginRequest(fullMethod, rpcType string) func(err error) (string, time.Duration) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	startTime := time.Now()
+
+	mi.metrics.RequestsStarted.WithLabelValues(serviceName, methodName, rpcType).Inc()
+	inFlight := mi.metrics.RequestsInFlight.WithLabelValues(serviceName, methodName, rpcType)
+	inFlight.Inc()
+
+	return func(err error) (string, time.Duration) {
+		duration := time.Since(startTime)
+		code := status.Code(err).String()
+
+		inFlight.Dec()
+		mi.metrics.RequestsHandled.WithLabelValues(serviceName, methodName, rpcType, code).Inc()
+		mi.metrics.RequestDuration.WithLabelValues(serviceName, methodName, rpcType, code).Observe(duration.Seconds())
+
+		return code, duration
 	}
 }
 
-func (mi *MetricsInterceptor) UnaryConnectionCounterInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response interface{}, err error) {
-	mi.metrics.NewConnections.Add(1)
-	mi.metrics.ActiveConnections.Inc()
+// bucketLabel returns the label to use for a bucket.  A successful request
+// proves that the bucket exists and the client was permitted to use it, after
+// which the bucket is labelled by name for all subsequent requests.
+func (mi *MetricsInterceptor) bucketLabel(bucketName string, err error) string {
+	mi.knownBucketsLock.Lock()
+	defer mi.knownBucketsLock.Unlock()
+
+	if _, ok := mi.knownBuckets[bucketName]; ok {
+		return bucketName
+	}
+
+	if err != nil {
+		return kvUnknownBucketLabel
+	}
+
+	mi.knownBuckets[bucketName] = struct{}{}
+	return bucketName
+}
+
+// observeKvRequest records the per-bucket metrics of a kv request, since
+// buckets are frequently used to separate the workloads of different
+// applications.  Requests to other services are ignored.
+func (mi *MetricsInterceptor) observeKvRequest(fullMethod string, req interface{}, err error, code string, duration time.Duration) {
+	serviceName, methodName := splitMethodName(fullMethod)
+	if serviceName != kv_v1.KvService_ServiceDesc.ServiceName {
+		return
+	}
+
+	bucketReq, ok := req.(interface{ GetBucketName() string })
+	if !ok {
+		return
+	}
+
+	bucketLabel := mi.bucketLabel(bucketReq.GetBucketName(), err)
+	mi.metrics.KvRequestsHandled.WithLabelValues(bucketLabel, methodName, code).Inc()
+	mi.metrics.KvRequestDuration.WithLabelValues(bucketLabel, methodName).Observe(duration.Seconds())
+}
+
+func (mi *MetricsInterceptor) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	endRequest := mi.beginRequest(info.FullMethod, rpcTypeUnary)
 
 	resp, err := handler(ctx, req)
 
-	mi.metrics.ActiveConnections.Dec()
+	code, duration := endRequest(err)
+	mi.observeKvRequest(info.FullMethod, req, err, code, duration)
 
 	return resp, err
 }
+
+func (mi *MetricsInterceptor) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	endRequest := mi.beginRequest(info.FullMethod, streamRpcType(info))
+
+	metricsSs := &metricsServerStream{
+		ServerStream: ss,
+	}
+
+	err := handler(srv, metricsSs)
+
+	code, duration := endRequest(err)
+	if req := metricsSs.FirstRequest(); req != nil {
+		mi.observeKvRequest(info.FullMethod, req, err, code, duration)
+	}
+
+	return err
+}
+
+// metricsServerStream remembers the first request received on a stream, which
+// for server streaming kv requests such as Scan identifies the bucket.
+type metricsServerStream struct {
+	grpc.ServerStream
+
+	lock         sync.Mutex
+	firstRequest interface{}
+}
+
+func (s *metricsServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	if s.firstRequest == nil {
+		s.firstRequest = m
+	}
+	s.lock.Unlock()
+
+	return nil
+}
+
+func (s *metricsServerStream) FirstRequest() interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.firstRequest
+}
+
+// StatsHandler returns a gRPC stats handler which tracks the connections
+// made to the server, which the interceptors are unable to observe.
+func (mi *MetricsInterceptor) StatsHandler() stats.Handler {
+	return &metricsStatsHandler{
+		metrics: mi.metrics,
+	}
+}
+
+type metricsStatsHandler struct {
+	metrics *metrics.SnMetrics
+}
+
+func (h *metricsStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *metricsStatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+}
+
+func (h *metricsStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *metricsStatsHandler) HandleConn(ctx context.Context, cs stats.ConnStats) {
+	switch cs.(type) {
+	case *stats.ConnBegin:
+		h.metrics.NewConnections.Inc()
+		h.metrics.ActiveConnections.Inc()
+	case *stats.ConnEnd:
+		h.metrics.ActiveConnections.Dec()
+	}
+}
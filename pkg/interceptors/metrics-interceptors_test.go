@@ -0,0 +1,136 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/couchbase/goprotostellar/genproto/kv_v1"
+	"github.com/couchbase/stellar-gateway/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type testServerStream struct {
+	grpc.ServerStream
+	req proto.Message
+}
+
+func (s *testServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testServerStream) RecvMsg(m interface{}) error {
+	proto.Merge(m.(proto.Message), s.req)
+	return nil
+}
+
+func (s *testServerStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func TestUnaryInterceptorBucketLabels(t *testing.T) {
+	snMetrics := metrics.GetSnMetrics()
+	mi := NewMetricsInterceptor(snMetrics)
+
+	const bucketName = "metrics-test-unary"
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + kv_v1.KvService_ServiceDesc.ServiceName + "/Get"}
+	req := &kv_v1.GetRequest{BucketName: bucketName}
+
+	unknownUnauthenticated := snMetrics.KvRequestsHandled.WithLabelValues(kvUnknownBucketLabel, "Get", codes.Unauthenticated.String())
+	unknownBefore := testutil.ToFloat64(unknownUnauthenticated)
+	handledBefore := testutil.ToFloat64(snMetrics.RequestsHandled.WithLabelValues(
+		kv_v1.KvService_ServiceDesc.ServiceName, "Get", rpcTypeUnary, codes.Unauthenticated.String()))
+
+	invoke := func(err error) {
+		_, _ = mi.UnaryInterceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &kv_v1.GetResponse{}, nil
+		})
+	}
+
+	// the bucket has not been used successfully, so it must not be labelled
+	invoke(status.Error(codes.Unauthenticated, "unauthenticated"))
+
+	if delta := testutil.ToFloat64(unknownUnauthenticated) - unknownBefore; delta != 1 {
+		t.Fatalf("expected the failed request to use the unknown bucket label, got %v", delta)
+	}
+	if count := testutil.ToFloat64(snMetrics.KvRequestsHandled.WithLabelValues(bucketName, "Get", codes.Unauthenticated.String())); count != 0 {
+		t.Fatalf("expected no requests to be labelled with the bucket, got %v", count)
+	}
+	if delta := testutil.ToFloat64(snMetrics.RequestsHandled.WithLabelValues(
+		kv_v1.KvService_ServiceDesc.ServiceName, "Get", rpcTypeUnary, codes.Unauthenticated.String())) - handledBefore; delta != 1 {
+		t.Fatalf("expected the request to be counted as handled, got %v", delta)
+	}
+
+	// once a request succeeds, failures for the bucket are labelled too
+	invoke(nil)
+	invoke(status.Error(codes.NotFound, "not found"))
+
+	if count := testutil.ToFloat64(snMetrics.KvRequestsHandled.WithLabelValues(bucketName, "Get", codes.OK.String())); count != 1 {
+		t.Fatalf("expected the successful request to be labelled with the bucket, got %v", count)
+	}
+	if count := testutil.ToFloat64(snMetrics.KvRequestsHandled.WithLabelValues(bucketName, "Get", codes.NotFound.String())); count != 1 {
+		t.Fatalf("expected the later failed request to be labelled with the bucket, got %v", count)
+	}
+}
+
+func TestStreamInterceptorBucketLabels(t *testing.T) {
+	snMetrics := metrics.GetSnMetrics()
+	mi := NewMetricsInterceptor(snMetrics)
+
+	const bucketName = "metrics-test-stream"
+	info := &grpc.StreamServerInfo{
+		FullMethod:     "/" + kv_v1.KvService_ServiceDesc.ServiceName + "/Scan",
+		IsServerStream: true,
+	}
+	ss := &testServerStream{req: &kv_v1.ScanRequest{BucketName: bucketName}}
+
+	err := mi.StreamInterceptor(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+		var req kv_v1.ScanRequest
+		return stream.RecvMsg(&req)
+	})
+	if err != nil {
+		t.Fatalf("unexpected stream error: %s", err)
+	}
+
+	if count := testutil.ToFloat64(snMetrics.KvRequestsHandled.WithLabelValues(bucketName, "Scan", codes.OK.String())); count != 1 {
+		t.Fatalf("expected the scan to be labelled with the bucket, got %v", count)
+	}
+	if count := testutil.ToFloat64(snMetrics.RequestsHandled.WithLabelValues(
+		kv_v1.KvService_ServiceDesc.ServiceName, "Scan", rpcTypeServerStream, codes.OK.String())); count != 1 {
+		t.Fatalf("expected the scan to be counted as handled, got %v", count)
+	}
+}
+
+func TestStatsHandlerConnections(t *testing.T) {
+	snMetrics := metrics.GetSnMetrics()
+	handler := NewMetricsInterceptor(snMetrics).StatsHandler()
+
+	newBefore := testutil.ToFloat64(snMetrics.NewConnections)
+	activeBefore := testutil.ToFloat64(snMetrics.ActiveConnections)
+
+	handler.HandleConn(context.Background(), &stats.ConnBegin{})
+	handler.HandleConn(context.Background(), &stats.ConnBegin{})
+
+	if delta := testutil.ToFloat64(snMetrics.NewConnections) - newBefore; delta != 2 {
+		t.Fatalf("expected 2 new connections, got %v", delta)
+	}
+	if delta := testutil.ToFloat64(snMetrics.ActiveConnections) - activeBefore; delta != 2 {
+		t.Fatalf("expected 2 active connections, got %v", delta)
+	}
+
+	handler.HandleConn(context.Background(), &stats.ConnEnd{})
+
+	if delta := testutil.ToFloat64(snMetrics.NewConnections) - newBefore; delta != 2 {
+		t.Fatalf("expected closing a connection not to change new connections, got %v", delta)
+	}
+	if delta := testutil.ToFloat64(snMetrics.ActiveConnections) - activeBefore; delta != 1 {
+		t.Fatalf("expected 1 active connection, got %v", delta)
+	}
+}
@@ -10,6 +10,17 @@ import (
 type SnMetrics struct {
 	NewConnections    prometheus.Counter
 	ActiveConnections prometheus.Gauge
+
+	RequestsStarted  *prometheus.CounterVec
+	RequestsHandled  *prometheus.CounterVec
+	RequestsInFlight *prometheus.GaugeVec
+	RequestDuration  *prometheus.HistogramVec
+
+	KvRequestsHandled *prometheus.CounterVec
+	KvRequestDuration *prometheus.HistogramVec
+
+	QueryPreparedCacheHits   prometheus.Counter
+	QueryPreparedCacheMisses prometheus.Counter
 }
 
 var (
@@ -31,6 +42,10 @@ func GetSnMetrics() *SnMetrics {
 	return snMetrics
 }
 
+// requestDurationBuckets spans from sub-millisecond KV operations through to
+// long running queries, in seconds.
+var requestDurationBuckets = prometheus.ExponentialBuckets(0.0005, 2, 16)
+
 func newSnMetrics() *SnMetrics {
 	return &SnMetrics{
 		NewConnections: promauto.NewCounter(prometheus.CounterOpts{
@@ -43,5 +58,47 @@ func newSnMetrics() *SnMetrics {
 			Name:      "grpc_active_connections",
 			Help:      "The number of active grpc connections.",
 		}),
+		RequestsStarted: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sn",
+			Name:      "grpc_requests_started",
+			Help:      "The number of gRPC requests which have been started.",
+		}, []string{"grpc_service", "grpc_method", "grpc_type"}),
+		RequestsHandled: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sn",
+			Name:      "grpc_requests_handled",
+			Help:      "The number of gRPC requests which have completed, by status code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_type", "grpc_code"}),
+		RequestsInFlight: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "sn",
+			Name:      "grpc_requests_in_flight",
+			Help:      "The number of gRPC requests currently being handled.",
+		}, []string{"grpc_service", "grpc_method", "grpc_type"}),
+		RequestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sn",
+			Name:      "grpc_request_duration_seconds",
+			Help:      "The time taken to handle gRPC requests, by status code.",
+			Buckets:   requestDurationBuckets,
+		}, []string{"grpc_service", "grpc_method", "grpc_type", "grpc_code"}),
+		KvRequestsHandled: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sn",
+			Name:      "kv_requests_handled",
+			Help:      "The number of KV requests which have completed, by bucket and status code.",
+		}, []string{"bucket", "grpc_method", "grpc_code"}),
+		KvRequestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sn",
+			Name:      "kv_request_duration_seconds",
+			Help:      "The time taken to handle KV requests, by bucket.",
+			Buckets:   requestDurationBuckets,
+		}, []string{"bucket", "grpc_method"}),
+		QueryPreparedCacheHits: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: "sn",
+			Name:      "query_prepared_cache_hits",
+			Help:      "The number of prepared queries which reused a cached prepared statement.",
+		}),
+		QueryPreparedCacheMisses: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: "sn",
+			Name:      "query_prepared_cache_misses",
+			Help:      "The number of prepared queries which had to prepare their statement.",
+		}),
 	}
 }
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/couchbase/stellar-gateway/gateway/capture"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	// the generated packages register their message types, which is how the
+	// captured messages are decoded.
+	_ "github.com/couchbase/goprotostellar/genproto/admin_bucket_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/admin_collection_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/admin_query_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/admin_search_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/admin_user_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/admin_view_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/analytics_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/changefeed_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/internal_hooks_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/kv_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/query_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/routing_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/search_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/transactions_v1"
+	_ "github.com/couchbase/goprotostellar/genproto/view_v1"
+)
+
+var capturePath = flag.String("capture", "", "the capture file to replay")
+var addr = flag.String("addr", "localhost:18098", "the address of the gateway to replay against")
+var username = flag.String("username", "Administrator", "the username to replay requests with")
+var password = flag.String("password", "password", "the password to replay requests with")
+var ignoreFields = flag.String("ignore-fields", "cas,mutation_token",
+	"comma-separated names of response fields which are expected to differ between runs")
+var timeout = flag.Duration("timeout", 10*time.Second, "the timeout for each replayed request")
+
+type basicAuthCreds struct {
+	encodedData string
+}
+
+func (c basicAuthCreds) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Basic " + c.encodedData,
+	}, nil
+}
+
+func (c basicAuthCreds) RequireTransportSecurity() bool {
+	return false
+}
+
+func main() {
+	flag.Parse()
+
+	if *capturePath == "" {
+		log.Fatalf("a capture file must be specified")
+	}
+
+	captureFile, err := os.Open(*capturePath)
+	if err != nil {
+		log.Fatalf("failed to open capture file: %s", err)
+	}
+	defer captureFile.Close()
+
+	conn, err := grpc.Dial(*addr,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			InsecureSkipVerify: true,
+		})),
+		grpc.WithPerRPCCredentials(basicAuthCreds{
+			encodedData: base64.StdEncoding.EncodeToString([]byte(*username + ":" + *password)),
+		}))
+	if err != nil {
+		log.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close()
+
+	var ignoredFieldNames []string
+	for _, fieldName := range strings.Split(*ignoreFields, ",") {
+		fieldName = strings.TrimSpace(fieldName)
+		if fieldName != "" {
+			ignoredFieldNames = append(ignoredFieldNames, fieldName)
+		}
+	}
+
+	reader := capture.NewReader(captureFile)
+
+	numReplayed, numDiffering := 0, 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			log.Fatalf("failed to read capture record: %s", err)
+		}
+
+		numReplayed++
+
+		diffs, err := replayRecord(conn, record, ignoredFieldNames)
+		if err != nil {
+			log.Printf("failed to replay %s: %s", record.Method, err)
+			numDiffering++
+			continue
+		}
+
+		if len(diffs) > 0 {
+			numDiffering++
+			log.Printf("response mismatch for %s (captured at %s):", record.Method, record.StartTime.Format(time.RFC3339Nano))
+			for _, diff := range diffs {
+				log.Printf("  %s", diff)
+			}
+		}
+	}
+
+	log.Printf("replayed %d requests, %d differed from the capture", numReplayed, numDiffering)
+	if numDiffering > 0 {
+		os.Exit(1)
+	}
+}
+
+func decodeMessage(msg *capture.Message) (proto.Message, error) {
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(msg.Type))
+	if err != nil {
+		return nil, fmt.Errorf("unknown message type %s: %w", msg.Type, err)
+	}
+
+	decoded := msgType.New().Interface()
+	err = proto.Unmarshal(msg.Data, decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// newResponseMessage creates an empty message of the same type as the first
+// captured response, which is how the replayed responses are decoded.
+func newResponseMessage(record *capture.Record) (proto.Message, error) {
+	if len(record.Responses) == 0 {
+		// with no captured responses, the type does not matter since only
+		// the status is compared.
+		return &emptypb.Empty{}, nil
+	}
+
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(record.Responses[0].Type))
+	if err != nil {
+		return nil, fmt.Errorf("unknown message type %s: %w", record.Responses[0].Type, err)
+	}
+
+	return msgType.New().Interface(), nil
+}
+
+func replayRecord(conn *grpc.ClientConn, record *capture.Record, ignoredFieldNames []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	// forward the captured metadata, less anything grpc manages itself
+	md := metadata.MD{}
+	for key, values := range record.Metadata {
+		if key == "content-type" || key == "user-agent" || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		md[key] = values
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	requests := make([]proto.Message, len(record.Requests))
+	for reqIdx, capturedReq := range record.Requests {
+		req, err := decodeMessage(capturedReq)
+		if err != nil {
+			return nil, err
+		}
+		requests[reqIdx] = req
+	}
+
+	var responses []proto.Message
+	var callErr error
+	if !record.IsStream {
+		if len(requests) != 1 {
+			return nil, fmt.Errorf("expected 1 request for unary call, found %d", len(requests))
+		}
+
+		resp, err := newResponseMessage(record)
+		if err != nil {
+			return nil, err
+		}
+
+		callErr = conn.Invoke(ctx, record.Method, requests[0], resp)
+		if callErr == nil {
+			responses = append(responses, resp)
+		}
+	} else {
+		// all the requests are sent up front, this does not reproduce the
+		// interleaving of a bidirectional stream, but is sufficient for all
+		// the streaming RPCs the gateway currently serves.
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{
+			ServerStreams: true,
+			ClientStreams: true,
+		}, record.Method)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, req := range requests {
+			err := stream.SendMsg(req)
+			if err != nil {
+				break
+			}
+		}
+
+		err = stream.CloseSend()
+		if err != nil {
+			return nil, err
+		}
+
+		// truncated records only hold the start of the stream, so we stop
+		// once we have received as many responses as were captured.
+		for !record.Truncated || len(responses) < len(record.Responses) {
+			resp, err := newResponseMessage(record)
+			if err != nil {
+				return nil, err
+			}
+
+			err = stream.RecvMsg(resp)
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					callErr = err
+				}
+				break
+			}
+
+			responses = append(responses, resp)
+		}
+	}
+
+	var diffs []string
+
+	// the final status of a truncated stream is only compared if the stream
+	// ended before all the captured responses were replayed.
+	st := status.Convert(callErr)
+	capturedCode := codes.Code(record.Status.Code)
+	if (!record.Truncated || callErr != nil) && st.Code() != capturedCode {
+		diffs = append(diffs, fmt.Sprintf("status: captured %s (%s), replayed %s (%s)",
+			capturedCode, record.Status.Message, st.Code(), st.Message()))
+	}
+
+	if len(responses) != len(record.Responses) {
+		diffs = append(diffs, fmt.Sprintf("response count: captured %d, replayed %d",
+			len(record.Responses), len(responses)))
+	}
+
+	for respIdx := 0; respIdx < len(responses) && respIdx < len(record.Responses); respIdx++ {
+		capturedResp, err := decodeMessage(record.Responses[respIdx])
+		if err != nil {
+			return nil, err
+		}
+
+		replayedResp := responses[respIdx]
+
+		clearIgnoredFields(capturedResp.ProtoReflect(), ignoredFieldNames)
+		clearIgnoredFields(replayedResp.ProtoReflect(), ignoredFieldNames)
+
+		if !proto.Equal(capturedResp, replayedResp) {
+			diffs = append(diffs, fmt.Sprintf("response %d: captured %s, replayed %s",
+				respIdx, protojson.Format(capturedResp), protojson.Format(replayedResp)))
+		}
+	}
+
+	return diffs, nil
+}
+
+// clearIgnoredFields clears all the fields with the given names anywhere
+// within msg, so that they are excluded from comparisons.
+func clearIgnoredFields(msg protoreflect.Message, fieldNames []string) {
+	msg.Range(func(fd protoreflect.FieldDescriptor, val protoreflect.Value) bool {
+		for _, fieldName := range fieldNames {
+			if string(fd.Name()) == fieldName {
+				msg.Clear(fd)
+				return true
+			}
+		}
+
+		switch {
+		case fd.IsList():
+			if fd.Kind() == protoreflect.MessageKind {
+				list := val.List()
+				for i := 0; i < list.Len(); i++ {
+					clearIgnoredFields(list.Get(i).Message(), fieldNames)
+				}
+			}
+		case fd.IsMap():
+			if fd.MapValue().Kind() == protoreflect.MessageKind {
+				val.Map().Range(func(_ protoreflect.MapKey, mapVal protoreflect.Value) bool {
+					clearIgnoredFields(mapVal.Message(), fieldNames)
+					return true
+				})
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			clearIgnoredFields(val.Message(), fieldNames)
+		}
+
+		return true
+	})
+}